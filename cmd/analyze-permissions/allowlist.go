@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/usadamasa/claude-config/internal/settings"
@@ -23,6 +27,23 @@ func LoadPermissions(settingsPath string) (allow, deny, ask []string, err error)
 	return s.Permissions.Allow, s.Permissions.Deny, s.Permissions.Ask, nil
 }
 
+// LoadEffectivePermissions は複数レイヤの settings を読み込み、マージした有効なパーミッションを返す｡
+// 読み込めるレイヤが1つもない場合はエラーを返す｡
+func LoadEffectivePermissions(sources []settings.LayerSource) (*settings.Effective, error) {
+	layers, err := settings.LoadLayers(sources)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) == 0 {
+		var paths []string
+		for _, src := range sources {
+			paths = append(paths, src.Path)
+		}
+		return nil, fmt.Errorf("settings ファイルが見つかりません: %s", strings.Join(paths, ", "))
+	}
+	return settings.Merge(layers), nil
+}
+
 // ParsePermissionEntry はパーミッション文字列をツール名とパターンに分解する｡
 // 対象ツール(Bash, Read, Write, Edit)のエントリのみ ok=true を返す｡
 func ParsePermissionEntry(entry string) (tool, pattern string, ok bool) {
@@ -88,3 +109,168 @@ func MatchesPermission(toolName, pattern string, permissions []string) bool {
 	}
 	return false
 }
+
+// fileTools は file_path を引数に取るツール名セット｡
+var fileTools = map[string]bool{
+	"Read":         true,
+	"Write":        true,
+	"Edit":         true,
+	"MultiEdit":    true,
+	"NotebookEdit": true,
+}
+
+// MatchEnv は仮想ツール呼び出しのパス評価に使う環境｡
+type MatchEnv struct {
+	Home string
+	Cwd  string
+}
+
+// MatchToolCall はパーミッションエントリが仮想的なツール呼び出しにマッチするか判定する｡
+// MatchesPermission がスキャン済みプレフィックスを対象にするのに対し、
+// こちらは Claude Code と同じく完全なコマンド文字列やファイルパスを評価する｡
+func MatchToolCall(tool, arg, entry string, env MatchEnv) bool {
+	entryTool, inner, hasInner := splitPermissionEntry(entry)
+	if !matchToolName(tool, entryTool) {
+		return false
+	}
+	if !hasInner {
+		return true
+	}
+
+	switch {
+	case tool == "Bash":
+		return matchBashCommand(arg, inner)
+	case fileTools[tool]:
+		return matchFilePath(arg, inner, env)
+	case tool == "WebFetch" || tool == "Fetch":
+		return matchDomain(arg, inner)
+	default:
+		return globMatch(inner, arg)
+	}
+}
+
+// splitPermissionEntry はエントリを "Tool" と括弧内に分解する｡
+func splitPermissionEntry(entry string) (tool, inner string, hasInner bool) {
+	idx := strings.Index(entry, "(")
+	if idx < 0 || !strings.HasSuffix(entry, ")") {
+		return entry, "", false
+	}
+	return entry[:idx], entry[idx+1 : len(entry)-1], true
+}
+
+// matchToolName はツール名を比較する｡mcp__server__* 形式のワイルドカードに対応する｡
+func matchToolName(tool, entryTool string) bool {
+	if tool == entryTool {
+		return true
+	}
+	if strings.HasPrefix(entryTool, "mcp__") {
+		if strings.HasSuffix(entryTool, "*") {
+			return strings.HasPrefix(tool, strings.TrimSuffix(entryTool, "*"))
+		}
+		// mcp__server はサーバ配下の全ツールにマッチする
+		return strings.Count(entryTool, "__") == 1 && strings.HasPrefix(tool, entryTool+"__")
+	}
+	return false
+}
+
+// matchBashCommand は Bash パターンをコマンドに照合する｡
+// "prefix:*" は単語境界での前方一致、それ以外は完全一致(* はワイルドカード)｡
+func matchBashCommand(command, pattern string) bool {
+	command = strings.TrimSpace(command)
+	if prefix, ok := strings.CutSuffix(pattern, ":*"); ok {
+		return command == prefix || strings.HasPrefix(command, prefix+" ")
+	}
+	if strings.Contains(pattern, "*") {
+		return wildcardMatch(pattern, command)
+	}
+	return command == pattern
+}
+
+// matchFilePath は gitignore 形式のパスパターンをファイルパスに照合する｡
+// "/" を含まないパターンは任意の階層のファイル名にマッチする｡
+func matchFilePath(path, pattern string, env MatchEnv) bool {
+	path = expandHome(path, env.Home)
+	if !filepath.IsAbs(path) && env.Cwd != "" {
+		path = filepath.Join(env.Cwd, path)
+	}
+	path = filepath.Clean(path)
+
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = expandHome(pattern, env.Home)
+	case strings.HasPrefix(pattern, "//"):
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, "/"):
+	case !strings.Contains(strings.TrimSuffix(pattern, "/"), "/"):
+		return globMatch(pattern, filepath.Base(path))
+	default:
+		if env.Cwd == "" {
+			return false
+		}
+		pattern = filepath.Join(env.Cwd, pattern)
+	}
+	return globMatch(pattern, path)
+}
+
+// matchDomain は "domain:example.com" 形式のパターンを URL またはドメインに照合する｡
+func matchDomain(arg, pattern string) bool {
+	domain, ok := strings.CutPrefix(pattern, "domain:")
+	if !ok {
+		return globMatch(pattern, arg)
+	}
+	host := strings.TrimPrefix(arg, "domain:")
+	if u, err := url.Parse(host); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	if suffix, ok := strings.CutPrefix(domain, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == domain
+}
+
+func expandHome(path, home string) string {
+	if home == "" {
+		return path
+	}
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, rest)
+	}
+	return path
+}
+
+// globMatch はパスグロブを照合する｡** は "/" を含む任意の文字列、* は "/" 以外にマッチする｡
+// "dir/**" は dir 自身にもマッチする｡
+func globMatch(pattern, s string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok && s == prefix {
+		return true
+	}
+	return globRegexp(pattern).MatchString(s)
+}
+
+// wildcardMatch は * を "/" を含む任意の文字列として照合する｡
+func wildcardMatch(pattern, s string) bool {
+	return globRegexp(strings.ReplaceAll(pattern, "*", "**")).MatchString(s)
+}
+
+func globRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"path/filepath"
 	"testing"
+
+	"github.com/usadamasa/claude-config/internal/settings"
 )
 
 func TestLoadPermissions(t *testing.T) {
@@ -89,6 +92,41 @@ func TestLoadPermissions(t *testing.T) {
 	})
 }
 
+func TestLoadEffectivePermissions(t *testing.T) {
+	t.Run("複数レイヤをマージする", func(t *testing.T) {
+		dir := t.TempDir()
+		project := writeTestFile(t, dir, "project.json", `{"permissions":{"deny":["Bash(git push:*)"]}}`)
+		user := writeTestFile(t, dir, "user.json", `{"permissions":{"allow":["Bash(git push:*)","Bash(go test:*)"]}}`)
+
+		eff, err := LoadEffectivePermissions([]settings.LayerSource{
+			{Scope: settings.ScopeManaged, Path: filepath.Join(dir, "missing.json")},
+			{Scope: settings.ScopeProject, Path: project},
+			{Scope: settings.ScopeUser, Path: user},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(eff.Layers) != 2 {
+			t.Errorf("layers: got %d, want 2", len(eff.Layers))
+		}
+		if got := eff.Entries(settings.ListAllow); len(got) != 2 {
+			t.Errorf("allow: got %v, want 2 entries", got)
+		}
+		if len(eff.Overrides) != 1 || eff.Overrides[0].OverridingList != settings.ListDeny {
+			t.Errorf("overrides: got %+v, want 1 deny override", eff.Overrides)
+		}
+	})
+
+	t.Run("レイヤが1つもなければエラーを返す", func(t *testing.T) {
+		_, err := LoadEffectivePermissions([]settings.LayerSource{
+			{Scope: settings.ScopeUser, Path: "/nonexistent/settings.json"},
+		})
+		if err == nil {
+			t.Fatal("エラーが期待されるがnilが返された")
+		}
+	})
+}
+
 func TestParsePermissionEntry(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -160,3 +198,48 @@ func TestMatchesPermission(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchToolCall(t *testing.T) {
+	env := MatchEnv{Home: "/home/u", Cwd: "/home/u/src/repo"}
+
+	tests := []struct {
+		name  string
+		tool  string
+		arg   string
+		entry string
+		want  bool
+	}{
+		{"Bash 前方一致", "Bash", "git push origin main", "Bash(git push:*)", true},
+		{"Bash 前方一致は単語境界", "Bash", "gitk", "Bash(git:*)", false},
+		{"Bash 完全一致", "Bash", "npm run build", "Bash(npm run build)", true},
+		{"Bash 完全一致は引数違いに不一致", "Bash", "npm run build --prod", "Bash(npm run build)", false},
+		{"Bash ワイルドカード", "Bash", "git commit -m 'x'", "Bash(git * -m *)", true},
+		{"ベアエントリ", "Bash", "anything", "Bash", true},
+		{"ツール名不一致", "Read", "/etc/passwd", "Bash(cat:*)", false},
+		{"Read ホーム配下 **", "Read", "/home/u/.ssh/id_rsa", "Read(~/.ssh/**)", true},
+		{"Read ~ 表記", "Read", "~/.ssh/config", "Read(~/.ssh/**)", true},
+		{"Read ファイル名のみのパターンは任意階層", "Read", "/home/u/src/repo/sub/.env", "Read(.env)", true},
+		{"Read ファイル名のみのパターンは別名に不一致", "Read", "/home/u/src/repo/.env.sample", "Read(.env)", false},
+		{"Read 相対パターンは cwd 基準", "Write", "/home/u/src/repo/src/main.go", "Write(src/**)", true},
+		{"Read 相対パスの呼び出し", "Write", "src/main.go", "Write(src/**)", true},
+		{"Read 絶対パターン", "Read", "/tmp/x/y", "Read(/tmp/**)", true},
+		{"Read // 絶対パターン", "Read", "/var/log/syslog", "Read(//var/log/*)", true},
+		{"* はディレクトリを越えない", "Read", "/var/log/a/b", "Read(//var/log/*)", false},
+		{"WebFetch URL", "WebFetch", "https://pkg.go.dev/fmt", "WebFetch(domain:pkg.go.dev)", true},
+		{"WebFetch ワイルドカードドメイン", "WebFetch", "https://api.databricks.com/x", "WebFetch(domain:*.databricks.com)", true},
+		{"WebFetch 別ドメイン", "WebFetch", "https://evil.com/pkg.go.dev", "WebFetch(domain:pkg.go.dev)", false},
+		{"MCP ワイルドカード", "mcp__obsidian__search", "", "mcp__obsidian__*", true},
+		{"MCP サーバ名", "mcp__obsidian__search", "", "mcp__obsidian", true},
+		{"MCP 別サーバ", "mcp__other__search", "", "mcp__obsidian__*", false},
+		{"Skill 完全一致", "Skill", "commit-commands:commit-push-pr", "Skill(commit-commands:commit-push-pr)", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchToolCall(tt.tool, tt.arg, tt.entry, env)
+			if got != tt.want {
+				t.Errorf("MatchToolCall(%q, %q, %q) = %v, want %v", tt.tool, tt.arg, tt.entry, got, tt.want)
+			}
+		})
+	}
+}
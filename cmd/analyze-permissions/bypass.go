@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// バイパス手法｡DenyBypassWarning.Technique に入る｡
+const (
+	TechniqueFileCommand = "file-command"
+	TechniqueInterpreter = "interpreter"
+	TechniqueRedirect    = "redirect"
+	TechniqueGitObject   = "git-object"
+	TechniqueArchive     = "archive"
+	TechniqueEncode      = "encode"
+	TechniqueExec        = "exec"
+)
+
+// bypassRule は allow された Bash パターンが Read/Write deny を回避する手口を表す｡
+type bypassRule struct {
+	technique string
+	bypass    string // read, write, both
+	match     func(pattern string) bool
+	reason    string
+	// example は回避コマンド例のフォーマット｡%s に deny 対象のパスが入る｡
+	example string
+	// writeExample は Write/Edit deny に対する回避コマンド例｡空なら example を使う｡
+	writeExample string
+	// repoOnly は git オブジェクト経由など、リポジトリ内の相対パス deny にのみ有効な手口｡
+	repoOnly bool
+}
+
+// interpreterCommands はワンライナーで任意のファイル操作ができるインタプリタ｡
+var interpreterCommands = []struct {
+	command      string
+	example      string
+	writeExample string
+}{
+	{"python", `python -c "print(open('%s').read())"`, `python -c "open('%s','w').write('x')"`},
+	{"python3", `python3 -c "print(open('%s').read())"`, `python3 -c "open('%s','w').write('x')"`},
+	{"node", `node -e "console.log(require('fs').readFileSync('%s','utf8'))"`, `node -e "require('fs').writeFileSync('%s','x')"`},
+	{"perl", `perl -e 'print <>' %s`, `perl -e 'open(F, ">%s"); print F "x"'`},
+	{"ruby", `ruby -e 'puts File.read("%s")'`, `ruby -e 'File.write("%s", "x")'`},
+	{"php", `php -r 'echo file_get_contents("%s");'`, `php -r 'file_put_contents("%s", "x");'`},
+	{"bash", `bash -c 'cat %s'`, `bash -c 'echo x > %s'`},
+	{"sh", `sh -c 'cat %s'`, `sh -c 'echo x > %s'`},
+	{"zsh", `zsh -c 'cat %s'`, `zsh -c 'echo x > %s'`},
+	{"uv", `uv run python -c "print(open('%s').read())"`, `uv run python -c "open('%s','w').write('x')"`},
+}
+
+// bashBypassRules は bashDenyBypassPatterns の前方一致に加えて検出する手口｡
+var bashBypassRules = buildBashBypassRules()
+
+func buildBashBypassRules() []bypassRule {
+	var rules []bypassRule
+	for _, ic := range interpreterCommands {
+		cmd := ic.command
+		rules = append(rules, bypassRule{
+			technique:    TechniqueInterpreter,
+			bypass:       "both",
+			match:        func(p string) bool { return matchBashPrefix(p, cmd) },
+			reason:       fmt.Sprintf("インタプリタ %s で任意のファイル読み書き (Read + Write deny バイパス)", cmd),
+			example:      ic.example,
+			writeExample: ic.writeExample,
+		})
+	}
+	return append(rules,
+		bypassRule{
+			technique:    TechniqueExec,
+			bypass:       "both",
+			match:        func(p string) bool { return matchBashPrefix(p, "xargs") },
+			reason:       "xargs 経由で任意コマンド実行 (Read + Write deny バイパス)",
+			example:      "echo %s | xargs cat",
+			writeExample: "echo %s | xargs touch",
+		},
+		bypassRule{
+			technique: TechniqueGitObject,
+			bypass:    "read",
+			match: func(p string) bool {
+				return p == "git" || matchBashPrefix(p, "git show") || matchBashPrefix(p, "git cat-file") ||
+					matchBashPrefix(p, "git archive") || matchBashPrefix(p, "git grep")
+			},
+			reason:   "git オブジェクトから追跡ファイルを読取 (Read deny バイパス)",
+			example:  "git show HEAD:%s",
+			repoOnly: true,
+		},
+		bypassRule{
+			technique:    TechniqueArchive,
+			bypass:       "both",
+			match:        func(p string) bool { return matchBashPrefix(p, "tar") },
+			reason:       "アーカイブ作成/展開 (Read + Write deny バイパス)",
+			example:      "tar cf - %s | tar xOf -",
+			writeExample: "tar xf archive.tar %s",
+		},
+		bypassRule{
+			technique: TechniqueArchive,
+			bypass:    "read",
+			match:     func(p string) bool { return matchBashPrefix(p, "zip") },
+			reason:    "アーカイブ作成 (Read deny バイパス)",
+			example:   "zip - %s",
+		},
+		bypassRule{
+			technique: TechniqueArchive,
+			bypass:    "write",
+			match:     func(p string) bool { return matchBashPrefix(p, "unzip") },
+			reason:    "アーカイブ展開 (Write deny バイパス)",
+			example:   "unzip -o archive.zip %s",
+		},
+		bypassRule{
+			technique: TechniqueEncode,
+			bypass:    "read",
+			match: func(p string) bool {
+				return matchBashPrefix(p, "base64") || matchBashPrefix(p, "xxd") ||
+					matchBashPrefix(p, "od") || matchBashPrefix(p, "hexdump") || matchBashPrefix(p, "strings")
+			},
+			reason:  "エンコード/ダンプ経由の読取 (Read deny バイパス)",
+			example: "base64 %s",
+		},
+		bypassRule{
+			technique:    TechniqueFileCommand,
+			bypass:       "both",
+			match:        func(p string) bool { return matchBashPrefix(p, "dd") },
+			reason:       "ブロックコピー (Read + Write deny バイパス)",
+			example:      "dd if=%s",
+			writeExample: "dd of=%s",
+		},
+	)
+}
+
+// AnalyzeDenyBypass は allow の Bash エントリが回避できる Read/Write/Edit deny を列挙する｡
+// 前方一致のファイル操作コマンドに加え、インタプリタ、xargs、git オブジェクト読取、
+// アーカイブ、エンコード、dd、リダイレクトを検出する｡
+// 同じ allow/deny の組に複数の手口が該当する場合は最初の手口のみ報告する｡
+func AnalyzeDenyBypass(allow, deny []string) []DenyBypassWarning {
+	var warnings []DenyBypassWarning
+	for _, allowEntry := range allow {
+		reported := make(map[string]bool)
+		tool, pattern, ok := ParsePermissionEntry(allowEntry)
+		if !ok || tool != "Bash" || pattern == "" {
+			continue
+		}
+		for _, rule := range bashRulesFor(allowEntry, pattern) {
+			for _, denyEntry := range deny {
+				denyTool, denyPattern, denyOk := ParsePermissionEntry(denyEntry)
+				if !denyOk || !matchesBypassType(rule.bypass, denyTool) {
+					continue
+				}
+				if reported[denyEntry] || (rule.repoOnly && !isRepoRelativePattern(denyPattern)) {
+					continue
+				}
+				reported[denyEntry] = true
+				w := DenyBypassWarning{
+					AllowEntry:   allowEntry,
+					BypassedDeny: denyEntry,
+					Risk:         rule.reason,
+					Technique:    rule.technique,
+				}
+				if example := rule.exampleFor(denyTool); example != "" {
+					w.Example = fmt.Sprintf(example, exampleTarget(denyPattern))
+				}
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	return warnings
+}
+
+// exampleFor は deny のツールに合った回避コマンド例のフォーマットを返す｡
+func (r bypassRule) exampleFor(denyTool string) string {
+	if (denyTool == "Write" || denyTool == "Edit") && r.writeExample != "" {
+		return r.writeExample
+	}
+	return r.example
+}
+
+// bashRulesFor は allow エントリに該当する手口を返す｡
+// 前方一致(:*)でないエントリは完全一致のコマンドしか許可しないため、
+// コマンド自体に含まれるリダイレクトのみを対象とする｡
+func bashRulesFor(allowEntry, pattern string) []bypassRule {
+	isPrefix := strings.HasSuffix(allowEntry, ":*)") || strings.Contains(pattern, "*")
+	if !isPrefix {
+		if strings.Contains(pattern, ">") {
+			return []bypassRule{redirectRule(pattern)}
+		}
+		return nil
+	}
+
+	var rules []bypassRule
+	for _, p := range bashDenyBypassPatterns {
+		if p.match(pattern) {
+			rules = append(rules, bypassRule{technique: TechniqueFileCommand, bypass: p.bypass, reason: p.reason})
+		}
+	}
+	for _, r := range bashBypassRules {
+		if r.match(pattern) {
+			rules = append(rules, r)
+		}
+	}
+	return append(rules, redirectRule(pattern))
+}
+
+// redirectRule は任意の許可コマンドの出力リダイレクトによる Write deny バイパス｡
+func redirectRule(pattern string) bypassRule {
+	example := strings.TrimSuffix(pattern, "*")
+	if before, _, ok := strings.Cut(example, ">"); ok {
+		example = before
+	}
+	return bypassRule{
+		technique: TechniqueRedirect,
+		bypass:    "write",
+		reason:    "出力リダイレクト (Write deny バイパス)",
+		example:   strings.TrimSpace(example) + " > %s",
+	}
+}
+
+// aggregateRedirectWarnings はリダイレクトの警告を deny ごとに1件にまとめる｡
+// リダイレクトは出力を持つほぼ全ての許可コマンドに該当するため、allow エントリは AllowEntries に集約する｡
+// ほかの手口の警告は順序を保って先に並べる｡
+func aggregateRedirectWarnings(warnings []DenyBypassWarning) []DenyBypassWarning {
+	var result []DenyBypassWarning
+	redirects := make(map[string]*DenyBypassWarning)
+	var redirectDenies []string
+	for _, w := range warnings {
+		if w.Technique != TechniqueRedirect {
+			result = append(result, w)
+			continue
+		}
+		agg, ok := redirects[w.BypassedDeny]
+		if !ok {
+			_, denyPattern, _ := ParsePermissionEntry(w.BypassedDeny)
+			agg = &DenyBypassWarning{
+				BypassedDeny: w.BypassedDeny,
+				Risk:         w.Risk,
+				Technique:    TechniqueRedirect,
+				Example:      "<cmd> > " + exampleTarget(denyPattern),
+			}
+			redirects[w.BypassedDeny] = agg
+			redirectDenies = append(redirectDenies, w.BypassedDeny)
+		}
+		agg.AllowEntries = append(agg.AllowEntries, w.allowEntries()...)
+	}
+	for _, deny := range redirectDenies {
+		result = append(result, *redirects[deny])
+	}
+	return result
+}
+
+// allowEntries は警告の対象となる allow エントリを返す｡集約済みなら AllowEntries を返す｡
+func (w DenyBypassWarning) allowEntries() []string {
+	if len(w.AllowEntries) > 0 {
+		return w.AllowEntries
+	}
+	return []string{w.AllowEntry}
+}
+
+// hasOutputRedirect はコマンドがクォート外でファイルへの出力リダイレクト(> または >>)を含むかを返す｡
+// 2>&1 のようなファイルディスクリプタの複製は含めない｡
+func hasOutputRedirect(command string) bool {
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '>':
+			next := i + 1
+			if next < len(command) && command[next] == '>' {
+				next++
+			}
+			if next < len(command) && command[next] == '&' {
+				i = next
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// isRepoRelativePattern は deny パターンがリポジトリ内の相対パスを指すかを返す｡
+func isRepoRelativePattern(pattern string) bool {
+	return !strings.HasPrefix(pattern, "~") && !strings.HasPrefix(pattern, "/")
+}
+
+// exampleTarget は deny パターンから回避コマンド例に使う具体的なパスを作る｡
+func exampleTarget(pattern string) string {
+	target := strings.ReplaceAll(pattern, "**", "secret")
+	return strings.ReplaceAll(target, "*", "secret")
+}
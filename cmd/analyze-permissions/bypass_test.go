@@ -0,0 +1,162 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeDenyBypass(t *testing.T) {
+	deny := []string{"Read(~/.ssh/**)", "Read(.env)", "Write(.env)", "Edit(secrets/**)"}
+
+	findWarning := func(ws []DenyBypassWarning, allow, denyEntry string) *DenyBypassWarning {
+		for i := range ws {
+			if ws[i].AllowEntry == allow && ws[i].BypassedDeny == denyEntry {
+				return &ws[i]
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name          string
+		allow         string
+		deny          string
+		wantTechnique string
+		wantExample   string
+	}{
+		{"ファイル読取コマンド", "Bash(cat:*)", "Read(~/.ssh/**)", TechniqueFileCommand, ""},
+		{"python -c", "Bash(python3:*)", "Read(~/.ssh/**)", TechniqueInterpreter, "python3 -c \"print(open('~/.ssh/secret').read())\""},
+		{"node -e", "Bash(node:*)", "Write(.env)", TechniqueInterpreter, "node -e \"require('fs').writeFileSync('.env','x')\""},
+		{"Write deny には書き込みの例", "Bash(python3:*)", "Write(.env)", TechniqueInterpreter, "python3 -c \"open('.env','w').write('x')\""},
+		{"perl -e", "Bash(perl:*)", "Read(.env)", TechniqueInterpreter, "perl -e 'print <>' .env"},
+		{"ruby -e", "Bash(ruby:*)", "Edit(secrets/**)", TechniqueInterpreter, ""},
+		{"uv run python", "Bash(uv:*)", "Read(.env)", TechniqueInterpreter, ""},
+		{"xargs", "Bash(xargs:*)", "Read(.env)", TechniqueExec, "echo .env | xargs cat"},
+		{"git show", "Bash(git show:*)", "Read(.env)", TechniqueGitObject, "git show HEAD:.env"},
+		{"git 全体", "Bash(git:*)", "Read(.env)", TechniqueGitObject, "git show HEAD:.env"},
+		{"git cat-file", "Bash(git cat-file:*)", "Read(.env)", TechniqueGitObject, ""},
+		{"tar", "Bash(tar:*)", "Read(~/.ssh/**)", TechniqueArchive, ""},
+		{"unzip", "Bash(unzip:*)", "Write(.env)", TechniqueArchive, ""},
+		{"base64", "Bash(base64:*)", "Read(~/.ssh/**)", TechniqueEncode, "base64 ~/.ssh/secret"},
+		{"dd", "Bash(dd:*)", "Write(.env)", TechniqueFileCommand, "dd of=.env"},
+		{"リダイレクト", "Bash(jq:*)", "Write(.env)", TechniqueRedirect, "jq > .env"},
+		{"リダイレクトは Edit deny も対象", "Bash(jq:*)", "Edit(secrets/**)", TechniqueRedirect, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := AnalyzeDenyBypass([]string{tt.allow}, deny)
+			w := findWarning(ws, tt.allow, tt.deny)
+			if w == nil {
+				t.Fatalf("%s -> %s の警告が見つからない: %+v", tt.allow, tt.deny, ws)
+			}
+			if w.Technique != tt.wantTechnique {
+				t.Errorf("Technique: got %q, want %q", w.Technique, tt.wantTechnique)
+			}
+			if tt.wantExample != "" && w.Example != tt.wantExample {
+				t.Errorf("Example: got %q, want %q", w.Example, tt.wantExample)
+			}
+		})
+	}
+
+	t.Run("git オブジェクトはリポジトリ外の deny を対象にしない", func(t *testing.T) {
+		ws := AnalyzeDenyBypass([]string{"Bash(git show:*)"}, []string{"Read(~/.ssh/**)", "Read(//etc/shadow)"})
+		for _, w := range ws {
+			if w.Technique == TechniqueGitObject {
+				t.Errorf("リポジトリ外の deny に git-object 警告: %+v", w)
+			}
+		}
+	})
+
+	t.Run("リダイレクトは Read deny を対象にしない", func(t *testing.T) {
+		ws := AnalyzeDenyBypass([]string{"Bash(jq:*)"}, []string{"Read(.env)"})
+		if len(ws) != 0 {
+			t.Errorf("Read deny にリダイレクト警告: %+v", ws)
+		}
+	})
+
+	t.Run("完全一致エントリはコマンド内のリダイレクトのみ検出", func(t *testing.T) {
+		ws := AnalyzeDenyBypass([]string{"Bash(python3 --version)"}, deny)
+		if len(ws) != 0 {
+			t.Errorf("完全一致エントリに警告: %+v", ws)
+		}
+		ws = AnalyzeDenyBypass([]string{"Bash(date > .env)"}, deny)
+		if w := findWarning(ws, "Bash(date > .env)", "Write(.env)"); w == nil || w.Technique != TechniqueRedirect {
+			t.Errorf("リダイレクトを含む完全一致エントリの警告: got %+v", ws)
+		}
+	})
+
+	t.Run("同じ組は最初の手口のみ報告", func(t *testing.T) {
+		ws := AnalyzeDenyBypass([]string{"Bash(echo:*)"}, []string{"Write(.env)"})
+		if len(ws) != 1 || ws[0].Technique != TechniqueFileCommand {
+			t.Errorf("got %+v, want file-command の1件", ws)
+		}
+	})
+
+	t.Run("Bash 以外は対象外", func(t *testing.T) {
+		ws := AnalyzeDenyBypass([]string{"Read(src/**)", "WebFetch(domain:example.com)"}, deny)
+		if len(ws) != 0 {
+			t.Errorf("got %+v", ws)
+		}
+	})
+}
+
+func TestAggregateRedirectWarnings(t *testing.T) {
+	ws := aggregateRedirectWarnings(AnalyzeDenyBypass(
+		[]string{"Bash(jq:*)", "Bash(ls:*)", "Bash(python3:*)"},
+		[]string{"Write(.env)", "Edit(secrets/**)"},
+	))
+	if len(ws) != 4 {
+		t.Fatalf("got %d warnings, want 2 interpreter + 2 redirect: %+v", len(ws), ws)
+	}
+	for _, w := range ws[:2] {
+		if w.Technique != TechniqueInterpreter || w.AllowEntry != "Bash(python3:*)" {
+			t.Errorf("インタプリタ警告が先に並ぶべき: %+v", w)
+		}
+	}
+	redirect := ws[2]
+	if redirect.Technique != TechniqueRedirect || redirect.BypassedDeny != "Write(.env)" || redirect.AllowEntry != "" {
+		t.Errorf("リダイレクト警告: got %+v", redirect)
+	}
+	if !reflect.DeepEqual(redirect.AllowEntries, []string{"Bash(jq:*)", "Bash(ls:*)"}) {
+		t.Errorf("AllowEntries: got %v", redirect.AllowEntries)
+	}
+	if redirect.Example != "<cmd> > .env" {
+		t.Errorf("Example: got %q", redirect.Example)
+	}
+}
+
+func TestHasOutputRedirect(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"go test ./...", false},
+		{"go test ./... > out.txt", true},
+		{"echo x >> .env", true},
+		{"make 2>&1", false},
+		{"make >&2", false},
+		{"make 2>/dev/null", true},
+		{`echo "a > b"`, false},
+		{`grep '>' file`, false},
+	}
+	for _, tt := range tests {
+		if got := hasOutputRedirect(tt.command); got != tt.want {
+			t.Errorf("hasOutputRedirect(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSummaryGroupsRedirectWarnings(t *testing.T) {
+	r := Report{Recommendations: Recommendations{
+		DenyBypassWarnings: aggregateRedirectWarnings(AnalyzeDenyBypass([]string{"Bash(jq:*)", "Bash(ls:*)", "Bash(python3:*)"}, []string{"Write(.env)"})),
+	}}
+	output := FormatSummary(r, "")
+	if !strings.Contains(output, "Deny bypass: 2 allow entries -> Write(.env) [redirect]") {
+		t.Errorf("リダイレクト警告が集約されていない:\n%s", output)
+	}
+	if !strings.Contains(output, "Deny bypass: Bash(python3:*) -> Write(.env) [interpreter]") {
+		t.Errorf("インタプリタ警告が含まれていない:\n%s", output)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/usadamasa/claude-config/internal/settings"
+)
+
+// Decision は仮想ツール呼び出しの評価結果を表す｡
+type Decision string
+
+const (
+	DecisionAllow Decision = "allow"
+	DecisionAsk   Decision = "ask"
+	DecisionDeny  Decision = "deny"
+	// DecisionNone はどのルールにもマッチせず、実行時にユーザー確認となることを表す｡
+	DecisionNone Decision = "none"
+)
+
+// decisionStrength は複合コマンドの判定で使う制限の強さ(大きいほど強い)｡
+var decisionStrength = map[Decision]int{
+	DecisionAllow: 0,
+	DecisionNone:  1,
+	DecisionAsk:   2,
+	DecisionDeny:  3,
+}
+
+// ToolCall は評価対象の仮想的なツール呼び出し｡
+type ToolCall struct {
+	Tool string
+	Arg  string
+}
+
+// String は ToolCall をパーミッション形式の文字列で返す｡
+func (c ToolCall) String() string {
+	if c.Arg == "" {
+		return c.Tool
+	}
+	return fmt.Sprintf("%s(%s)", c.Tool, c.Arg)
+}
+
+// CheckResult はツール呼び出しの評価結果｡
+type CheckResult struct {
+	Call               string              `json:"call"`
+	Decision           Decision            `json:"decision"`
+	Matched            *settings.Rule      `json:"matched,omitempty"`
+	Segments           []SegmentResult     `json:"segments,omitempty"`
+	DenyBypassWarnings []DenyBypassWarning `json:"deny_bypass_warnings,omitempty"`
+}
+
+// SegmentResult は複合 Bash コマンドの各サブコマンドの評価結果｡
+type SegmentResult struct {
+	Command  string         `json:"command"`
+	Decision Decision       `json:"decision"`
+	Matched  *settings.Rule `json:"matched,omitempty"`
+}
+
+// ParseToolCall は "Bash(git push origin main)" 形式の文字列を ToolCall に分解する｡
+func ParseToolCall(s string) (ToolCall, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ToolCall{}, fmt.Errorf("ツール呼び出しが空です")
+	}
+	tool, arg, hasArg := splitPermissionEntry(s)
+	if !hasArg && strings.ContainsAny(s, "()") {
+		return ToolCall{}, fmt.Errorf("不正なツール呼び出し: %s (例: 'Bash(git status)')", s)
+	}
+	return ToolCall{Tool: tool, Arg: arg}, nil
+}
+
+// EvaluateToolCall は有効なパーミッションに対してツール呼び出しを評価する｡
+// deny > ask > allow の順に評価し、Bash の複合コマンドはサブコマンドごとに判定して
+// 最も制限の強い結果を採用する｡
+func EvaluateToolCall(call ToolCall, eff *settings.Effective, env MatchEnv) CheckResult {
+	result := CheckResult{Call: call.String()}
+
+	if call.Tool != "Bash" {
+		result.Decision, result.Matched = evaluateSingle(call, eff, env)
+		return result
+	}
+
+	segments := SplitBashCommand(call.Arg)
+	if len(segments) == 0 {
+		segments = []string{call.Arg}
+	}
+	result.Decision = DecisionAllow
+	for _, seg := range segments {
+		decision, matched := evaluateSingle(ToolCall{Tool: "Bash", Arg: seg}, eff, env)
+		result.Segments = append(result.Segments, SegmentResult{Command: seg, Decision: decision, Matched: matched})
+		if decisionStrength[decision] > decisionStrength[result.Decision] || (result.Matched == nil && decision == result.Decision) {
+			result.Decision = decision
+			result.Matched = matched
+		}
+		if decision == DecisionAllow && matched != nil {
+			result.DenyBypassWarnings = append(result.DenyBypassWarnings, segmentBypassWarnings(seg, matched.Entry, eff)...)
+		}
+	}
+	if len(result.Segments) == 1 {
+		result.Segments = nil
+	}
+	return result
+}
+
+// segmentBypassWarnings は許可されたサブコマンドの deny バイパス警告を返す｡
+// リダイレクトの手口はコマンドが実際に出力リダイレクトを含む場合のみ報告する｡
+func segmentBypassWarnings(command, allowEntry string, eff *settings.Effective) []DenyBypassWarning {
+	redirect := hasOutputRedirect(command)
+	var warnings []DenyBypassWarning
+	for _, w := range detectDenyBypassWarnings([]string{allowEntry}, eff.Entries(settings.ListDeny)) {
+		if w.Technique == TechniqueRedirect && !redirect {
+			continue
+		}
+		warnings = append(warnings, w)
+	}
+	return warnings
+}
+
+func evaluateSingle(call ToolCall, eff *settings.Effective, env MatchEnv) (Decision, *settings.Rule) {
+	for _, list := range []struct {
+		rules    []settings.Rule
+		decision Decision
+	}{
+		{eff.Deny, DecisionDeny},
+		{eff.Ask, DecisionAsk},
+		{eff.Allow, DecisionAllow},
+	} {
+		for _, rule := range list.rules {
+			if MatchToolCall(call.Tool, call.Arg, rule.Entry, env) {
+				matched := rule
+				return list.decision, &matched
+			}
+		}
+	}
+	return DecisionNone, nil
+}
+
+// SplitBashCommand は &&, ||, ;, |, 改行でコマンドを分割する｡
+// クォート内の区切り文字は無視する｡
+func SplitBashCommand(command string) []string {
+	var segments []string
+	var cur strings.Builder
+	var quote byte
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			segments = append(segments, s)
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(command) {
+				cur.WriteByte(c)
+				i++
+				c = command[i]
+			}
+			cur.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			cur.WriteByte(c)
+		case c == ';' || c == '\n':
+			flush()
+		case c == '&' && i+1 < len(command) && command[i+1] == '&':
+			flush()
+			i++
+		case c == '|':
+			flush()
+			if i+1 < len(command) && command[i+1] == '|' {
+				i++
+			}
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return segments
+}
+
+// FormatCheckResult は評価結果をテキストに整形する｡
+func FormatCheckResult(r CheckResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Call:     %s\n", r.Call)
+	fmt.Fprintf(&b, "Decision: %s\n", r.Decision)
+	if r.Matched != nil {
+		fmt.Fprintf(&b, "Matched:  %s  [%s, %s: %s]\n", r.Matched.Entry, r.Matched.List, r.Matched.Scope, r.Matched.Path)
+	} else {
+		fmt.Fprintf(&b, "Matched:  (none — 実行時にユーザー確認)\n")
+	}
+	if len(r.Segments) > 0 {
+		fmt.Fprintf(&b, "\n[SEGMENTS]\n")
+		for _, s := range r.Segments {
+			entry := "-"
+			if s.Matched != nil {
+				entry = s.Matched.Entry
+			}
+			fmt.Fprintf(&b, "  %-5s %-40s %s\n", s.Decision, s.Command, entry)
+		}
+	}
+	if len(r.DenyBypassWarnings) > 0 {
+		fmt.Fprintf(&b, "\n[WARNINGS]\n")
+		for _, w := range r.DenyBypassWarnings {
+			fmt.Fprintf(&b, "  Deny bypass: %s -> %s (%s)\n", w.AllowEntry, w.BypassedDeny, w.Risk)
+			if w.Example != "" {
+				fmt.Fprintf(&b, "    e.g. %s\n", w.Example)
+			}
+		}
+	}
+	return b.String()
+}
+
+// runCheck は check サブコマンドを実行し、終了コードを返す｡
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	settingsPath := fs.String("settings", "", "user レイヤの settings.json パス (デフォルト: git ルートの settings.json または ~/.claude/settings.json)")
+	layered := fs.Bool("layered", true, "managed/project/local レイヤをマージして評価する")
+	tool := fs.String("tool", "", "ツール名 (位置引数の代わりに指定)")
+	path := fs.String("path", "", "--tool と併用: ファイルパス (Read/Write/Edit)")
+	command := fs.String("command", "", "--tool と併用: コマンド文字列 (Bash)")
+	format := fs.String("format", "summary", "出力形式: summary または json")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze-permissions check [flags] 'Tool(arg)'\n       analyze-permissions check [flags] --tool Read --path ~/.ssh/id_rsa\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	call, err := toolCallFromFlags(fs.Args(), *tool, *path, *command)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		fs.Usage()
+		return 2
+	}
+
+	eff, env, err := loadCheckEnv(*settingsPath, *layered)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	result := EvaluateToolCall(call, eff, env)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			fmt.Fprintf(stderr, "結果の出力に失敗: %v\n", err)
+			return 1
+		}
+	case "summary":
+		fmt.Fprint(stdout, FormatCheckResult(result))
+	default:
+		fmt.Fprintf(stderr, "不明な出力形式: %s (summary または json を指定)\n", *format)
+		return 2
+	}
+	return 0
+}
+
+func toolCallFromFlags(args []string, tool, path, command string) (ToolCall, error) {
+	if tool == "" {
+		if len(args) != 1 {
+			return ToolCall{}, fmt.Errorf("評価するツール呼び出しを1つ指定してください")
+		}
+		return ParseToolCall(args[0])
+	}
+	if len(args) > 0 {
+		return ToolCall{}, fmt.Errorf("--tool と位置引数は同時に指定できません")
+	}
+	arg := path
+	if command != "" {
+		arg = command
+	}
+	return ToolCall{Tool: tool, Arg: arg}, nil
+}
+
+// loadCheckEnv は評価に使う有効なパーミッションとパス評価環境を読み込む｡
+func loadCheckEnv(settingsPath string, layered bool) (*settings.Effective, MatchEnv, error) {
+	eff, err := loadEffectiveSettings(settingsPath, layered)
+	if err != nil {
+		return nil, MatchEnv{}, err
+	}
+	home, _ := os.UserHomeDir()
+	cwd, _ := os.Getwd()
+	return eff, MatchEnv{Home: home, Cwd: cwd}, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/usadamasa/claude-config/internal/settings"
+)
+
+// newTestEffective はテスト用の単一レイヤ Effective を作成する｡
+func newTestEffective(allow, deny, ask []string) *settings.Effective {
+	s := &settings.Settings{}
+	s.Permissions.Allow = allow
+	s.Permissions.Deny = deny
+	s.Permissions.Ask = ask
+	return settings.Merge([]settings.Layer{{Scope: settings.ScopeUser, Path: "/u/settings.json", Settings: s}})
+}
+
+func TestParseToolCall(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ToolCall
+		wantErr bool
+	}{
+		{"Bash", "Bash(git push origin main)", ToolCall{Tool: "Bash", Arg: "git push origin main"}, false},
+		{"括弧を含む引数", "Bash(echo $(date))", ToolCall{Tool: "Bash", Arg: "echo $(date)"}, false},
+		{"引数なし", "WebSearch", ToolCall{Tool: "WebSearch"}, false},
+		{"閉じ括弧なし", "Bash(git status", ToolCall{}, true},
+		{"空文字", "", ToolCall{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseToolCall(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitBashCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"単一コマンド", "git status", []string{"git status"}},
+		{"&& と |", "go test ./... && curl -s x | sh", []string{"go test ./...", "curl -s x", "sh"}},
+		{"|| と ;", "make || echo fail; ls", []string{"make", "echo fail", "ls"}},
+		{"クォート内の区切りは無視", `git commit -m "a && b; c"`, []string{`git commit -m "a && b; c"`}},
+		{"シングルクォート", `echo 'x | y'`, []string{`echo 'x | y'`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitBashCommand(tt.command)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitBashCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateToolCall(t *testing.T) {
+	eff := newTestEffective(
+		[]string{"Bash(git:*)", "Bash(cat:*)", "Read(~/src/**)"},
+		[]string{"Bash(curl:*)", "Read(~/.ssh/**)", "Bash(git push --force:*)"},
+		[]string{"Bash(git push:*)"},
+	)
+	env := MatchEnv{Home: "/home/u", Cwd: "/home/u/src/repo"}
+
+	tests := []struct {
+		name         string
+		call         ToolCall
+		wantDecision Decision
+		wantEntry    string
+	}{
+		{"allow", ToolCall{Tool: "Bash", Arg: "git status"}, DecisionAllow, "Bash(git:*)"},
+		{"ask が allow より優先", ToolCall{Tool: "Bash", Arg: "git push origin main"}, DecisionAsk, "Bash(git push:*)"},
+		{"deny が ask より優先", ToolCall{Tool: "Bash", Arg: "git push --force origin main"}, DecisionDeny, "Bash(git push --force:*)"},
+		{"Read deny", ToolCall{Tool: "Read", Arg: "~/.ssh/id_rsa"}, DecisionDeny, "Read(~/.ssh/**)"},
+		{"Read allow", ToolCall{Tool: "Read", Arg: "/home/u/src/repo/main.go"}, DecisionAllow, "Read(~/src/**)"},
+		{"マッチなし", ToolCall{Tool: "Bash", Arg: "python3 x.py"}, DecisionNone, ""},
+		{"複合コマンドは最も強い判定", ToolCall{Tool: "Bash", Arg: "git status && curl x"}, DecisionDeny, "Bash(curl:*)"},
+		{"複合コマンドの一部がマッチなし", ToolCall{Tool: "Bash", Arg: "git status | python3"}, DecisionNone, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvaluateToolCall(tt.call, eff, env)
+			if got.Decision != tt.wantDecision {
+				t.Errorf("Decision: got %s, want %s", got.Decision, tt.wantDecision)
+			}
+			entry := ""
+			if got.Matched != nil {
+				entry = got.Matched.Entry
+			}
+			if entry != tt.wantEntry {
+				t.Errorf("Matched: got %q, want %q", entry, tt.wantEntry)
+			}
+		})
+	}
+
+	t.Run("allow された Bash の deny バイパス警告", func(t *testing.T) {
+		got := EvaluateToolCall(ToolCall{Tool: "Bash", Arg: "cat ~/.ssh/id_rsa"}, eff, env)
+		if got.Decision != DecisionAllow {
+			t.Fatalf("Decision: got %s, want allow", got.Decision)
+		}
+		if len(got.DenyBypassWarnings) != 1 || got.DenyBypassWarnings[0].BypassedDeny != "Read(~/.ssh/**)" {
+			t.Errorf("DenyBypassWarnings: got %+v", got.DenyBypassWarnings)
+		}
+	})
+
+	t.Run("リダイレクトはコマンドが > を含む場合のみ警告する", func(t *testing.T) {
+		eff := newTestEffective([]string{"Bash(go test:*)"}, []string{"Write(.env)", "Edit(secrets/**)"}, nil)
+		got := EvaluateToolCall(ToolCall{Tool: "Bash", Arg: "go test ./... 2>&1"}, eff, env)
+		if len(got.DenyBypassWarnings) != 0 {
+			t.Errorf("リダイレクトのないコマンドに警告: %+v", got.DenyBypassWarnings)
+		}
+		got = EvaluateToolCall(ToolCall{Tool: "Bash", Arg: "go test ./... > .env"}, eff, env)
+		if len(got.DenyBypassWarnings) != 2 || got.DenyBypassWarnings[0].Technique != TechniqueRedirect {
+			t.Errorf("DenyBypassWarnings: got %+v", got.DenyBypassWarnings)
+		}
+	})
+
+	t.Run("出所のレイヤを返す", func(t *testing.T) {
+		got := EvaluateToolCall(ToolCall{Tool: "Bash", Arg: "git log"}, eff, env)
+		if got.Matched == nil || got.Matched.Scope != settings.ScopeUser || got.Matched.Path != "/u/settings.json" {
+			t.Errorf("Matched: got %+v", got.Matched)
+		}
+	})
+}
+
+func TestRunCheck(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "settings.json", `{
+  "permissions": {
+    "allow": ["Bash(go test:*)"],
+    "deny": ["Bash(curl:*)"]
+  }
+}`)
+
+	t.Run("summary 出力", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"--settings", path, "--layered=false", "Bash(curl https://example.com)"}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("exit code: got %d, stderr: %s", code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "Decision: deny") {
+			t.Errorf("判定が含まれていない:\n%s", stdout.String())
+		}
+		if !strings.Contains(stdout.String(), "Bash(curl:*)  [deny, user: "+path+"]") {
+			t.Errorf("マッチしたエントリが含まれていない:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("--tool と json 出力", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"--settings", path, "--layered=false", "--format", "json", "--tool", "Bash", "--command", "go test ./..."}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("exit code: got %d, stderr: %s", code, stderr.String())
+		}
+		var got CheckResult
+		if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+			t.Fatalf("JSON パース失敗: %v", err)
+		}
+		if got.Decision != DecisionAllow {
+			t.Errorf("Decision: got %s, want allow", got.Decision)
+		}
+	})
+
+	t.Run("引数なしは終了コード2", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runCheck([]string{"--settings", path}, &stdout, &stderr); code != 2 {
+			t.Errorf("exit code: got %d, want 2", code)
+		}
+	})
+}
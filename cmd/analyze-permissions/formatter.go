@@ -64,14 +64,15 @@ func FormatSummary(r Report, jsonOutputPath string) string {
 		if len(r.Recommendations.BareEntryWarnings) > 0 {
 			fmt.Fprintf(&b, "  Bare entries: %s\n", strings.Join(r.Recommendations.BareEntryWarnings, ", "))
 		}
-		for _, w := range r.Recommendations.DenyBypassWarnings {
-			fmt.Fprintf(&b, "  Deny bypass: %s -> %s\n", w.AllowEntry, w.BypassedDeny)
-		}
+		writeDenyBypassWarnings(&b, r.Recommendations.DenyBypassWarnings)
 		for _, w := range r.Recommendations.AllowEncompassesDeny {
 			fmt.Fprintf(&b, "  Allow encompasses deny: %s covers %s\n", w.AllowEntry, w.DenyEntry)
 		}
 	}
 
+	// レイヤ
+	writeLayers(&b, r.Layers)
+
 	// サマリ行
 	fmt.Fprintf(&b, "\nSummary: %d allow / %d deny / %d ask",
 		len(r.CurrentAllow), len(r.CurrentDeny), len(r.CurrentAsk))
@@ -91,6 +92,56 @@ func FormatSummary(r Report, jsonOutputPath string) string {
 	return b.String()
 }
 
+// writeLayers は読み込んだ settings レイヤと、上位レイヤに上書きされたエントリを出力する｡
+func writeLayers(b *strings.Builder, layers *LayerReport) {
+	if layers == nil || len(layers.Sources) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n[LAYERS] %d layers (highest precedence first):\n", len(layers.Sources))
+	for _, l := range layers.Sources {
+		fmt.Fprintf(b, "  %-8s %3d allow / %3d deny / %3d ask  %s\n", l.Scope, l.Allow, l.Deny, l.Ask, l.Path)
+	}
+	total := len(layers.Overrides)
+	if total == 0 {
+		return
+	}
+	limit := min(total, 10)
+	fmt.Fprintf(b, "  Overridden entries: %d (showing %d/%d)\n", total, limit, total)
+	for _, o := range layers.Overrides[:limit] {
+		fmt.Fprintf(b, "    %s %s: %s <- %s %s\n", o.Scope, o.List, o.Entry, o.OverriddenBy, o.OverridingList)
+	}
+	if total > limit {
+		fmt.Fprintf(b, "    ... and %d more (use --format json for full list)\n", total-limit)
+	}
+}
+
+// writeDenyBypassWarnings は deny バイパス警告を出力する｡
+// リダイレクトはほぼ全ての前方一致 allow が該当するため deny ごとに件数のみ集約する｡
+func writeDenyBypassWarnings(b *strings.Builder, warnings []DenyBypassWarning) {
+	redirects := make(map[string]int)
+	var redirectDenies []string
+	for _, w := range warnings {
+		if w.Technique == TechniqueRedirect {
+			if redirects[w.BypassedDeny] == 0 {
+				redirectDenies = append(redirectDenies, w.BypassedDeny)
+			}
+			redirects[w.BypassedDeny] += len(w.allowEntries())
+			continue
+		}
+		fmt.Fprintf(b, "  Deny bypass: %s -> %s", w.AllowEntry, w.BypassedDeny)
+		if w.Technique != "" {
+			fmt.Fprintf(b, " [%s]", w.Technique)
+		}
+		if w.Example != "" {
+			fmt.Fprintf(b, "  e.g. %s", w.Example)
+		}
+		fmt.Fprintln(b)
+	}
+	for _, deny := range redirectDenies {
+		fmt.Fprintf(b, "  Deny bypass: %d allow entries -> %s [%s]  e.g. <cmd> > path\n", redirects[deny], deny, TechniqueRedirect)
+	}
+}
+
 // formatPermission はツール名とパターンからパーミッション形式の文字列を生成する｡
 func formatPermission(toolName, pattern string) string {
 	if toolName == "Bash" {
@@ -3,6 +3,8 @@ package main
 import (
 	"strings"
 	"testing"
+
+	"github.com/usadamasa/claude-config/internal/settings"
 )
 
 func TestFormatSummary(t *testing.T) {
@@ -138,6 +140,32 @@ func TestFormatSummary(t *testing.T) {
 		}
 	})
 
+	t.Run("レイヤと上書きエントリ", func(t *testing.T) {
+		report := Report{
+			Layers: &LayerReport{
+				Sources: []LayerSummary{
+					{Scope: settings.ScopeProject, Path: "/repo/.claude/settings.json", Deny: 1},
+					{Scope: settings.ScopeUser, Path: "/home/u/.claude/settings.json", Allow: 2},
+				},
+				Overrides: []settings.Override{
+					{Entry: "Bash(git push:*)", List: "allow", Scope: settings.ScopeUser, OverriddenBy: settings.ScopeProject, OverridingList: "deny"},
+				},
+			},
+		}
+
+		output := FormatSummary(report, "")
+
+		if !strings.Contains(output, "[LAYERS] 2 layers (highest precedence first):") {
+			t.Error("レイヤセクションが含まれていない")
+		}
+		if !strings.Contains(output, "/repo/.claude/settings.json") {
+			t.Error("レイヤのパスが含まれていない")
+		}
+		if !strings.Contains(output, "user allow: Bash(git push:*) <- project deny") {
+			t.Errorf("上書きエントリが含まれていない:\n%s", output)
+		}
+	})
+
 	t.Run("10件超で省略表示", func(t *testing.T) {
 		recs := make([]PatternRecommendation, 15)
 		for i := range recs {
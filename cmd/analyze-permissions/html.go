@@ -0,0 +1,120 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"sort"
+)
+
+//go:embed templates/report.html.tmpl
+var templateFS embed.FS
+
+var reportTemplate = template.Must(template.New("report.html.tmpl").
+	Funcs(template.FuncMap{"permission": formatPermission}).
+	ParseFS(templateFS, "templates/report.html.tmpl"))
+
+const (
+	chartHeight   = 180
+	chartBarWidth = 14
+	chartBarGap   = 4
+	chartLabelGap = 7 // ラベルは7本ごとに表示する
+)
+
+// htmlView は HTML テンプレートに渡す表示用データ｡
+type htmlView struct {
+	Report      Report
+	Categories  []categoryBar
+	Daily       []dailyBar
+	ChartWidth  int
+	ChartHeight int
+	Patterns    []patternRow
+}
+
+// categoryBar はカテゴリ別内訳の1行｡
+type categoryBar struct {
+	Name     Category
+	Patterns int
+	Calls    int
+	Percent  int
+}
+
+// dailyBar は日別使用量チャートの1本｡
+type dailyBar struct {
+	Date   string
+	Count  int
+	X      int
+	Y      int
+	Width  int
+	Height int
+	Label  string
+}
+
+// patternRow は全パターン表の1行｡
+type patternRow struct {
+	PatternSummary
+	DenyBypassRisk bool
+}
+
+// RenderHTML はレポートを単一ファイルで完結する HTML として書き出す｡
+func RenderHTML(w io.Writer, r Report) error {
+	return reportTemplate.Execute(w, newHTMLView(r))
+}
+
+func newHTMLView(r Report) htmlView {
+	v := htmlView{Report: r, ChartHeight: chartHeight}
+
+	type catStat struct{ patterns, calls int }
+	stats := make(map[Category]*catStat)
+	totalCalls := 0
+	for _, p := range r.AllPatterns {
+		s, ok := stats[p.Category]
+		if !ok {
+			s = &catStat{}
+			stats[p.Category] = s
+		}
+		s.patterns++
+		s.calls += p.Count
+		totalCalls += p.Count
+
+		v.Patterns = append(v.Patterns, patternRow{
+			PatternSummary: p,
+			DenyBypassRisk: CategorizePermission(p.ToolName, p.Pattern).DenyBypassRisk,
+		})
+	}
+	for name, s := range stats {
+		bar := categoryBar{Name: name, Patterns: s.patterns, Calls: s.calls}
+		if totalCalls > 0 {
+			bar.Percent = s.calls * 100 / totalCalls
+		}
+		v.Categories = append(v.Categories, bar)
+	}
+	sort.Slice(v.Categories, func(i, j int) bool { return v.Categories[i].Calls > v.Categories[j].Calls })
+
+	maxCount := 0
+	for _, d := range r.DailyUsage {
+		maxCount = max(maxCount, d.Count)
+	}
+	plotHeight := chartHeight - 14 // 下端の日付ラベル分
+	for i, d := range r.DailyUsage {
+		h := 0
+		if maxCount > 0 {
+			h = max(d.Count*plotHeight/maxCount, 1)
+		}
+		bar := dailyBar{
+			Date:   d.Date,
+			Count:  d.Count,
+			X:      i * (chartBarWidth + chartBarGap),
+			Y:      plotHeight - h,
+			Width:  chartBarWidth,
+			Height: h,
+		}
+		if i%chartLabelGap == 0 {
+			bar.Label = d.Date[len("2006-"):]
+		}
+		v.Daily = append(v.Daily, bar)
+	}
+	v.ChartWidth = max(len(v.Daily)*(chartBarWidth+chartBarGap), 1)
+
+	return v
+}
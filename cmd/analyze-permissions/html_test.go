@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	report := Report{
+		Metadata:     ReportMetadata{AnalysisDate: "2026-10-19", DaysAnalyzed: 30, FilesScanned: 3, TotalToolCalls: 42},
+		CurrentAllow: []string{"Bash(cat:*)"},
+		CurrentDeny:  []string{"Read(~/.ssh/**)"},
+		Recommendations: Recommendations{
+			Add: []PatternRecommendation{
+				{ToolName: "Bash", Pattern: "go vet", Count: 12, Category: CategorySafe, Reason: "Go ツールチェイン"},
+			},
+			DenyBypassWarnings: []DenyBypassWarning{
+				{AllowEntry: "Bash(cat:*)", BypassedDeny: "Read(~/.ssh/**)", Risk: "ファイル読取 (Read deny バイパス)"},
+			},
+			AllowEncompassesDeny: []AllowEncompassesDeny{
+				{AllowEntry: "Bash(gh:*)", DenyEntry: "Bash(gh auth:*)", Note: "包含"},
+			},
+		},
+		AllPatterns: []PatternSummary{
+			{ToolName: "Bash", Pattern: "cat", Count: 30, Category: CategoryReview, InAllowlist: true},
+			{ToolName: "Bash", Pattern: "go vet", Count: 12, Category: CategorySafe},
+		},
+		DailyUsage: []DailyUsage{
+			{Date: "2026-10-17", Count: 10},
+			{Date: "2026-10-18", Count: 32},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, report); err != nil {
+		t.Fatalf("RenderHTML失敗: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<style>",
+		"<script>",
+		"Tool Calls: 42",
+		`<tr class="danger"><td>deny bypass</td><td><code>Bash(cat:*)</code></td><td><code>Read(~/.ssh/**)</code></td>`,
+		`<tr class="warn"><td>allow encompasses deny</td>`,
+		"<code>Bash(go vet:*)</code>",
+		`<tr class="danger" title="deny バイパスリスク"><td>Bash</td><td><code>cat</code></td>`,
+		"<title>2026-10-18: 32 calls</title>",
+		`class="sortable"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれていない", want)
+		}
+	}
+
+	if strings.Contains(out, "<link") || strings.Contains(out, "src=\"http") {
+		t.Error("外部リソースを参照してはいけない")
+	}
+}
+
+func TestNewHTMLView(t *testing.T) {
+	report := Report{
+		AllPatterns: []PatternSummary{
+			{ToolName: "Bash", Pattern: "git status", Count: 75, Category: CategorySafe},
+			{ToolName: "Bash", Pattern: "curl", Count: 25, Category: CategoryDeny},
+		},
+		DailyUsage: []DailyUsage{{Date: "2026-10-01", Count: 5}, {Date: "2026-10-02", Count: 10}},
+	}
+
+	v := newHTMLView(report)
+
+	if len(v.Categories) != 2 || v.Categories[0].Name != CategorySafe || v.Categories[0].Percent != 75 {
+		t.Errorf("Categories: got %+v", v.Categories)
+	}
+	if len(v.Daily) != 2 {
+		t.Fatalf("Daily: got %d, want 2", len(v.Daily))
+	}
+	if v.Daily[1].Height <= v.Daily[0].Height {
+		t.Errorf("多い日のバーが高くなるべき: %+v", v.Daily)
+	}
+	if v.Daily[0].Label != "10-01" || v.Daily[1].Label != "" {
+		t.Errorf("Label: got %q, %q", v.Daily[0].Label, v.Daily[1].Label)
+	}
+}
+
+func TestRenderHTMLEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, Report{}); err != nil {
+		t.Fatalf("空のレポートでも失敗しないべき: %v", err)
+	}
+	if strings.Contains(buf.String(), "<h2>Warnings</h2>") {
+		t.Error("空なのに警告セクションが含まれている")
+	}
+}
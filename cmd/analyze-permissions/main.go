@@ -11,6 +11,8 @@ import (
 
 	"github.com/usadamasa/claude-config/internal/jsonlscan"
 	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
+	"github.com/usadamasa/claude-config/internal/settings"
 )
 
 // Report はレポートの最上位構造｡
@@ -21,6 +23,30 @@ type Report struct {
 	CurrentAsk      []string         `json:"current_ask"`
 	Recommendations Recommendations  `json:"recommendations"`
 	AllPatterns     []PatternSummary `json:"all_patterns"`
+	DailyUsage      []DailyUsage     `json:"daily_usage,omitempty"`
+	Layers          *LayerReport     `json:"layers,omitempty"`
+}
+
+// DailyUsage は日別のツール呼び出し数｡
+type DailyUsage struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+// LayerReport は settings レイヤごとの出所と上書き状況を表す｡
+type LayerReport struct {
+	Sources   []LayerSummary      `json:"sources"`
+	Rules     []settings.Rule     `json:"rules"`
+	Overrides []settings.Override `json:"overrides,omitempty"`
+}
+
+// LayerSummary は1レイヤ分の概要｡
+type LayerSummary struct {
+	Scope settings.Scope `json:"scope"`
+	Path  string         `json:"path"`
+	Allow int            `json:"allow"`
+	Deny  int            `json:"deny"`
+	Ask   int            `json:"ask"`
 }
 
 // ReportMetadata は分析の概要統計を保持する｡
@@ -50,9 +76,13 @@ type AllowEncompassesDeny struct {
 
 // DenyBypassWarning は allow の Bash コマンドが deny の Read/Write をバイパスするリスク｡
 type DenyBypassWarning struct {
-	AllowEntry   string `json:"allow_entry"`
-	BypassedDeny string `json:"bypassed_deny"`
-	Risk         string `json:"risk"`
+	AllowEntry string `json:"allow_entry,omitempty"`
+	// AllowEntries はリダイレクトのように多数の allow エントリに該当する手口を deny ごとに集約した場合の allow エントリ｡
+	AllowEntries []string `json:"allow_entries,omitempty"`
+	BypassedDeny string   `json:"bypassed_deny"`
+	Risk         string   `json:"risk"`
+	Technique    string   `json:"technique,omitempty"`
+	Example      string   `json:"example,omitempty"`
 }
 
 // PatternRecommendation は追加または確認が推奨されるパターン｡
@@ -62,6 +92,7 @@ type PatternRecommendation struct {
 	Count    int      `json:"count"`
 	Category Category `json:"category"`
 	Reason   string   `json:"reason"`
+	Projects []string `json:"projects,omitempty"`
 }
 
 // UnusedEntry はパーミッションリストにあるが使用されていないエントリ｡
@@ -80,18 +111,44 @@ type PatternSummary struct {
 	InAllowlist bool     `json:"in_allowlist"`
 	InDenylist  bool     `json:"in_denylist"`
 	InAsklist   bool     `json:"in_asklist"`
+	// Projects はパターンを使用したプロジェクト｡1つだけならプロジェクトの設定への追加を検討する｡
+	Projects []string `json:"projects,omitempty"`
 }
 
-// GenerateReport はスキャン結果と現在のパーミッション設定からレポートを生成する｡
-func GenerateReport(scanResults []ScanResult, allow, deny, ask []string, days, filesScanned int) Report {
-	type patternKey struct {
-		toolName string
-		pattern  string
-	}
+// patternKey はツール名とパターンの組｡
+type patternKey struct {
+	toolName string
+	pattern  string
+}
+
+// countPatterns はパターンごとの使用回数と、使用したプロジェクト名(昇順)を集計する｡
+func countPatterns(scanResults []ScanResult) (map[patternKey]int, map[patternKey][]string) {
 	counts := make(map[patternKey]int)
+	projectSets := make(map[patternKey]map[string]bool)
 	for _, r := range scanResults {
-		counts[patternKey{r.ToolName, r.Pattern}]++
+		key := patternKey{r.ToolName, r.Pattern}
+		counts[key]++
+		if r.Project == "" {
+			continue
+		}
+		if projectSets[key] == nil {
+			projectSets[key] = make(map[string]bool)
+		}
+		projectSets[key][r.Project] = true
+	}
+	projects := make(map[patternKey][]string, len(projectSets))
+	for key, set := range projectSets {
+		for name := range set {
+			projects[key] = append(projects[key], name)
+		}
+		sort.Strings(projects[key])
 	}
+	return counts, projects
+}
+
+// GenerateReport はスキャン結果と現在のパーミッション設定からレポートを生成する｡
+func GenerateReport(scanResults []ScanResult, allow, deny, ask []string, days, filesScanned int) Report {
+	counts, projects := countPatterns(scanResults)
 
 	var bareWarnings []string
 	for _, lists := range [][]string{allow, deny, ask} {
@@ -121,6 +178,7 @@ func GenerateReport(scanResults []ScanResult, allow, deny, ask []string, days, f
 			InAllowlist: inAllow,
 			InDenylist:  inDeny,
 			InAsklist:   inAsk,
+			Projects:    projects[key],
 		})
 
 		if !inAllow && !inDeny && !inAsk {
@@ -130,6 +188,7 @@ func GenerateReport(scanResults []ScanResult, allow, deny, ask []string, days, f
 				Count:    count,
 				Category: cat.Category,
 				Reason:   cat.Reason,
+				Projects: projects[key],
 			}
 			switch cat.Category {
 			case CategorySafe:
@@ -174,7 +233,7 @@ func GenerateReport(scanResults []ScanResult, allow, deny, ask []string, days, f
 	checkUnused(deny, "deny")
 	checkUnused(ask, "ask")
 
-	denyBypassWarnings := detectDenyBypassWarnings(allow, deny)
+	denyBypassWarnings := aggregateRedirectWarnings(detectDenyBypassWarnings(allow, deny))
 	allowEncompassesDeny := detectAllowEncompassesDeny(allow, deny)
 
 	sort.Slice(allPatterns, func(i, j int) bool { return allPatterns[i].Count > allPatterns[j].Count })
@@ -200,46 +259,64 @@ func GenerateReport(scanResults []ScanResult, allow, deny, ask []string, days, f
 			AllowEncompassesDeny: allowEncompassesDeny,
 		},
 		AllPatterns: allPatterns,
+		DailyUsage:  aggregateDailyUsage(scanResults),
 	}
 }
 
-// getDenyBypassType は Bash コマンドパターンの deny バイパスタイプを返す｡
-func getDenyBypassType(pattern string) string {
-	for _, p := range bashDenyBypassPatterns {
-		if p.match(pattern) {
-			return p.bypass
-		}
-	}
-	return ""
-}
-
-func detectDenyBypassWarnings(allow, deny []string) []DenyBypassWarning {
-	var warnings []DenyBypassWarning
-	for _, allowEntry := range allow {
-		tool, pattern, ok := ParsePermissionEntry(allowEntry)
-		if !ok || tool != "Bash" {
+// aggregateDailyUsage はタイムスタンプを持つスキャン結果を日別に集計し、日付昇順で返す｡
+// チャートの横軸が日付と対応するよう、最初と最後の日の間で呼び出しのない日も Count 0 で含める｡
+func aggregateDailyUsage(scanResults []ScanResult) []DailyUsage {
+	counts := make(map[string]int)
+	var first, last time.Time
+	for _, r := range scanResults {
+		if r.Timestamp.IsZero() {
 			continue
 		}
-		cat := CategorizePermission("Bash", pattern)
-		if !cat.DenyBypassRisk {
-			continue
+		t := r.Timestamp.Local()
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+		if first.IsZero() || day.Before(first) {
+			first = day
 		}
-		bypassType := getDenyBypassType(pattern)
-		for _, denyEntry := range deny {
-			denyTool, _, denyOk := ParsePermissionEntry(denyEntry)
-			if !denyOk {
-				continue
-			}
-			if matchesBypassType(bypassType, denyTool) {
-				warnings = append(warnings, DenyBypassWarning{
-					AllowEntry:   allowEntry,
-					BypassedDeny: denyEntry,
-					Risk:         cat.Reason,
-				})
-			}
+		if day.After(last) {
+			last = day
 		}
+		counts[day.Format("2006-01-02")]++
 	}
-	return warnings
+	if first.IsZero() {
+		return nil
+	}
+
+	var daily []DailyUsage
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		daily = append(daily, DailyUsage{Date: date, Count: counts[date]})
+	}
+	return daily
+}
+
+// NewLayerReport はマージ結果からレイヤレポートを生成する｡
+// Rules は deny, ask, allow の順に並ぶ｡
+func NewLayerReport(eff *settings.Effective) *LayerReport {
+	lr := &LayerReport{Overrides: eff.Overrides}
+	for _, l := range eff.Layers {
+		lr.Sources = append(lr.Sources, LayerSummary{
+			Scope: l.Scope,
+			Path:  l.Path,
+			Allow: len(l.Settings.Permissions.Allow),
+			Deny:  len(l.Settings.Permissions.Deny),
+			Ask:   len(l.Settings.Permissions.Ask),
+		})
+	}
+	lr.Rules = append(lr.Rules, eff.Deny...)
+	lr.Rules = append(lr.Rules, eff.Ask...)
+	lr.Rules = append(lr.Rules, eff.Allow...)
+	return lr
+}
+
+// detectDenyBypassWarnings は allow の Bash エントリによる deny バイパスを検出する｡
+// 手口の詳細は AnalyzeDenyBypass を参照｡
+func detectDenyBypassWarnings(allow, deny []string) []DenyBypassWarning {
+	return AnalyzeDenyBypass(allow, deny)
 }
 
 func detectAllowEncompassesDeny(allow, deny []string) []AllowEncompassesDeny {
@@ -274,9 +351,9 @@ func matchesBypassType(bypassType, denyTool string) bool {
 	case "read":
 		return denyTool == "Read"
 	case "write":
-		return denyTool == "Write"
+		return denyTool == "Write" || denyTool == "Edit"
 	case "both":
-		return denyTool == "Read" || denyTool == "Write"
+		return denyTool == "Read" || denyTool == "Write" || denyTool == "Edit"
 	default:
 		return false
 	}
@@ -296,11 +373,88 @@ func matchPattern(scanPattern, permPattern string) bool {
 	return false
 }
 
+// resolveUserSettingsPath は user レイヤの settings.json パスを決定する｡
+// フラグ未指定時は git ルートの dotclaude/settings.json または ~/.claude/settings.json を返す｡
+func resolveUserSettingsPath(flagPath, cwd, home string) (string, error) {
+	if flagPath != "" {
+		return flagPath, nil
+	}
+	return pathutil.ResolveSettingsPath(cwd, home)
+}
+
+// resolveLayerSources は settings の読み込み元レイヤを決定する｡
+// layered が false の場合は settingsPath の1ファイルのみを user レイヤとして扱う｡
+// --settings で明示したファイルと layered でない場合のファイルは、存在しなければ読み込み時にエラーとする｡
+func resolveLayerSources(settingsPath string, layered bool) ([]settings.LayerSource, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("ホームディレクトリの取得に失敗: %w", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("カレントディレクトリの取得に失敗: %w", err)
+	}
+
+	explicit := settingsPath != ""
+	settingsPath, err = resolveUserSettingsPath(settingsPath, cwd, home)
+	if err != nil {
+		return nil, err
+	}
+
+	if !layered {
+		return []settings.LayerSource{{Scope: settings.ScopeUser, Path: settingsPath, Required: true}}, nil
+	}
+
+	projectRoot, _ := pathutil.FindGitRoot(cwd)
+	return settings.DefaultLayerSources(settingsPath, projectRoot, explicit), nil
+}
+
+// loadEffectiveSettings は settings の読み込み元レイヤを決定し、マージした有効なパーミッションを返す｡
+func loadEffectiveSettings(settingsPath string, layered bool) (*settings.Effective, error) {
+	sources, err := resolveLayerSources(settingsPath, layered)
+	if err != nil {
+		return nil, err
+	}
+	eff, err := LoadEffectivePermissions(sources)
+	if err != nil {
+		return nil, fmt.Errorf("settings の読み込みに失敗: %w", err)
+	}
+	return eff, nil
+}
+
+// writeReportFile はレポートのフル JSON を path に書き出す｡
+func writeReportFile(path string, report Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("出力ファイルの作成に失敗: %w", err)
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("JSON の書き出しに失敗: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("出力ファイルのクローズに失敗: %w", err)
+	}
+	return nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout, os.Stderr))
+		case "test":
+			os.Exit(runPolicyTest(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	days := flag.Int("days", 30, "集計期間(日数)")
-	settingsPath := flag.String("settings", "", "settings.json パス (デフォルト: git ルートの settings.json または ~/.claude/settings.json)")
+	settingsPath := flag.String("settings", "", "user レイヤの settings.json パス (デフォルト: git ルートの settings.json または ~/.claude/settings.json)")
+	layered := flag.Bool("layered", true, "managed/project/local レイヤをマージして評価する (false で --settings の1ファイルのみ)")
 	projectsDirFlag := flag.String("projects-dir", "", "projects ディレクトリパス (デフォルト: ~/.claude/projects)")
-	format := flag.String("format", "summary", "出力形式: summary (テキストサマリ) または json (フル JSON)")
+	format := flag.String("format", "summary", "出力形式: summary (テキストサマリ), json (フル JSON) または html (単一ファイル HTML)")
 	outputPath := flag.String("output", "", "フル JSON の出力先ファイルパス (summary 形式と併用可)")
 	flag.Parse()
 
@@ -310,29 +464,23 @@ func main() {
 		os.Exit(1)
 	}
 
-	if *settingsPath == "" {
-		cwd, err := os.Getwd()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "カレントディレクトリの取得に失敗: %v\n", err)
-			os.Exit(1)
-		}
-		resolved, err := pathutil.ResolveSettingsPath(cwd, home)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v\n", err)
-			os.Exit(1)
-		}
-		*settingsPath = resolved
+	eff, err := loadEffectiveSettings(*settingsPath, *layered)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 
 	projectsDir := pathutil.ResolveProjectsDir(*projectsDirFlag, home)
+	allow := eff.Entries(settings.ListAllow)
+	deny := eff.Entries(settings.ListDeny)
+	ask := eff.Entries(settings.ListAsk)
 
-	allow, deny, ask, err := LoadPermissions(*settingsPath)
+	resolver, err := project.NewDefaultResolver(home)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "settings.json の読み込みに失敗 (%s): %v\n", *settingsPath, err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-
-	scanResults, err := ScanJSONLFiles(projectsDir, *days)
+	scanResults, err := ScanJSONLFilesWithResolver(projectsDir, *days, resolver)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "JSONL ファイルの走査に失敗: %v\n", err)
 		os.Exit(1)
@@ -341,22 +489,11 @@ func main() {
 	filesScanned := jsonlscan.CountUniqueFiles(scanResults, func(r ScanResult) string { return r.FilePath })
 
 	report := GenerateReport(scanResults, allow, deny, ask, *days, filesScanned)
+	report.Layers = NewLayerReport(eff)
 
 	if *outputPath != "" {
-		f, err := os.Create(*outputPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "出力ファイルの作成に失敗: %v\n", err)
-			os.Exit(1)
-		}
-		encoder := json.NewEncoder(f)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(report); err != nil {
-			_ = f.Close()
-			fmt.Fprintf(os.Stderr, "JSON の書き出しに失敗: %v\n", err)
-			os.Exit(1)
-		}
-		if err := f.Close(); err != nil {
-			fmt.Fprintf(os.Stderr, "出力ファイルのクローズに失敗: %v\n", err)
+		if err := writeReportFile(*outputPath, report); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(1)
 		}
 	}
@@ -371,8 +508,13 @@ func main() {
 		}
 	case "summary":
 		fmt.Print(FormatSummary(report, *outputPath))
+	case "html":
+		if err := RenderHTML(os.Stdout, report); err != nil {
+			fmt.Fprintf(os.Stderr, "HTML の出力に失敗: %v\n", err)
+			os.Exit(1)
+		}
 	default:
-		fmt.Fprintf(os.Stderr, "不明な出力形式: %s (summary または json を指定)\n", *format)
+		fmt.Fprintf(os.Stderr, "不明な出力形式: %s (summary, json または html を指定)\n", *format)
 		os.Exit(1)
 	}
 }
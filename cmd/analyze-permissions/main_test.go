@@ -2,10 +2,15 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/usadamasa/claude-config/internal/jsonlscan"
 	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/settings"
 )
 
 func TestGenerateReport(t *testing.T) {
@@ -136,6 +141,62 @@ func TestGenerateReport(t *testing.T) {
 	})
 }
 
+func TestAggregateDailyUsage(t *testing.T) {
+	day1 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	day4 := day1.AddDate(0, 0, 3)
+	scanResults := []ScanResult{
+		{ToolName: "Bash", Pattern: "git status", Timestamp: day2},
+		{ToolName: "Bash", Pattern: "git status", Timestamp: day1},
+		{ToolName: "Bash", Pattern: "go test", Timestamp: day2},
+		{ToolName: "Bash", Pattern: "go vet", Timestamp: day4},
+		{ToolName: "Bash", Pattern: "ls"},
+	}
+
+	got := aggregateDailyUsage(scanResults)
+
+	// 呼び出しのない 10-03 も 0 件として含める
+	want := []DailyUsage{
+		{Date: "2026-10-01", Count: 1},
+		{Date: "2026-10-02", Count: 2},
+		{Date: "2026-10-03", Count: 0},
+		{Date: "2026-10-04", Count: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("[%d] got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNewLayerReport(t *testing.T) {
+	s := &settings.Settings{}
+	s.Permissions.Allow = []string{"Bash(go test:*)", "Bash(ls:*)"}
+	s.Permissions.Deny = []string{"Bash(curl:*)"}
+	eff := settings.Merge([]settings.Layer{{Scope: settings.ScopeUser, Path: "/u/settings.json", Settings: s}})
+
+	lr := NewLayerReport(eff)
+
+	if len(lr.Sources) != 1 {
+		t.Fatalf("Sources: got %d, want 1", len(lr.Sources))
+	}
+	if lr.Sources[0].Allow != 2 || lr.Sources[0].Deny != 1 || lr.Sources[0].Ask != 0 {
+		t.Errorf("Sources[0]: got %+v", lr.Sources[0])
+	}
+	if len(lr.Rules) != 3 {
+		t.Fatalf("Rules: got %d, want 3", len(lr.Rules))
+	}
+	if lr.Rules[0].List != settings.ListDeny {
+		t.Errorf("Rules[0].List: got %s, want deny (deny が先頭)", lr.Rules[0].List)
+	}
+	if lr.Rules[1].Scope != settings.ScopeUser || lr.Rules[1].Path != "/u/settings.json" {
+		t.Errorf("Rules[1]: got %+v, want user provenance", lr.Rules[1])
+	}
+}
+
 func TestResolveProjectsDir(t *testing.T) {
 	t.Run("--projects-dir 指定時はそのパスを使う", func(t *testing.T) {
 		got := pathutil.ResolveProjectsDir("/custom/projects", "/home/user")
@@ -154,6 +215,34 @@ func TestResolveProjectsDir(t *testing.T) {
 	})
 }
 
+func TestResolveLayerSources(t *testing.T) {
+	t.Run("--settings で明示したファイルは存在しなければエラーになる", func(t *testing.T) {
+		sources, err := resolveLayerSources(filepath.Join(t.TempDir(), "typo.json"), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		user := sources[len(sources)-1]
+		if user.Scope != settings.ScopeUser || !user.Required {
+			t.Fatalf("user レイヤは Required であるべき: %+v", user)
+		}
+		if _, err := LoadEffectivePermissions(sources); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got %v, want os.ErrNotExist", err)
+		}
+	})
+
+	t.Run("暗黙のレイヤは Required にしない", func(t *testing.T) {
+		sources, err := resolveLayerSources(filepath.Join(t.TempDir(), "settings.json"), true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, src := range sources[:len(sources)-1] {
+			if src.Required {
+				t.Errorf("%s レイヤは Required であるべきでない", src.Scope)
+			}
+		}
+	})
+}
+
 func TestMatchPattern(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/usadamasa/claude-config/internal/settings"
+)
+
+// policyFileName は settings.json と同じディレクトリに置くポリシーテストファイル名｡
+const policyFileName = "settings.policy"
+
+// PolicyCase はポリシーテストファイルの1ケース｡
+type PolicyCase struct {
+	Line     int
+	Call     ToolCall
+	Expected Decision
+}
+
+// PolicyResult はポリシーテスト1ケースの実行結果｡
+type PolicyResult struct {
+	Case   PolicyCase
+	Result CheckResult
+}
+
+// Passed は期待値と評価結果が一致したかを返す｡
+func (r PolicyResult) Passed() bool {
+	return r.Result.Decision == r.Case.Expected
+}
+
+// ParsePolicy はポリシーテストファイルを読み込む｡
+// 書式は1行1ケースの "<ツール呼び出し> => <allow|ask|deny|none>"｡
+// 空行と # で始まる行は無視する｡
+func ParsePolicy(r io.Reader) ([]PolicyCase, error) {
+	var cases []PolicyCase
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		idx := strings.LastIndex(line, "=>")
+		if idx < 0 {
+			return nil, fmt.Errorf("%d行目: \"=>\" がありません: %s", lineNo, line)
+		}
+		call, err := ParseToolCall(line[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %w", lineNo, err)
+		}
+		expected := Decision(strings.TrimSpace(line[idx+2:]))
+		if _, ok := decisionStrength[expected]; !ok {
+			return nil, fmt.Errorf("%d行目: 不明な判定 %q (allow, ask, deny, none のいずれか)", lineNo, expected)
+		}
+		cases = append(cases, PolicyCase{Line: lineNo, Call: call, Expected: expected})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cases, nil
+}
+
+// RunPolicyTests は全ケースを有効なパーミッションに対して評価する｡
+func RunPolicyTests(cases []PolicyCase, eff *settings.Effective, env MatchEnv) []PolicyResult {
+	results := make([]PolicyResult, 0, len(cases))
+	for _, c := range cases {
+		results = append(results, PolicyResult{Case: c, Result: EvaluateToolCall(c.Call, eff, env)})
+	}
+	return results
+}
+
+// FormatPolicyResults はテスト結果をテキストに整形する｡verbose なら成功ケースも出力する｡
+func FormatPolicyResults(results []PolicyResult, policyPath string, verbose bool) string {
+	var b strings.Builder
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			if verbose {
+				fmt.Fprintf(&b, "PASS %s => %s\n", r.Case.Call, r.Case.Expected)
+			}
+			continue
+		}
+		failed++
+		matched := "no matching rule"
+		if r.Result.Matched != nil {
+			matched = fmt.Sprintf("matched %s [%s]", r.Result.Matched.Entry, r.Result.Matched.Scope)
+		}
+		fmt.Fprintf(&b, "FAIL %s:%d: %s => %s (got %s, %s)\n",
+			policyPath, r.Case.Line, r.Case.Call, r.Case.Expected, r.Result.Decision, matched)
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed\n", len(results)-failed, failed)
+	return b.String()
+}
+
+// runPolicyTest は test サブコマンドを実行し、失敗があれば終了コード1を返す｡
+func runPolicyTest(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	settingsPath := fs.String("settings", "", "テスト対象の settings.json パス (デフォルト: git ルートの settings.json または ~/.claude/settings.json)")
+	layered := fs.Bool("layered", true, "managed/project/local レイヤをマージして評価する")
+	policyPath := fs.String("policy", "", "ポリシーテストファイル (デフォルト: settings.json と同じディレクトリの "+policyFileName+")")
+	verbose := fs.Bool("v", false, "成功したケースも出力する")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	sources, err := resolveLayerSources(*settingsPath, *layered)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	if *policyPath == "" {
+		user := sources[len(sources)-1]
+		*policyPath = filepath.Join(filepath.Dir(user.Path), policyFileName)
+	}
+
+	f, err := os.Open(*policyPath) // #nosec G304 -- CLIツール: パスはフラグ引数由来
+	if err != nil {
+		fmt.Fprintf(stderr, "ポリシーファイルの読み込みに失敗: %v\n", err)
+		return 1
+	}
+	cases, err := ParsePolicy(f)
+	_ = f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "ポリシーファイルのパースに失敗 (%s): %v\n", *policyPath, err)
+		return 1
+	}
+
+	eff, err := LoadEffectivePermissions(sources)
+	if err != nil {
+		fmt.Fprintf(stderr, "settings の読み込みに失敗: %v\n", err)
+		return 1
+	}
+	home, _ := os.UserHomeDir()
+	cwd, _ := os.Getwd()
+
+	results := RunPolicyTests(cases, eff, MatchEnv{Home: home, Cwd: cwd})
+	fmt.Fprint(stdout, FormatPolicyResults(results, *policyPath, *verbose))
+
+	for _, r := range results {
+		if !r.Passed() {
+			return 1
+		}
+	}
+	return 0
+}
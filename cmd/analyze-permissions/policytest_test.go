@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	t.Run("コメントと空行を無視してパースする", func(t *testing.T) {
+		input := `# comment
+
+Bash(rm -rf /) => deny
+Read(~/.ssh/id_rsa)=>deny
+Bash(echo "a => b") => allow
+`
+		cases, err := ParsePolicy(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cases) != 3 {
+			t.Fatalf("got %d cases, want 3", len(cases))
+		}
+		if cases[0].Line != 3 || cases[0].Call.Arg != "rm -rf /" || cases[0].Expected != DecisionDeny {
+			t.Errorf("cases[0]: got %+v", cases[0])
+		}
+		if cases[1].Call.Tool != "Read" || cases[1].Expected != DecisionDeny {
+			t.Errorf("cases[1]: got %+v", cases[1])
+		}
+		if cases[2].Call.Arg != `echo "a => b"` {
+			t.Errorf("cases[2]: 最後の => で分割されるべき: got %+v", cases[2])
+		}
+	})
+
+	t.Run("=> がない行はエラー", func(t *testing.T) {
+		_, err := ParsePolicy(strings.NewReader("Bash(ls)\n"))
+		if err == nil || !strings.Contains(err.Error(), "1行目") {
+			t.Errorf("行番号付きエラーが期待される: %v", err)
+		}
+	})
+
+	t.Run("不明な判定はエラー", func(t *testing.T) {
+		if _, err := ParsePolicy(strings.NewReader("Bash(ls) => maybe\n")); err == nil {
+			t.Error("エラーが期待される")
+		}
+	})
+}
+
+func TestRunPolicyTests(t *testing.T) {
+	eff := newTestEffective(
+		[]string{"Bash(go test:*)", "Bash(rm:*)"},
+		[]string{"Read(~/.ssh/**)"},
+		nil,
+	)
+	cases := []PolicyCase{
+		{Line: 1, Call: ToolCall{Tool: "Bash", Arg: "go test ./..."}, Expected: DecisionAllow},
+		{Line: 2, Call: ToolCall{Tool: "Read", Arg: "~/.ssh/id_rsa"}, Expected: DecisionDeny},
+		{Line: 3, Call: ToolCall{Tool: "Bash", Arg: "rm -rf /"}, Expected: DecisionDeny},
+	}
+
+	results := RunPolicyTests(cases, eff, MatchEnv{Home: "/home/u"})
+
+	if !results[0].Passed() || !results[1].Passed() {
+		t.Errorf("1, 2 件目は成功するべき: %+v", results[:2])
+	}
+	if results[2].Passed() {
+		t.Error("3 件目は失敗するべき (allow が広すぎる)")
+	}
+
+	output := FormatPolicyResults(results, "settings.policy", false)
+	if !strings.Contains(output, "FAIL settings.policy:3: Bash(rm -rf /) => deny (got allow, matched Bash(rm:*) [user])") {
+		t.Errorf("失敗ケースの出力が正しくない:\n%s", output)
+	}
+	if strings.Contains(output, "PASS") {
+		t.Error("verbose でないのに成功ケースが出力されている")
+	}
+	if !strings.Contains(output, "2 passed, 1 failed") {
+		t.Errorf("集計行が正しくない:\n%s", output)
+	}
+}
+
+func TestRunPolicyTest(t *testing.T) {
+	dir := t.TempDir()
+	settingsPath := writeTestFile(t, dir, "settings.json", `{"permissions":{"allow":["Bash(go test:*)"],"deny":["Bash(curl:*)"]}}`)
+
+	t.Run("settings.json と同じディレクトリのポリシーを使う", func(t *testing.T) {
+		writeTestFile(t, dir, policyFileName, "Bash(go test ./...) => allow\nBash(curl x) => deny\n")
+		var stdout, stderr bytes.Buffer
+		if code := runPolicyTest([]string{"--settings", settingsPath, "--layered=false"}, &stdout, &stderr); code != 0 {
+			t.Fatalf("exit code: got %d, stdout: %s stderr: %s", code, stdout.String(), stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "2 passed, 0 failed") {
+			t.Errorf("got:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("失敗があれば終了コード1", func(t *testing.T) {
+		policy := writeTestFile(t, dir, "widened.policy", "Bash(go build ./...) => deny\n")
+		var stdout, stderr bytes.Buffer
+		if code := runPolicyTest([]string{"--settings", settingsPath, "--layered=false", "--policy", policy}, &stdout, &stderr); code != 1 {
+			t.Errorf("exit code: got %d, want 1", code)
+		}
+	})
+
+	t.Run("ポリシーファイルがなければ終了コード1", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runPolicyTest([]string{"--settings", settingsPath, "--layered=false", "--policy", filepath.Join(dir, "missing")}, &stdout, &stderr); code != 1 {
+			t.Errorf("exit code: got %d, want 1", code)
+		}
+	})
+}
@@ -5,15 +5,21 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/usadamasa/claude-config/internal/jsonlscan"
+	"github.com/usadamasa/claude-config/internal/project"
 )
 
 // ScanResult はセッションログから抽出されたツール使用情報を表す｡
 type ScanResult struct {
-	ToolName string
-	Pattern  string
-	FilePath string
+	ToolName  string
+	Pattern   string
+	FilePath  string
+	Timestamp time.Time
+	// Project は呼び出し時の cwd から解決したプロジェクト名｡
+	Project   string
+	ProjectID string
 }
 
 // bashInput は Bash tool_use の入力フィールド｡
@@ -29,10 +35,16 @@ type fileInput struct {
 // ScanJSONLFiles は指定ディレクトリの JSONL ファイルから Bash/Read/Write/Edit
 // の tool_use エントリを抽出する｡
 func ScanJSONLFiles(projectsDir string, days int) ([]ScanResult, error) {
+	return ScanJSONLFilesWithResolver(projectsDir, days, project.NewResolver(nil))
+}
+
+// ScanJSONLFilesWithResolver は resolver でプロジェクトを解決して ScanJSONLFiles と同じ抽出を行う｡
+// プロジェクト名は全ファイルの走査後に、同名のプロジェクトを区別して割り当てる｡
+func ScanJSONLFilesWithResolver(projectsDir string, days int, resolver *project.Resolver) ([]ScanResult, error) {
 	var results []ScanResult
 
 	err := jsonlscan.WalkJSONLFiles(projectsDir, jsonlscan.WalkOptions{Days: days}, func(path string) error {
-		fileResults, err := scanSingleFile(path)
+		fileResults, err := scanSingleFile(path, resolver)
 		if err != nil {
 			return nil
 		}
@@ -42,11 +54,14 @@ func ScanJSONLFiles(projectsDir string, days int) ([]ScanResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	for i := range results {
+		results[i].Project = resolver.Name(results[i].ProjectID)
+	}
 	return results, nil
 }
 
 // scanSingleFile は JSONL ファイルを1行ずつ読み取り、対象ツールのエントリを抽出する｡
-func scanSingleFile(path string) ([]ScanResult, error) {
+func scanSingleFile(path string, resolver *project.Resolver) ([]ScanResult, error) {
 	f, err := os.Open(path) // #nosec G304 -- CLIツール: パスはWalkDir由来
 	if err != nil {
 		return nil, err
@@ -67,6 +82,8 @@ func scanSingleFile(path string) ([]ScanResult, error) {
 			continue
 		}
 
+		ts, _ := jsonlscan.ParseTimestamp(entry.Timestamp)
+
 		for _, block := range entry.Message.Content {
 			if block.Type != "tool_use" {
 				continue
@@ -76,8 +93,10 @@ func scanSingleFile(path string) ([]ScanResult, error) {
 			}
 
 			result := ScanResult{
-				ToolName: block.Name,
-				FilePath: path,
+				ToolName:  block.Name,
+				FilePath:  path,
+				Timestamp: ts,
+				ProjectID: resolver.Resolve(entry.CWD, entry.GitBranch).ID,
 			}
 
 			switch block.Name {
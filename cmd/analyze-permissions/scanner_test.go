@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -62,6 +63,50 @@ func TestScanJSONLFiles(t *testing.T) {
 		}
 	})
 
+	t.Run("cwd からプロジェクトを解決する", func(t *testing.T) {
+		dir := t.TempDir()
+		line := func(cwd string) string {
+			return `{"type":"assistant","cwd":"` + cwd + `","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"git status"}}]}}` + "\n"
+		}
+		writeTestFile(t, dir, "session.jsonl",
+			line("/nonexistent/src/a/api")+line("/nonexistent/src/b/api")+line("/nonexistent/src/a/api"))
+
+		results, err := ScanJSONLFiles(dir, 30)
+		if err != nil {
+			t.Fatalf("エラーが発生: %v", err)
+		}
+		if len(results) != 3 || results[0].Project != "a/api" || results[1].Project != "b/api" {
+			t.Fatalf("results = %+v", results)
+		}
+
+		report := GenerateReport(results, nil, nil, nil, 30, 1)
+		if len(report.AllPatterns) != 1 || strings.Join(report.AllPatterns[0].Projects, ",") != "a/api,b/api" {
+			t.Errorf("AllPatterns = %+v", report.AllPatterns)
+		}
+	})
+
+	t.Run("timestamp を取り込む", func(t *testing.T) {
+		dir := t.TempDir()
+		jsonlContent := `{"type":"assistant","timestamp":"2026-10-01T09:00:00.000Z","message":{"role":"assistant","content":[{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}` + "\n" +
+			makeBashLine("pwd") + "\n"
+		writeTestFile(t, dir, "session.jsonl", jsonlContent)
+
+		results, err := ScanJSONLFiles(dir, 30)
+		if err != nil {
+			t.Fatalf("エラーが発生: %v", err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("結果数: got %d, want 2", len(results))
+		}
+		want := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+		if !results[0].Timestamp.Equal(want) {
+			t.Errorf("Timestamp: got %v, want %v", results[0].Timestamp, want)
+		}
+		if !results[1].Timestamp.IsZero() {
+			t.Errorf("timestamp なしはゼロ値: got %v", results[1].Timestamp)
+		}
+	})
+
 	t.Run("Read tool_use を抽出する", func(t *testing.T) {
 		dir := t.TempDir()
 		jsonlContent := makeReadLine("/Users/testuser/project/src/main.go") + "\n"
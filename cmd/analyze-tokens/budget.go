@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+// exitBudgetExceeded は budget サブコマンドで予算超過があった場合の終了コード｡
+// 実行時エラー(1)や使い方エラー(2)と区別する｡
+const exitBudgetExceeded = 3
+
+// allProjects は全プロジェクト合計に対する予算を表すプロジェクト名｡
+const allProjects = "*"
+
+// Budget はプロジェクト単位の日次・週次の予算｡
+// Tokens は実効コンテキスト(input + cache read + cache creation)と output の合計に対する上限｡
+type Budget struct {
+	// Project はレポートのプロジェクト名(別名を含む)｡"*" は全プロジェクト合計｡
+	Project string  `json:"project"`
+	Period  string  `json:"period"`
+	Tokens  int64   `json:"tokens,omitempty"`
+	CostUSD float64 `json:"cost_usd,omitempty"`
+}
+
+// validate は予算の定義を検証する｡
+func (b Budget) validate() error {
+	if b.Project == "" {
+		return fmt.Errorf("project が指定されていません (全プロジェクト合計は %q)", allProjects)
+	}
+	if b.Period != GroupByDay && b.Period != GroupByWeek {
+		return fmt.Errorf("不明な period: %q (day または week)", b.Period)
+	}
+	if b.Tokens <= 0 && b.CostUSD <= 0 {
+		return fmt.Errorf("tokens か cost_usd のどちらかを指定してください")
+	}
+	return nil
+}
+
+// matches は予算がプロジェクトに適用されるかを返す｡
+func (b Budget) matches(project string) bool {
+	return b.Project == allProjects || b.Project == project
+}
+
+// BudgetStatus は予算1つ分の消費状況｡Ratio は上限に対する消費割合｡
+type BudgetStatus struct {
+	Project     string  `json:"project"`
+	Period      string  `json:"period"`
+	PeriodStart string  `json:"period_start"`
+	Tokens      int64   `json:"tokens"`
+	TokenLimit  int64   `json:"token_limit,omitempty"`
+	TokenRatio  float64 `json:"token_ratio,omitempty"`
+	CostUSD     float64 `json:"cost_usd"`
+	CostLimit   float64 `json:"cost_limit_usd,omitempty"`
+	CostRatio   float64 `json:"cost_ratio,omitempty"`
+	Exceeded    bool    `json:"exceeded"`
+}
+
+// periodStart は now が属する区間の開始時刻(ローカル時刻)を返す｡週は月曜起点｡
+func periodStart(now time.Time, period string) time.Time {
+	now = now.Local()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if period == GroupByWeek {
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	}
+	return start
+}
+
+// EvaluateBudgets は now を含む区間の消費量を予算ごとに集計する｡
+// 区間はファイルの mtime ではなく各APIコールの timestamp で判定する｡
+func EvaluateBudgets(results []SessionResult, budgets []Budget, now time.Time) []BudgetStatus {
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		start := periodStart(now, b.Period)
+		st := BudgetStatus{
+			Project:     b.Project,
+			Period:      b.Period,
+			PeriodStart: start.Format("2006-01-02"),
+			TokenLimit:  b.Tokens,
+			CostLimit:   b.CostUSD,
+		}
+		for _, r := range results {
+			if !b.matches(r.Project) {
+				continue
+			}
+			for _, c := range r.Calls {
+				if c.Timestamp.Before(start) || c.Timestamp.After(now) {
+					continue
+				}
+				st.Tokens += c.ContextTokens() + c.Usage.OutputTokens
+				st.CostUSD += c.CostUSD
+			}
+		}
+		if b.Tokens > 0 {
+			st.TokenRatio = float64(st.Tokens) / float64(b.Tokens)
+			st.Exceeded = st.Tokens > b.Tokens
+		}
+		if b.CostUSD > 0 {
+			st.CostRatio = st.CostUSD / b.CostUSD
+			st.Exceeded = st.Exceeded || st.CostUSD > b.CostUSD
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// FormatBudgetStatuses は予算の消費状況をテキストに整形する｡
+func FormatBudgetStatuses(statuses []BudgetStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== Token Budget ===\n")
+	tbl := &textTable{
+		headers: []string{"project", "period", "since", "tokens", "limit", "used", "cost", "limit", "used", "status"},
+		numeric: []bool{false, false, false, true, true, true, true, true, true, false},
+	}
+	for _, st := range statuses {
+		status := "ok"
+		if st.Exceeded {
+			status = "EXCEEDED"
+		}
+		row := []string{st.Project, st.Period, st.PeriodStart,
+			humanizeTokens(st.Tokens), "-", "-", fmt.Sprintf("$%.2f", st.CostUSD), "-", "-", status}
+		if st.TokenLimit > 0 {
+			row[4], row[5] = humanizeTokens(st.TokenLimit), formatPercent(st.TokenRatio)
+		}
+		if st.CostLimit > 0 {
+			row[7], row[8] = fmt.Sprintf("$%.2f", st.CostLimit), formatPercent(st.CostRatio)
+		}
+		tbl.add(row, row)
+	}
+	tbl.render(&b, "  ", 0)
+	return b.String()
+}
+
+// sessionStartInput は SessionStart フックの入力 JSON のうち使用するフィールド｡
+type sessionStartInput struct {
+	CWD string `json:"cwd"`
+}
+
+// sessionStartOutput は SessionStart フックの出力 JSON｡
+// additionalContext はエージェントに、systemMessage はユーザーに表示される｡
+type sessionStartOutput struct {
+	HookSpecificOutput struct {
+		HookEventName     string `json:"hookEventName"`
+		AdditionalContext string `json:"additionalContext"`
+	} `json:"hookSpecificOutput"`
+	SystemMessage string `json:"systemMessage"`
+}
+
+// budgetHookMessage は超過した予算の一覧から SessionStart フックの出力を作る｡
+// 超過がなければ nil を返す｡
+func budgetHookMessage(statuses []BudgetStatus) *sessionStartOutput {
+	var lines []string
+	for _, st := range statuses {
+		if !st.Exceeded {
+			continue
+		}
+		line := fmt.Sprintf("%s の %s 予算を超過 (%s〜)", st.Project, st.Period, st.PeriodStart)
+		if st.TokenLimit > 0 {
+			line += fmt.Sprintf(" tokens %s / %s", humanizeTokens(st.Tokens), humanizeTokens(st.TokenLimit))
+		}
+		if st.CostLimit > 0 {
+			line += fmt.Sprintf(" cost $%.2f / $%.2f", st.CostUSD, st.CostLimit)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	msg := "token 予算超過: " + strings.Join(lines, "; ")
+	out := &sessionStartOutput{SystemMessage: msg}
+	out.HookSpecificOutput.HookEventName = "SessionStart"
+	out.HookSpecificOutput.AdditionalContext = msg +
+		"｡subagent の多用や大きなファイルの全文読み込みを避け、haiku 等の軽量モデルの利用を検討してください｡"
+	return out
+}
+
+// scanWithPricing は mtime が days 日以内のセッションを読み込み、料金表があれば料金を設定する｡
+func scanWithPricing(projectsDir string, days int, pricingPath, cachePath string, noCache bool, resolver *project.Resolver, stderr io.Writer) ([]SessionResult, error) {
+	results, err := scanProjects(projectsDir, days, "", cachePath, noCache, resolver, stderr)
+	if err != nil {
+		return nil, fmt.Errorf("スキャン失敗: %w", err)
+	}
+	if pricingPath == "" {
+		return results, nil
+	}
+	table, err := LoadPricing(pricingPath)
+	if err != nil {
+		return nil, fmt.Errorf("料金表の読み込み失敗: %w", err)
+	}
+	ApplyPricing(results, table)
+	return results, nil
+}
+
+// filterBudgets は project に適用される予算を返す｡project が空なら全予算｡
+func filterBudgets(budgets []Budget, project string) []Budget {
+	var filtered []Budget
+	for _, b := range budgets {
+		if project == "" || b.matches(project) {
+			filtered = append(filtered, b)
+		}
+	}
+	return filtered
+}
+
+// warnUnpricedBudgets は料金表がない場合に判定できない cost_usd の予算を警告する｡
+func warnUnpricedBudgets(budgets []Budget, stderr io.Writer) {
+	for _, b := range budgets {
+		if b.CostUSD > 0 {
+			fmt.Fprintf(stderr, "料金表がないため cost_usd の予算は判定できません (%s)\n", b.Project)
+		}
+	}
+}
+
+// budgetFlags は budget サブコマンドのフラグ｡
+type budgetFlags struct {
+	projectsDir, configPath, pricingPath, project, format, cachePath string
+	hook, noCache                                                    bool
+}
+
+// parseBudgetFlags は budget サブコマンドのフラグを解析する｡不正な指定なら false を返す｡
+func parseBudgetFlags(args []string, stderr io.Writer) (budgetFlags, bool) {
+	var f budgetFlags
+	fset := flag.NewFlagSet("budget", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.StringVar(&f.projectsDir, "dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)")
+	fset.StringVar(&f.configPath, "config", "", "設定ファイルのパス (デフォルト: ~/.claude/"+configFileName+")")
+	fset.StringVar(&f.pricingPath, "pricing", "", "料金表JSONのパス (デフォルト: ~/.claude/"+pricingFileName+" があれば使用)")
+	fset.StringVar(&f.project, "project", "", "対象プロジェクト (省略時は全予算)")
+	fset.StringVar(&f.format, "format", FormatSummary, "出力形式: summary または json")
+	fset.BoolVar(&f.hook, "hook", false, "SessionStart フックとして実行する")
+	fset.StringVar(&f.cachePath, "cache", "", "スキャン結果のキャッシュファイル (デフォルト: ユーザーキャッシュディレクトリ配下)")
+	fset.BoolVar(&f.noCache, "no-cache", false, "キャッシュを使わずに全ファイルを解析する")
+	fset.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze-tokens budget [flags]\n予算超過時は終了コード %d を返す\n", exitBudgetExceeded)
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return f, false
+	}
+	if f.format != FormatSummary && f.format != FormatJSON {
+		fmt.Fprintf(stderr, "不明な出力形式: %s (summary または json を指定)\n", f.format)
+		return f, false
+	}
+	return f, true
+}
+
+// loadBudgetStatuses は設定ファイルの予算を直近のセッションで判定し、超過したものを先頭にして返す｡
+// 判定する予算がなければ nil を返す｡フックでなければその旨を stderr に出す｡
+// フック実行時は hookCWD のプロジェクトの予算だけを判定し、設定ファイルがなくてもエラーにしない｡
+func loadBudgetStatuses(f budgetFlags, hookCWD string, stderr io.Writer) ([]BudgetStatus, error) {
+	noBudgets := func() ([]BudgetStatus, error) {
+		if !f.hook {
+			fmt.Fprintf(stderr, "対象の予算が定義されていません\n")
+		}
+		return nil, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("ホームディレクトリ取得失敗: %w", err)
+	}
+	cPath := ResolveConfigPath(f.configPath, home)
+	if cPath == "" {
+		if f.hook {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("設定ファイルがありません (~/.claude/%s または --config)", configFileName)
+	}
+	cfg, err := LoadConfig(cPath)
+	if err != nil {
+		return nil, fmt.Errorf("設定ファイルの読み込み失敗: %w", err)
+	}
+	if len(cfg.Budgets) == 0 {
+		return noBudgets()
+	}
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		return nil, err
+	}
+	pPath := ResolvePricingPath(f.pricingPath, home)
+	// 週次予算でも mtime が8日以内のファイルを見れば足りる
+	results, err := scanWithPricing(pathutil.ResolveProjectsDir(f.projectsDir, home), 8, pPath, f.cachePath, f.noCache, resolver, stderr)
+	if err != nil {
+		return nil, err
+	}
+	projectName := f.project
+	// フックの cwd は集計対象のセッションと同じ規則で名前を付ける
+	if f.hook {
+		projectName = resolver.Name(resolver.Resolve(hookCWD, "").ID)
+	}
+
+	budgets := filterBudgets(cfg.Budgets, projectName)
+	if len(budgets) == 0 {
+		return noBudgets()
+	}
+	if pPath == "" {
+		warnUnpricedBudgets(budgets, stderr)
+	}
+	statuses := EvaluateBudgets(results, budgets, time.Now())
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].Exceeded && !statuses[j].Exceeded })
+	return statuses, nil
+}
+
+// writeBudgetStatuses は判定結果を format の形式で書き出す｡
+func writeBudgetStatuses(w io.Writer, statuses []BudgetStatus, format string) error {
+	if format != FormatJSON {
+		_, err := fmt.Fprint(w, FormatBudgetStatuses(statuses))
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(statuses); err != nil {
+		return fmt.Errorf("JSON出力失敗: %w", err)
+	}
+	return nil
+}
+
+// runBudget は budget サブコマンドを実行し、終了コードを返す｡
+// --hook 指定時は stdin の SessionStart フック入力の cwd からプロジェクトを決め、
+// 超過時のみフック出力 JSON を書き出して常に 0 を返す｡
+func runBudget(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	f, ok := parseBudgetFlags(args, stderr)
+	if !ok {
+		return 2
+	}
+	var hookCWD string
+	if f.hook {
+		var input sessionStartInput
+		if err := json.NewDecoder(stdin).Decode(&input); err != nil {
+			fmt.Fprintf(stderr, "フック入力のパースに失敗: %v\n", err)
+			return 1
+		}
+		hookCWD = input.CWD
+	}
+
+	statuses, err := loadBudgetStatuses(f, hookCWD, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	if statuses == nil {
+		return 0
+	}
+
+	if f.hook {
+		if out := budgetHookMessage(statuses); out != nil {
+			if err := json.NewEncoder(stdout).Encode(out); err != nil {
+				fmt.Fprintf(stderr, "JSON出力失敗: %v\n", err)
+				return 1
+			}
+		}
+		return 0
+	}
+
+	if err := writeBudgetStatuses(stdout, statuses, f.format); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	for _, st := range statuses {
+		if st.Exceeded {
+			return exitBudgetExceeded
+		}
+	}
+	return 0
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	// 2026-10-01 は木曜日
+	now := time.Date(2026, 10, 1, 13, 45, 0, 0, time.Local)
+	if got := periodStart(now, GroupByDay); !got.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("day = %v", got)
+	}
+	if got := periodStart(now, GroupByWeek); !got.Equal(time.Date(2026, 9, 28, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("week = %v", got)
+	}
+}
+
+func TestBudgetValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       Budget
+		wantErr bool
+	}{
+		{"tokens予算", Budget{Project: "alpha", Period: "day", Tokens: 1000}, false},
+		{"全プロジェクトのcost予算", Budget{Project: "*", Period: "week", CostUSD: 10}, false},
+		{"project未指定", Budget{Period: "day", Tokens: 1000}, true},
+		{"不明なperiod", Budget{Project: "alpha", Period: "month", Tokens: 1000}, true},
+		{"上限なし", Budget{Project: "alpha", Period: "day"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.b.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluateBudgets(t *testing.T) {
+	now := time.Date(2026, 10, 1, 18, 0, 0, 0, time.Local)
+	today := time.Date(2026, 10, 1, 9, 0, 0, 0, time.Local)
+	monday := time.Date(2026, 9, 28, 9, 0, 0, 0, time.Local)
+	lastWeek := time.Date(2026, 9, 25, 9, 0, 0, 0, time.Local)
+	results := []SessionResult{
+		{Project: "alpha", Calls: []APICall{
+			{Timestamp: today, Usage: tokenUsage{InputTokens: 100, CacheReadInputTokens: 800, OutputTokens: 100}, CostUSD: 1},
+			{Timestamp: monday, Usage: tokenUsage{InputTokens: 5000}, CostUSD: 2},
+			{Timestamp: lastWeek, Usage: tokenUsage{InputTokens: 99999}, CostUSD: 50},
+		}},
+		{Project: "beta", Calls: []APICall{
+			{Timestamp: today, Usage: tokenUsage{InputTokens: 3000}, CostUSD: 4},
+		}},
+	}
+	budgets := []Budget{
+		{Project: "alpha", Period: "day", Tokens: 2000},
+		{Project: "alpha", Period: "week", Tokens: 5000},
+		{Project: "*", Period: "day", CostUSD: 4.5},
+	}
+
+	statuses := EvaluateBudgets(results, budgets, now)
+	if len(statuses) != 3 {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+
+	t.Run("日次は当日のコールのみ", func(t *testing.T) {
+		st := statuses[0]
+		if st.Tokens != 1000 || st.Exceeded || st.TokenRatio != 0.5 || st.PeriodStart != "2026-10-01" {
+			t.Errorf("alpha day = %+v", st)
+		}
+	})
+
+	t.Run("週次は月曜以降のコール", func(t *testing.T) {
+		st := statuses[1]
+		if st.Tokens != 6000 || !st.Exceeded || st.PeriodStart != "2026-09-28" {
+			t.Errorf("alpha week = %+v", st)
+		}
+	})
+
+	t.Run("全プロジェクト合計のcost予算", func(t *testing.T) {
+		st := statuses[2]
+		if st.CostUSD != 5 || !st.Exceeded || st.Project != "*" {
+			t.Errorf("* day = %+v", st)
+		}
+	})
+
+	t.Run("超過分のみフックメッセージにする", func(t *testing.T) {
+		out := budgetHookMessage(statuses)
+		if out == nil || out.HookSpecificOutput.HookEventName != "SessionStart" {
+			t.Fatalf("out = %+v", out)
+		}
+		if !strings.Contains(out.SystemMessage, "alpha の week 予算を超過") || strings.Contains(out.SystemMessage, "alpha の day") {
+			t.Errorf("SystemMessage = %q", out.SystemMessage)
+		}
+		if budgetHookMessage(statuses[:1]) != nil {
+			t.Error("超過がなければ nil")
+		}
+	})
+}
+
+func TestRunBudget(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	dir := t.TempDir()
+	ts := time.Now().UTC().Format(time.RFC3339)
+	writeTestJSONL(t, dir, "s1.jsonl",
+		`{"type":"assistant","cwd":"/Users/test/alpha","sessionId":"s1","timestamp":"`+ts+`","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":5000,"output_tokens":100}}}
+`)
+	cfg := writeTestJSONL(t, t.TempDir(), configFileName,
+		`{"budgets":[{"project":"alpha","period":"day","tokens":1000},{"project":"beta","period":"day","tokens":1000}]}`)
+
+	t.Run("超過時は専用の終了コード", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runBudget([]string{"--dir", dir, "--config", cfg}, strings.NewReader(""), &stdout, &stderr)
+		if code != exitBudgetExceeded {
+			t.Fatalf("exit = %d, want %d, stderr = %s", code, exitBudgetExceeded, stderr.String())
+		}
+		out := stdout.String()
+		if !strings.Contains(out, "EXCEEDED") || strings.Index(out, "alpha") > strings.Index(out, "beta") {
+			t.Errorf("超過した予算が先に表示されるべき:\n%s", out)
+		}
+	})
+
+	t.Run("--projectで対象を絞る", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runBudget([]string{"--dir", dir, "--config", cfg, "--project", "beta", "--format", "json"}, strings.NewReader(""), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("exit = %d, stderr = %s", code, stderr.String())
+		}
+		var statuses []BudgetStatus
+		if err := json.Unmarshal(stdout.Bytes(), &statuses); err != nil {
+			t.Fatalf("JSONパース失敗: %v", err)
+		}
+		if len(statuses) != 1 || statuses[0].Project != "beta" {
+			t.Errorf("statuses = %+v", statuses)
+		}
+	})
+
+	t.Run("フックモードはcwdのプロジェクトで判定し0を返す", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		stdin := strings.NewReader(`{"session_id":"x","cwd":"/Users/test/alpha","hook_event_name":"SessionStart"}`)
+		if code := runBudget([]string{"--dir", dir, "--config", cfg, "--hook"}, stdin, &stdout, &stderr); code != 0 {
+			t.Fatalf("exit = %d, stderr = %s", code, stderr.String())
+		}
+		var out sessionStartOutput
+		if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+			t.Fatalf("JSONパース失敗: %v (%s)", err, stdout.String())
+		}
+		if !strings.Contains(out.HookSpecificOutput.AdditionalContext, "alpha") {
+			t.Errorf("out = %+v", out)
+		}
+
+		stdout.Reset()
+		stdin = strings.NewReader(`{"cwd":"/Users/test/beta"}`)
+		if code := runBudget([]string{"--dir", dir, "--config", cfg, "--hook"}, stdin, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+			t.Errorf("超過がなければ何も出力しない: exit = %d, out = %q", code, stdout.String())
+		}
+	})
+
+	t.Run("設定ファイルがなければエラー", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runBudget([]string{"--dir", dir}, strings.NewReader(""), &stdout, &stderr); code != 1 {
+			t.Errorf("exit = %d, want 1", code)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/usadamasa/claude-config/internal/jsonlscan"
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+// sessionCacheVersion はキャッシュに保存する集計状態の形式のバージョン｡
+// 集計ロジックや sessionScanner の保存内容を変えたら更新し、古いキャッシュを捨てる｡
+const sessionCacheVersion = "analyze-tokens/7"
+
+// SessionCache はファイルごとの集計途中の状態を保存するキャッシュ｡
+type SessionCache = jsonlscan.FileCache[*sessionScanner]
+
+// LoadSessionCache はキャッシュファイルを読み込む｡読めなければ空のキャッシュを返す｡
+func LoadSessionCache(path string) *SessionCache {
+	return jsonlscan.LoadFileCache[*sessionScanner](path, sessionCacheVersion)
+}
+
+// ResolveCachePath はキャッシュファイルのパスを決定する｡
+// 未指定ならユーザーキャッシュディレクトリ配下を使い、それも取得できなければ空文字を返す｡
+func ResolveCachePath(flagPath string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "claude-config", "analyze-tokens-cache.json")
+}
+
+// scanProjects は --cache / --no-cache の指定に従ってキャッシュを使い、セッションを集計する｡
+// キャッシュの保存に失敗しても集計結果は返し、stderr に警告を出す｡
+func scanProjects(projectsDir string, days int, projectFilter, cachePath string, noCache bool, resolver *project.Resolver, stderr io.Writer) ([]SessionResult, error) {
+	cachePath = ResolveCachePath(cachePath)
+	if noCache || cachePath == "" {
+		return ScanProjectsDirWithCache(projectsDir, days, projectFilter, nil, resolver)
+	}
+	cache := LoadSessionCache(cachePath)
+	results, err := ScanProjectsDirWithCache(projectsDir, days, projectFilter, cache, resolver)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.Save(); err != nil {
+		fmt.Fprintf(stderr, "キャッシュの保存に失敗 (%s): %v\n", cachePath, err)
+	}
+	return results, nil
+}
+
+// scanSessionFileCached は cache を使ってセッションファイルを集計する｡cache が nil なら毎回解析する｡
+// プロジェクトはキャッシュせず、毎回 resolver で解決する｡
+func scanSessionFileCached(path string, cache *SessionCache, resolver *project.Resolver) (*SessionResult, error) {
+	if cache == nil {
+		return ScanSessionFileWithResolver(path, resolver)
+	}
+	s, err := cache.Scan(path, func() *sessionScanner { return newSessionScanner(path) },
+		func(s **sessionScanner, line []byte) { (*s).processLine(line) })
+	if err != nil {
+		return nil, err
+	}
+	return s.finish(resolver), nil
+}
+
+// sessionScannerState は sessionScanner をキャッシュに保存する形式｡
+// SessionResult の JSON 出力に含めない集計途中のフィールドもここで保存する｡
+type sessionScannerState struct {
+	Result      *SessionResult      `json:"result"`
+	Calls       []APICall           `json:"calls,omitempty"`
+	Compactions []CompactionEvent   `json:"compactions,omitempty"`
+	Tasks       map[string]TaskInfo `json:"tasks,omitempty"`
+	AgentTasks  map[string]string   `json:"agent_tasks,omitempty"`
+	ToolNames   map[string]string   `json:"tool_names,omitempty"`
+	CallIndex   map[string]int      `json:"call_index,omitempty"`
+	ToolCalls   []toolCall          `json:"tool_calls,omitempty"`
+	SeenEntry   bool                `json:"seen_entry"`
+	CWDs        []cwdUsage          `json:"cwds,omitempty"`
+}
+
+// MarshalJSON は集計途中の状態を JSON にする｡
+func (s *sessionScanner) MarshalJSON() ([]byte, error) {
+	r := s.result
+	return json.Marshal(sessionScannerState{
+		Result:      r,
+		Calls:       r.Calls,
+		Compactions: r.Compactions,
+		Tasks:       r.tasks,
+		AgentTasks:  r.agentTasks,
+		ToolNames:   r.toolNames,
+		CallIndex:   r.callIndex,
+		ToolCalls:   r.toolCalls,
+		SeenEntry:   s.seenEntry,
+		CWDs:        s.cwds,
+	})
+}
+
+// UnmarshalJSON は MarshalJSON で保存した状態を復元する｡
+func (s *sessionScanner) UnmarshalJSON(data []byte) error {
+	var st sessionScannerState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	r := st.Result
+	if r == nil {
+		r = &SessionResult{}
+	}
+	if r.ModelUsage == nil {
+		r.ModelUsage = make(map[string]ModelTokens)
+	}
+	if r.ToolUsage == nil {
+		r.ToolUsage = make(map[string]int)
+	}
+	r.Calls = st.Calls
+	r.Compactions = st.Compactions
+	r.tasks = st.Tasks
+	r.agentTasks = st.AgentTasks
+	r.toolNames = st.ToolNames
+	r.callIndex = st.CallIndex
+	r.toolCalls = st.ToolCalls
+	*s = sessionScanner{result: r, seenEntry: st.SeenEntry, cwds: st.CWDs}
+	return nil
+}
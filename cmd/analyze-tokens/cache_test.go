@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+func TestScanProjectsDirWithCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	lines := strings.SplitAfter(subagentParentJSONL, "\n")
+	parent := writeTestJSONL(t, dir, "parent-1.jsonl", strings.Join(lines[:3], ""))
+	writeTestJSONL(t, dir, "agent-a1.jsonl", subagentChildJSONL)
+
+	cache := LoadSessionCache(cachePath)
+	first, err := ScanProjectsDirWithCache(dir, 30, "", cache, project.NewResolver(nil))
+	if err != nil {
+		t.Fatalf("スキャン失敗: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("results = %d件", len(first))
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save失敗: %v", err)
+	}
+
+	t.Run("追記後の結果はキャッシュなしの集計と一致", func(t *testing.T) {
+		f, err := os.OpenFile(parent, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(strings.Join(lines[3:], "")); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		cached, err := ScanProjectsDirWithCache(dir, 30, "", LoadSessionCache(cachePath), project.NewResolver(nil))
+		if err != nil {
+			t.Fatalf("スキャン失敗: %v", err)
+		}
+		want, err := ScanProjectsDir(dir, 30)
+		if err != nil {
+			t.Fatalf("スキャン失敗: %v", err)
+		}
+		if !reflect.DeepEqual(cached, want) {
+			t.Errorf("cached = %+v\nwant   = %+v", cached, want)
+		}
+		if got := BuildSubagentSummary(cached, 10); !reflect.DeepEqual(got, BuildSubagentSummary(want, 10)) {
+			t.Errorf("subagent summary = %+v", got)
+		}
+	})
+
+	t.Run("料金の設定はキャッシュ中の状態を変更しない", func(t *testing.T) {
+		cache := LoadSessionCache(cachePath)
+		results, err := ScanProjectsDirWithCache(dir, 30, "", cache, project.NewResolver(nil))
+		if err != nil {
+			t.Fatalf("スキャン失敗: %v", err)
+		}
+		ApplyPricing(results, &PricingTable{Models: map[string]ModelPrice{"claude-opus-4-6": {Input: 15}}})
+		again, err := ScanProjectsDirWithCache(dir, 30, "", cache, project.NewResolver(nil))
+		if err != nil {
+			t.Fatalf("スキャン失敗: %v", err)
+		}
+		for _, r := range again {
+			if r.CostUSD != 0 || r.Calls[0].CostUSD != 0 {
+				t.Errorf("%s: CostUSD = %v", r.SessionID, r.CostUSD)
+			}
+		}
+	})
+}
+
+func TestScanProjectsDirWithProjectFilter(t *testing.T) {
+	dir := t.TempDir()
+	line := func(cwd, session string) string {
+		return `{"type":"assistant","cwd":"` + cwd + `","sessionId":"` + session + `","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":100,"output_tokens":10}}}` + "\n"
+	}
+	for _, p := range []struct{ dir, cwd, session string }{
+		{"-nonexistent-src-a-api", "/nonexistent/src/a/api", "s-a"},
+		{"-nonexistent-src-b-api", "/nonexistent/src/b/api", "s-b"},
+		{"-nonexistent-src-web", "/nonexistent/src/web", "s-web"},
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, p.dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeTestJSONL(t, filepath.Join(dir, p.dir), p.session+".jsonl", line(p.cwd, p.session))
+	}
+
+	for _, tt := range []struct {
+		filter string
+		want   []string
+	}{
+		{"a/api", []string{"s-a"}},
+		{"api", []string{"s-a", "s-b"}},
+		{"/nonexistent/src/web", []string{"s-web"}},
+		{"", []string{"s-a", "s-b", "s-web"}},
+	} {
+		t.Run("project="+tt.filter, func(t *testing.T) {
+			results, err := ScanProjectsDirWithCache(dir, 30, tt.filter, nil, project.NewResolver(nil))
+			if err != nil {
+				t.Fatalf("スキャン失敗: %v", err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.SessionID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sessions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("別プロジェクトを飛ばしても表示名は全体で区別する", func(t *testing.T) {
+		results, err := ScanProjectsDirWithCache(dir, 30, "a/api", nil, project.NewResolver(nil))
+		if err != nil {
+			t.Fatalf("スキャン失敗: %v", err)
+		}
+		if len(results) != 1 || results[0].Project != "a/api" {
+			t.Errorf("results = %+v", results)
+		}
+	})
+}
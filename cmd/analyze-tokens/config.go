@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configFileName は ~/.claude 配下のデフォルト設定ファイル名｡
+const configFileName = "analyze-tokens.json"
+
+// Thresholds は組み込み警告の閾値｡
+type Thresholds struct {
+	// ProjectAvgInput と GlobalAvgInput は cache されなかった input token のコールあたり平均に対する閾値｡
+	ProjectAvgInput int64 `json:"project_avg_input"`
+	GlobalAvgInput  int64 `json:"global_avg_input"`
+	// ProjectAvgContext と GlobalAvgContext は実効コンテキスト(input + cache read + cache creation)の
+	// コールあたり平均に対する閾値｡prompt cache が効いていても会話履歴で膨らむため input より大きい値にする｡
+	ProjectAvgContext int64 `json:"project_avg_context"`
+	GlobalAvgContext  int64 `json:"global_avg_context"`
+	CallMessageRatio  int64 `json:"call_message_ratio"`
+	// CacheHitPercent を下回るプロジェクトは cache が効いていない｡
+	CacheHitPercent int64 `json:"cache_hit_percent"`
+	// CacheMinCalls 未満のプロジェクトはサンプル不足として cache 警告の対象外｡
+	CacheMinCalls int64 `json:"cache_min_calls"`
+	// ToolResultAvgTokens を超える平均サイズの tool_result を返すツールはコンテキストを圧迫する｡
+	ToolResultAvgTokens int64 `json:"tool_result_avg_tokens"`
+	// ToolResultMinCount 未満の呼び出ししかないツールは警告の対象外｡
+	ToolResultMinCount int64 `json:"tool_result_min_count"`
+	EnabledPlugins     int64 `json:"enabled_plugins"`
+	GlobalSkills       int64 `json:"global_skills"`
+	// UnusedConfigTokens を超える推定 token を期間中に使われないスキル・プラグイン・MCP サーバーが消費していれば警告する｡
+	UnusedConfigTokens int64 `json:"unused_config_tokens"`
+	// RepeatedToolCalls を超える回数、同じ入力で同じツールを続けて呼んだセッションはループを疑う｡
+	RepeatedToolCalls int64 `json:"repeated_tool_calls"`
+	// RepeatedFileReads を超える回数、同じファイルを Read したセッションを警告する｡
+	RepeatedFileReads int64 `json:"repeated_file_reads"`
+	// FailingBashRetries を超える回数、同じ Bash コマンドが続けて失敗したセッションを警告する｡
+	FailingBashRetries int64 `json:"failing_bash_retries"`
+	// RepeatedCallWindow コール以内の間隔で続く同じ呼び出しを1つの繰り返しとして数える｡
+	// RepeatedToolCalls と FailingBashRetries に使い、セッション中に散らばった同じ呼び出しは数えない｡
+	RepeatedCallWindow int64 `json:"repeated_call_window"`
+	// RapidCompactionWindow コール以内の間隔の compaction が RapidCompactions を超えて続けば警告する｡
+	RapidCompactions      int64 `json:"rapid_compactions"`
+	RapidCompactionWindow int64 `json:"rapid_compaction_window"`
+}
+
+// DefaultThresholds は組み込みの閾値を返す｡
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		ProjectAvgInput:     80000,
+		GlobalAvgInput:      60000,
+		ProjectAvgContext:   150000,
+		GlobalAvgContext:    120000,
+		CallMessageRatio:    50,
+		CacheHitPercent:     50,
+		CacheMinCalls:       20,
+		ToolResultAvgTokens: 5000,
+		ToolResultMinCount:  5,
+		EnabledPlugins:      10,
+		GlobalSkills:        15,
+		UnusedConfigTokens:  2000,
+		RepeatedToolCalls:   4,
+		RepeatedFileReads:   4,
+		FailingBashRetries:  2,
+		RepeatedCallWindow:  10,
+		// 2回以上の compaction が50コール以内の間隔で続けば警告する
+		RapidCompactions:      1,
+		RapidCompactionWindow: 50,
+	}
+}
+
+// fields は JSON キー名から各閾値へのポインタを返す｡
+func (t *Thresholds) fields() map[string]*int64 {
+	return map[string]*int64{
+		"project_avg_input":       &t.ProjectAvgInput,
+		"global_avg_input":        &t.GlobalAvgInput,
+		"project_avg_context":     &t.ProjectAvgContext,
+		"global_avg_context":      &t.GlobalAvgContext,
+		"call_message_ratio":      &t.CallMessageRatio,
+		"cache_hit_percent":       &t.CacheHitPercent,
+		"cache_min_calls":         &t.CacheMinCalls,
+		"tool_result_avg_tokens":  &t.ToolResultAvgTokens,
+		"tool_result_min_count":   &t.ToolResultMinCount,
+		"enabled_plugins":         &t.EnabledPlugins,
+		"global_skills":           &t.GlobalSkills,
+		"unused_config_tokens":    &t.UnusedConfigTokens,
+		"repeated_tool_calls":     &t.RepeatedToolCalls,
+		"repeated_file_reads":     &t.RepeatedFileReads,
+		"failing_bash_retries":    &t.FailingBashRetries,
+		"repeated_call_window":    &t.RepeatedCallWindow,
+		"rapid_compactions":       &t.RapidCompactions,
+		"rapid_compaction_window": &t.RapidCompactionWindow,
+	}
+}
+
+// Set は name=value 形式で閾値を1つ上書きする｡
+func (t *Thresholds) Set(assignment string) error {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok {
+		return fmt.Errorf("閾値は name=value の形式で指定してください: %q", assignment)
+	}
+	fields := t.fields()
+	field, ok := fields[strings.TrimSpace(name)]
+	if !ok {
+		names := make([]string, 0, len(fields))
+		for n := range fields {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("不明な閾値: %s (%s のいずれかを指定)", name, strings.Join(names, ", "))
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return fmt.Errorf("閾値 %s の値が整数ではありません: %q", name, value)
+	}
+	*field = n
+	return nil
+}
+
+// thresholdFlags は --threshold name=value を繰り返し受け取る flag.Value｡
+type thresholdFlags []string
+
+func (f *thresholdFlags) String() string { return strings.Join(*f, ",") }
+
+func (f *thresholdFlags) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// RuleConfig は設定ファイルで定義するカスタム警告ルール｡
+type RuleConfig struct {
+	// Type は Warning.Type に設定する警告種別｡
+	Type string `json:"type"`
+	// When は警告を出す条件式｡例: project.avg_input > 80000 && project.sessions > 5
+	When           string `json:"when"`
+	Message        string `json:"message"`
+	Recommendation string `json:"recommendation,omitempty"`
+	// Value は Warning.Value に設定する式｡省略時は0｡
+	Value     string `json:"value,omitempty"`
+	Threshold int64  `json:"threshold,omitempty"`
+}
+
+// Config は analyze-tokens の設定ファイルの内容｡
+type Config struct {
+	Thresholds Thresholds   `json:"thresholds"`
+	Rules      []RuleConfig `json:"rules,omitempty"`
+	Budgets    []Budget     `json:"budgets,omitempty"`
+
+	rules []*Rule
+}
+
+// DefaultConfig は組み込みの閾値のみを持つ設定を返す｡
+func DefaultConfig() *Config {
+	return &Config{Thresholds: DefaultThresholds()}
+}
+
+// LoadConfig は設定ファイルを読み込む｡
+// ファイルにない閾値はデフォルト値のまま残る｡ルールのコンパイルと予算の検証もここで行う｡
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはフラグ引数由来
+	if err != nil {
+		return nil, err
+	}
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("設定ファイルのパースに失敗 (%s): %w", path, err)
+	}
+	if err := cfg.compileRules(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, b := range cfg.Budgets {
+		if err := b.validate(); err != nil {
+			return nil, fmt.Errorf("%s: budgets[%d]: %w", path, i, err)
+		}
+	}
+	return cfg, nil
+}
+
+func (c *Config) compileRules() error {
+	c.rules = nil
+	for i, rc := range c.Rules {
+		r, err := CompileRule(rc)
+		if err != nil {
+			return fmt.Errorf("rules[%d] (%s): %w", i, rc.Type, err)
+		}
+		c.rules = append(c.rules, r)
+	}
+	return nil
+}
+
+// ResolveConfigPath は設定ファイルのパスを決定する｡
+// 未指定なら ~/.claude/analyze-tokens.json が存在する場合のみそれを使い、なければ空文字を返す｡
+func ResolveConfigPath(flagPath, home string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	path := filepath.Join(home, ".claude", configFileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThresholdsSet(t *testing.T) {
+	th := DefaultThresholds()
+	if err := th.Set("project_avg_context=120000"); err != nil {
+		t.Fatalf("Set失敗: %v", err)
+	}
+	if th.ProjectAvgContext != 120000 || th.GlobalAvgContext != DefaultThresholds().GlobalAvgContext {
+		t.Errorf("thresholds = %+v", th)
+	}
+
+	for _, bad := range []string{"project_avg_context", "unknown=1", "global_skills=abc"} {
+		if err := th.Set(bad); err == nil {
+			t.Errorf("Set(%q) はエラーになるべき", bad)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("ファイルにない閾値はデフォルトのまま", func(t *testing.T) {
+		path := writeTestJSONL(t, t.TempDir(), configFileName, `{"thresholds":{"enabled_plugins":20}}`)
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig失敗: %v", err)
+		}
+		want := DefaultThresholds()
+		want.EnabledPlugins = 20
+		if cfg.Thresholds != want {
+			t.Errorf("Thresholds = %+v, want %+v", cfg.Thresholds, want)
+		}
+	})
+
+	t.Run("ルールをコンパイルする", func(t *testing.T) {
+		path := writeTestJSONL(t, t.TempDir(), configFileName,
+			`{"rules":[{"type":"busy_project","when":"project.sessions > 5","message":"m"}]}`)
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig失敗: %v", err)
+		}
+		if len(cfg.rules) != 1 || cfg.rules[0].scope != ScopeProject {
+			t.Errorf("rules = %+v", cfg.rules)
+		}
+	})
+
+	t.Run("不正なルールはエラー", func(t *testing.T) {
+		path := writeTestJSONL(t, t.TempDir(), configFileName,
+			`{"rules":[{"type":"bad","when":"project.sessions >","message":"m"}]}`)
+		if _, err := LoadConfig(path); err == nil {
+			t.Error("エラーが返されるべき")
+		}
+	})
+}
+
+func TestResolveConfigPath(t *testing.T) {
+	home := t.TempDir()
+	if got := ResolveConfigPath("", home); got != "" {
+		t.Errorf("設定ファイルがない場合は空文字: got %q", got)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".claude"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestJSONL(t, filepath.Join(home, ".claude"), configFileName, `{}`)
+	if got := ResolveConfigPath("", home); got != path {
+		t.Errorf("デフォルトパス: got %q, want %q", got, path)
+	}
+	if got := ResolveConfigPath("/tmp/c.json", home); got != "/tmp/c.json" {
+		t.Errorf("フラグ指定が優先: got %q", got)
+	}
+}
+
+func TestGenerateReportWithConfig(t *testing.T) {
+	results := []SessionResult{
+		{SessionID: "s1", Project: "proj-a", TotalInputTokens: 700000, APICallCount: 10, UserMessageCount: 2},
+		{SessionID: "s2", Project: "proj-b", TotalInputTokens: 100000, APICallCount: 10, UserMessageCount: 2},
+	}
+
+	t.Run("閾値の変更が組み込み警告に反映される", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Thresholds.GlobalAvgInput = 30000
+		cfg.Thresholds.ProjectAvgInput = 60000
+		report := GenerateReportWithConfig(results, 10, cfg)
+		var got []string
+		for _, w := range report.Warnings {
+			got = append(got, w.Type+":"+w.Project)
+		}
+		// 全体平均 40000、proj-a 70000、proj-b 10000
+		if len(got) != 2 || got[0] != "global_high_avg:" || got[1] != "high_avg_input:proj-a" {
+			t.Errorf("warnings = %v", got)
+		}
+		if ws := GenerateReport(results, 10).Warnings; len(ws) != 0 {
+			t.Errorf("デフォルトの閾値では警告なし: %+v", ws)
+		}
+	})
+
+	t.Run("カスタムルールの警告が追加される", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Rules = []RuleConfig{{
+			Type:      "custom_heavy_project",
+			When:      `project.avg_input > 50000 && project.name != "proj-b"`,
+			Message:   "重いプロジェクト",
+			Value:     "project.avg_input",
+			Threshold: 50000,
+		}}
+		if err := cfg.compileRules(); err != nil {
+			t.Fatalf("compileRules失敗: %v", err)
+		}
+		report := GenerateReportWithConfig(results, 10, cfg)
+		var custom []Warning
+		for _, w := range report.Warnings {
+			if w.Type == "custom_heavy_project" {
+				custom = append(custom, w)
+			}
+		}
+		if len(custom) != 1 || custom[0].Project != "proj-a" || custom[0].Value != 70000 || custom[0].Message != "重いプロジェクト" {
+			t.Errorf("custom warnings = %+v", custom)
+		}
+	})
+}
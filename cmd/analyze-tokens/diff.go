@@ -0,0 +1,455 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+// diffMetrics は diff サブコマンドで比較する指標の並び｡
+var diffMetrics = []string{"average_context_per_call", "cache_hit_ratio", "calls_per_message", "cost_per_session"}
+
+// DateRange は開始日から終了日までを含むローカル時刻の区間 [Start, End)｡
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParseDateRange は "2026-09-01..2026-09-15" 形式の区間をパースする｡終了日も区間に含む｡
+func ParseDateRange(s string) (DateRange, error) {
+	from, to, ok := strings.Cut(s, "..")
+	if !ok {
+		return DateRange{}, fmt.Errorf("区間は YYYY-MM-DD..YYYY-MM-DD の形式で指定してください: %q", s)
+	}
+	start, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(from), time.Local)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("開始日のパースに失敗: %w", err)
+	}
+	end, err := time.ParseInLocation(time.DateOnly, strings.TrimSpace(to), time.Local)
+	if err != nil {
+		return DateRange{}, fmt.Errorf("終了日のパースに失敗: %w", err)
+	}
+	if end.Before(start) {
+		return DateRange{}, fmt.Errorf("終了日が開始日より前です: %q", s)
+	}
+	return DateRange{Start: start, End: end.AddDate(0, 0, 1)}, nil
+}
+
+// Contains は t が区間に含まれるかを返す｡
+func (r DateRange) Contains(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End)
+}
+
+// String は "2026-09-01..2026-09-15" 形式で区間を返す｡
+func (r DateRange) String() string {
+	return r.Start.Format(time.DateOnly) + ".." + r.End.AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+// MetricDiff は1指標の前後比較｡Before と After は期間全体の集計値、
+// PValue はセッション単位の値に対する Welch の t 検定(両側)の p 値｡
+type MetricDiff struct {
+	Metric       string  `json:"metric"`
+	Before       float64 `json:"before"`
+	After        float64 `json:"after"`
+	Delta        float64 `json:"delta"`
+	DeltaPercent float64 `json:"delta_percent"`
+	PValue       float64 `json:"p_value"`
+	// Significance は p < 0.01 で "**"、p < 0.05 で "*"、どちらかのサンプルが2未満なら "n/a"｡
+	Significance string `json:"significance,omitempty"`
+}
+
+// ProjectDiff はプロジェクト1つの前後比較｡Project が "*" の行は全プロジェクト合計｡
+type ProjectDiff struct {
+	Project        string       `json:"project"`
+	BeforeSessions int          `json:"before_sessions"`
+	AfterSessions  int          `json:"after_sessions"`
+	BeforeCostUSD  float64      `json:"before_cost_usd,omitempty"`
+	AfterCostUSD   float64      `json:"after_cost_usd,omitempty"`
+	Metrics        []MetricDiff `json:"metrics"`
+}
+
+// DiffReport は2期間の比較結果｡
+type DiffReport struct {
+	Before   string        `json:"before"`
+	After    string        `json:"after"`
+	Projects []ProjectDiff `json:"projects"`
+}
+
+// sessionSample は比較に使うセッション1つ分の集計｡subagent の sidechain は起動元のセッションに合算する｡
+type sessionSample struct {
+	start         time.Time
+	input         int64
+	cacheRead     int64
+	cacheCreation int64
+	calls         int
+	userMessages  int
+	cost          float64
+}
+
+func (s *sessionSample) add(r SessionResult) {
+	for _, c := range r.Calls {
+		if s.start.IsZero() || c.Timestamp.Before(s.start) {
+			s.start = c.Timestamp
+		}
+	}
+	s.input += r.TotalInputTokens
+	s.cacheRead += r.TotalCacheReadTokens
+	s.cacheCreation += r.TotalCacheCreationTokens
+	s.calls += r.APICallCount
+	s.userMessages += r.UserMessageCount
+	s.cost += r.CostUSD
+}
+
+// value は指標のセッション単位の値を返す｡値が定義できなければ ok=false｡
+func (s *sessionSample) value(metric string) (float64, bool) {
+	switch metric {
+	case "average_context_per_call":
+		return float64(averagePerCall(contextTokens(s.input, s.cacheRead, s.cacheCreation), s.calls)), s.calls > 0
+	case "cache_hit_ratio":
+		return cacheHitRatio(s.input, s.cacheRead, s.cacheCreation), contextTokens(s.input, s.cacheRead, s.cacheCreation) > 0
+	case "calls_per_message":
+		if s.userMessages == 0 {
+			return 0, false
+		}
+		return float64(s.calls) / float64(s.userMessages), true
+	case "cost_per_session":
+		return s.cost, true
+	}
+	return 0, false
+}
+
+// periodSamples は1期間・1プロジェクトのセッションの集まり｡
+type periodSamples []*sessionSample
+
+// total は全セッションを1つに合算した集計を返す｡指標の期間全体の値に使う｡
+func (p periodSamples) total() *sessionSample {
+	t := &sessionSample{}
+	for _, s := range p {
+		t.input += s.input
+		t.cacheRead += s.cacheRead
+		t.cacheCreation += s.cacheCreation
+		t.calls += s.calls
+		t.userMessages += s.userMessages
+		t.cost += s.cost
+	}
+	return t
+}
+
+func (p periodSamples) values(metric string) []float64 {
+	var values []float64
+	for _, s := range p {
+		if v, ok := s.value(metric); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// aggregate は全期間の値を返す｡cost_per_session は合計をセッション数で割る｡
+func (p periodSamples) aggregate(metric string) float64 {
+	t := p.total()
+	if metric == "cost_per_session" {
+		if len(p) == 0 {
+			return 0
+		}
+		return t.cost / float64(len(p))
+	}
+	v, _ := t.value(metric)
+	return v
+}
+
+// collectSamples はセッションをプロジェクト・期間ごとに振り分ける｡
+// セッションの期間は最初の API コールの timestamp で決める｡
+func collectSamples(results []SessionResult, before, after DateRange) map[string][2]periodSamples {
+	sessions := make(map[string]*sessionSample)
+	projects := make(map[string]string)
+	var keys []string
+	for _, r := range results {
+		key := r.rootSessionID()
+		if sessions[key] == nil {
+			sessions[key] = &sessionSample{}
+			keys = append(keys, key)
+		}
+		sessions[key].add(r)
+		if !r.IsSidechain || projects[key] == "" {
+			projects[key] = r.Project
+		}
+	}
+
+	samples := make(map[string][2]periodSamples)
+	for _, key := range keys {
+		s := sessions[key]
+		for i, period := range []DateRange{before, after} {
+			if !period.Contains(s.start) {
+				continue
+			}
+			for _, name := range []string{projects[key], allProjects} {
+				ps := samples[name]
+				ps[i] = append(ps[i], s)
+				samples[name] = ps
+			}
+		}
+	}
+	return samples
+}
+
+// BuildDiff は before と after の期間をプロジェクトごとに比較する｡
+// 全プロジェクト合計の行を先頭に、以降はセッション数の多い順に並べる｡
+func BuildDiff(results []SessionResult, before, after DateRange) DiffReport {
+	report := DiffReport{Before: before.String(), After: after.String(), Projects: []ProjectDiff{}}
+	for name, ps := range collectSamples(results, before, after) {
+		d := ProjectDiff{
+			Project:        name,
+			BeforeSessions: len(ps[0]),
+			AfterSessions:  len(ps[1]),
+			BeforeCostUSD:  ps[0].total().cost,
+			AfterCostUSD:   ps[1].total().cost,
+		}
+		for _, metric := range diffMetrics {
+			d.Metrics = append(d.Metrics, compareMetric(metric, ps[0], ps[1]))
+		}
+		report.Projects = append(report.Projects, d)
+	}
+	sort.Slice(report.Projects, func(i, j int) bool {
+		a, b := report.Projects[i], report.Projects[j]
+		if (a.Project == allProjects) != (b.Project == allProjects) {
+			return a.Project == allProjects
+		}
+		if na, nb := a.BeforeSessions+a.AfterSessions, b.BeforeSessions+b.AfterSessions; na != nb {
+			return na > nb
+		}
+		return a.Project < b.Project
+	})
+	return report
+}
+
+func compareMetric(metric string, before, after periodSamples) MetricDiff {
+	m := MetricDiff{Metric: metric, Before: before.aggregate(metric), After: after.aggregate(metric)}
+	m.Delta = m.After - m.Before
+	if m.Before != 0 {
+		m.DeltaPercent = math.Round(m.Delta/m.Before*1000) / 10
+	}
+	p, ok := welchTTest(before.values(metric), after.values(metric))
+	switch {
+	case !ok:
+		m.PValue, m.Significance = 1, "n/a"
+	case p < 0.01:
+		m.PValue, m.Significance = p, "**"
+	case p < 0.05:
+		m.PValue, m.Significance = p, "*"
+	default:
+		m.PValue = p
+	}
+	return m
+}
+
+// welchTTest は等分散を仮定しない2標本 t 検定の両側 p 値を返す｡
+// どちらかのサンプルが2未満なら ok=false｡両方の分散が0なら平均が等しいかどうかで 1 か 0 を返す｡
+func welchTTest(a, b []float64) (float64, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	sa, sb := varA/float64(len(a)), varB/float64(len(b))
+	if sa+sb == 0 {
+		if meanA == meanB {
+			return 1, true
+		}
+		return 0, true
+	}
+	t := (meanA - meanB) / math.Sqrt(sa+sb)
+	df := (sa + sb) * (sa + sb) / (sa*sa/float64(len(a)-1) + sb*sb/float64(len(b)-1))
+	return regularizedIncompleteBeta(df/2, 0.5, df/(df+t*t)), true
+}
+
+// meanVariance は平均と不偏分散を返す｡
+func meanVariance(xs []float64) (mean, variance float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, variance / float64(len(xs)-1)
+}
+
+// regularizedIncompleteBeta は正則化不完全ベータ関数 I_x(a, b) を連分数展開で求める｡
+// t 分布の両側 p 値は I_{df/(df+t²)}(df/2, 1/2) になる｡
+func regularizedIncompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lgab, _ := math.Lgamma(a + b)
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+	// 収束の速い側で展開する
+	if x > (a+1)/(a+b+2) {
+		return 1 - front*betaContinuedFraction(b, a, 1-x)/b
+	}
+	return front * betaContinuedFraction(a, b, x) / a
+}
+
+// betaContinuedFraction は不完全ベータ関数の連分数を Lentz 法で評価する｡
+func betaContinuedFraction(a, b, x float64) float64 {
+	const tiny = 1e-300
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= 200; m++ {
+		fm := float64(m)
+		for _, num := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < 1e-12 {
+			break
+		}
+	}
+	return h
+}
+
+// formatMetricValue は指標の値を表示用に整形する｡
+func formatMetricValue(metric string, v float64) string {
+	switch metric {
+	case "average_context_per_call":
+		return humanizeTokens(int64(math.Round(v)))
+	case "cache_hit_ratio":
+		return formatPercent(v)
+	case "cost_per_session":
+		return fmt.Sprintf("$%.2f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// FormatDiffReport は比較結果をテキストに整形する｡
+func FormatDiffReport(report DiffReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== Token Usage Diff ===\n")
+	fmt.Fprintf(&b, "  before: %s\n  after:  %s\n", report.Before, report.After)
+	fmt.Fprintf(&b, "  * p < 0.05, ** p < 0.01 (Welch の t 検定、セッション単位)\n\n")
+	tbl := &textTable{
+		headers: []string{"project", "sessions", "metric", "before", "after", "delta", "p", "sig"},
+		numeric: []bool{false, true, false, true, true, true, true, false},
+	}
+	for _, d := range report.Projects {
+		sessions := fmt.Sprintf("%d→%d", d.BeforeSessions, d.AfterSessions)
+		for i, m := range d.Metrics {
+			project := d.Project
+			if i > 0 {
+				project, sessions = "", ""
+			}
+			delta := "-"
+			if m.Before != 0 {
+				delta = fmt.Sprintf("%+.1f%%", m.DeltaPercent)
+			}
+			p := "-"
+			if m.Significance != "n/a" {
+				p = fmt.Sprintf("%.3f", m.PValue)
+			}
+			row := []string{project, sessions, m.Metric,
+				formatMetricValue(m.Metric, m.Before), formatMetricValue(m.Metric, m.After), delta, p, m.Significance}
+			tbl.add(row, row)
+		}
+	}
+	tbl.render(&b, "  ", 0)
+	return b.String()
+}
+
+// runDiff は diff サブコマンドを実行し、終了コードを返す｡
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	beforeFlag := fset.String("before", "", "比較元の区間 YYYY-MM-DD..YYYY-MM-DD (必須)")
+	afterFlag := fset.String("after", "", "比較先の区間 YYYY-MM-DD..YYYY-MM-DD (必須)")
+	projectsDir := fset.String("dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)")
+	pricingPath := fset.String("pricing", "", "料金表JSONのパス (デフォルト: ~/.claude/"+pricingFileName+" があれば使用)")
+	format := fset.String("format", FormatSummary, "出力形式: summary または json")
+	cachePath := fset.String("cache", "", "スキャン結果のキャッシュファイル (デフォルト: ユーザーキャッシュディレクトリ配下)")
+	noCache := fset.Bool("no-cache", false, "キャッシュを使わずに全ファイルを解析する")
+	fset.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze-tokens diff --before FROM..TO --after FROM..TO [flags]\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if *format != FormatSummary && *format != FormatJSON {
+		fmt.Fprintf(stderr, "不明な出力形式: %s (summary または json を指定)\n", *format)
+		return 2
+	}
+	before, err := ParseDateRange(*beforeFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "--before: %v\n", err)
+		return 2
+	}
+	after, err := ParseDateRange(*afterFlag)
+	if err != nil {
+		fmt.Fprintf(stderr, "--after: %v\n", err)
+		return 2
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(stderr, "ホームディレクトリ取得失敗: %v\n", err)
+		return 1
+	}
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	// 古い方の区間の開始日以降に更新されたファイルだけを読めば足りる
+	oldest := before.Start
+	if after.Start.Before(oldest) {
+		oldest = after.Start
+	}
+	days := int(time.Since(oldest).Hours()/24) + 1
+	results, err := scanWithPricing(pathutil.ResolveProjectsDir(*projectsDir, home), days,
+		ResolvePricingPath(*pricingPath, home), *cachePath, *noCache, resolver, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	report := BuildDiff(results, before, after)
+	if *format == FormatJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "JSON出力失敗: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprint(stdout, FormatDiffReport(report))
+	return 0
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDateRange(t *testing.T) {
+	r, err := ParseDateRange("2026-09-01..2026-09-15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Start.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)) || !r.End.Equal(time.Date(2026, 9, 16, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("range = %v..%v", r.Start, r.End)
+	}
+	if !r.Contains(time.Date(2026, 9, 15, 23, 59, 0, 0, time.Local)) || r.Contains(time.Date(2026, 9, 16, 0, 0, 0, 0, time.Local)) {
+		t.Error("終了日を含み翌日を含まないべき")
+	}
+	if r.String() != "2026-09-01..2026-09-15" {
+		t.Errorf("String = %q", r.String())
+	}
+	for _, s := range []string{"2026-09-01", "2026-09-01..09-15", "2026-09-15..2026-09-01"} {
+		if _, err := ParseDateRange(s); err == nil {
+			t.Errorf("%q はエラーになるべき", s)
+		}
+	}
+}
+
+func TestWelchTTest(t *testing.T) {
+	t.Run("平均の差が大きい", func(t *testing.T) {
+		// t = -5, df = 8 の両側 p 値
+		p, ok := welchTTest([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10})
+		if !ok || math.Abs(p-0.001053) > 1e-5 {
+			t.Errorf("p = %v, ok = %v", p, ok)
+		}
+	})
+	t.Run("分散の異なる標本", func(t *testing.T) {
+		// t ≈ -1.860, df ≈ 4.91
+		p, ok := welchTTest([]float64{10, 12, 11, 13}, []float64{9, 15, 20, 14, 18})
+		if !ok || math.Abs(p-0.1231) > 1e-3 {
+			t.Errorf("p = %v, ok = %v", p, ok)
+		}
+	})
+	t.Run("同じ分布なら有意でない", func(t *testing.T) {
+		p, ok := welchTTest([]float64{1, 2, 3}, []float64{3, 2, 1})
+		if !ok || math.Abs(p-1) > 1e-9 {
+			t.Errorf("p = %v", p)
+		}
+	})
+	t.Run("サンプル不足", func(t *testing.T) {
+		if _, ok := welchTTest([]float64{1}, []float64{1, 2}); ok {
+			t.Error("サンプルが2未満なら ok=false")
+		}
+	})
+}
+
+// diffSession は最初のコールの時刻と合計値を持つセッションを作る｡
+func diffSession(id, proj string, ts time.Time, input, cacheRead int64, calls, messages int, cost float64) SessionResult {
+	return SessionResult{
+		SessionID: id, Project: proj,
+		TotalInputTokens: input, TotalCacheReadTokens: cacheRead,
+		APICallCount: calls, UserMessageCount: messages, CostUSD: cost,
+		Calls: []APICall{{Timestamp: ts}},
+	}
+}
+
+func TestBuildDiff(t *testing.T) {
+	before, _ := ParseDateRange("2026-09-01..2026-09-15")
+	after, _ := ParseDateRange("2026-09-16..2026-09-30")
+	b := time.Date(2026, 9, 5, 10, 0, 0, 0, time.Local)
+	a := time.Date(2026, 9, 20, 10, 0, 0, 0, time.Local)
+	results := []SessionResult{
+		diffSession("b1", "alpha", b, 1000, 9000, 10, 2, 1),
+		diffSession("b2", "alpha", b, 1200, 8800, 10, 2, 1.2),
+		diffSession("b3", "alpha", b, 900, 9100, 10, 2, 0.8),
+		diffSession("a1", "alpha", a, 500, 4500, 10, 5, 0.5),
+		diffSession("a2", "alpha", a, 600, 4400, 10, 5, 0.6),
+		diffSession("a3", "alpha", a, 400, 4600, 10, 5, 0.4),
+		diffSession("x1", "beta", a, 100, 0, 1, 1, 0),
+		// 区間外のセッションは含めない
+		diffSession("old", "alpha", time.Date(2026, 8, 1, 0, 0, 0, 0, time.Local), 1, 1, 1, 1, 100),
+		// sidechain は起動元のセッションに合算する
+		{SessionID: "agent-1", Project: "alpha", IsSidechain: true, ParentSessionID: "a1", TotalInputTokens: 0, TotalCacheReadTokens: 0, APICallCount: 0, CostUSD: 0.1,
+			Calls: []APICall{{Timestamp: a.Add(time.Minute)}}},
+	}
+	report := BuildDiff(results, before, after)
+	if len(report.Projects) != 3 || report.Projects[0].Project != allProjects || report.Projects[1].Project != "alpha" {
+		t.Fatalf("projects = %+v", report.Projects)
+	}
+	alpha := report.Projects[1]
+	if alpha.BeforeSessions != 3 || alpha.AfterSessions != 3 || math.Abs(alpha.BeforeCostUSD-3) > 1e-9 || math.Abs(alpha.AfterCostUSD-1.6) > 1e-9 {
+		t.Errorf("alpha = %+v", alpha)
+	}
+	metrics := make(map[string]MetricDiff)
+	for _, m := range alpha.Metrics {
+		metrics[m.Metric] = m
+	}
+	if m := metrics["average_context_per_call"]; m.Before != 1000 || m.After != 500 || m.DeltaPercent != -50 || m.Significance != "**" {
+		t.Errorf("average_context_per_call = %+v", m)
+	}
+	if m := metrics["calls_per_message"]; m.Before != 5 || m.After != 2 || m.Significance != "**" {
+		t.Errorf("calls_per_message = %+v", m)
+	}
+	if m := metrics["cache_hit_ratio"]; m.Significance == "n/a" {
+		t.Errorf("cache_hit_ratio = %+v", m)
+	}
+	if beta := report.Projects[2]; beta.Metrics[0].Significance != "n/a" || beta.BeforeSessions != 0 {
+		t.Errorf("beta = %+v", beta)
+	}
+
+	text := FormatDiffReport(report)
+	if !strings.Contains(text, "2026-09-16..2026-09-30") || !strings.Contains(text, "3→3") || !strings.Contains(text, "-50.0%") {
+		t.Errorf("text = %s", text)
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	dir := t.TempDir()
+	day := func(offset int) string {
+		return time.Now().AddDate(0, 0, offset).UTC().Format(time.RFC3339)
+	}
+	writeTestJSONL(t, dir, "s1.jsonl",
+		`{"type":"assistant","cwd":"/Users/test/alpha","sessionId":"s1","timestamp":"`+day(-5)+`","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":5000,"output_tokens":100}}}
+`)
+	writeTestJSONL(t, dir, "s2.jsonl",
+		`{"type":"assistant","cwd":"/Users/test/alpha","sessionId":"s2","timestamp":"`+day(0)+`","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":1000,"output_tokens":100}}}
+`)
+	rng := func(from, to int) string {
+		return time.Now().AddDate(0, 0, from).Format(time.DateOnly) + ".." + time.Now().AddDate(0, 0, to).Format(time.DateOnly)
+	}
+
+	t.Run("JSON出力", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runDiff([]string{"--dir", dir, "--before", rng(-7, -3), "--after", rng(-2, 0), "--format", "json"}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("code = %d, stderr = %s", code, stderr.String())
+		}
+		var report DiffReport
+		if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Projects) != 2 || report.Projects[1].Project != "alpha" || report.Projects[1].Metrics[0].Before != 5000 {
+			t.Errorf("report = %+v", report)
+		}
+	})
+
+	t.Run("区間の指定誤りは使い方エラー", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runDiff([]string{"--dir", dir, "--before", "2026-09-01"}, &stdout, &stderr); code != 2 {
+			t.Errorf("code = %d", code)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReportFilter はレポートの対象を絞り込む条件｡指定した条件は ReportSummary にそのまま記録する｡
+// Model・Since・Until はセッション単位ではなく API コール単位で絞り込み、
+// 条件に合うコールだけから token 数やツール使用を集計し直す｡
+type ReportFilter struct {
+	// Project はプロジェクトの表示名・ID・ルートディレクトリのいずれか｡
+	Project string `json:"project,omitempty"`
+	Model   string `json:"model,omitempty"`
+	// Since と Until は YYYY-MM-DD 形式で、どちらの日も含む｡
+	Since string `json:"since,omitempty"`
+	Until string `json:"until,omitempty"`
+	// Session はセッション ID の前方一致｡sidechain は起動元のセッション ID でも一致する｡
+	Session string `json:"session,omitempty"`
+	// MinTokens は実効コンテキストと output の合計の下限｡ほかの条件で絞り込んだ後の値で判定する｡
+	MinTokens int64 `json:"min_tokens,omitempty"`
+
+	since time.Time
+	until time.Time
+}
+
+// filterFlags はフィルタのコマンドラインフラグ｡
+type filterFlags struct {
+	project, model, since, until, session *string
+	minTokens                             *int64
+}
+
+// registerFilterFlags はフィルタのフラグを fs に登録する｡
+func registerFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		project:   fs.String("project", "", "対象プロジェクト (表示名・ID・ルートディレクトリ)"),
+		model:     fs.String("model", "", "対象モデル (例: claude-opus-4-6)"),
+		since:     fs.String("since", "", "開始日 YYYY-MM-DD (指定時は --days より優先)"),
+		until:     fs.String("until", "", "終了日 YYYY-MM-DD (その日を含む)"),
+		session:   fs.String("session", "", "対象セッション ID (前方一致)"),
+		minTokens: fs.Int64("min-tokens", 0, "実効コンテキストと output の合計がこの値以上のセッションのみ"),
+	}
+}
+
+// build はフラグの値からフィルタを作る｡条件がなければ nil を返す｡
+func (f *filterFlags) build() (*ReportFilter, error) {
+	filter := &ReportFilter{
+		Project:   *f.project,
+		Model:     *f.model,
+		Since:     *f.since,
+		Until:     *f.until,
+		Session:   *f.session,
+		MinTokens: *f.minTokens,
+	}
+	if err := filter.parseDates(); err != nil {
+		return nil, err
+	}
+	if *filter == (ReportFilter{}) {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+func (f *ReportFilter) parseDates() error {
+	var err error
+	if f.Since != "" {
+		if f.since, err = time.ParseInLocation(time.DateOnly, f.Since, time.Local); err != nil {
+			return fmt.Errorf("--since のパースに失敗: %w", err)
+		}
+	}
+	if f.Until != "" {
+		if f.until, err = time.ParseInLocation(time.DateOnly, f.Until, time.Local); err != nil {
+			return fmt.Errorf("--until のパースに失敗: %w", err)
+		}
+		f.until = f.until.AddDate(0, 0, 1)
+	}
+	if !f.since.IsZero() && !f.until.IsZero() && !f.since.Before(f.until) {
+		return fmt.Errorf("--until は --since 以降の日付を指定してください")
+	}
+	return nil
+}
+
+// ScanDays はファイルの mtime で絞り込む日数を返す｡Since があれば --days より優先する｡
+// Since が未来の日付でも当日分は走査するよう、1日以上を返す｡
+func (f *ReportFilter) ScanDays(days int, now time.Time) int {
+	if f == nil || f.since.IsZero() {
+		return days
+	}
+	return max(int(now.Sub(f.since).Hours()/24)+1, 1)
+}
+
+// ScanProject は走査時にプロジェクトディレクトリを絞り込むプロジェクトを返す｡条件がなければ空文字｡
+func (f *ReportFilter) ScanProject() string {
+	if f == nil {
+		return ""
+	}
+	return f.Project
+}
+
+// WindowStart は時系列の開始時刻を返す｡Since がなければ now から days 日前｡
+func (f *ReportFilter) WindowStart(days int, now time.Time) time.Time {
+	if f == nil || f.since.IsZero() {
+		return now.AddDate(0, 0, -days)
+	}
+	return f.since
+}
+
+// Apply は条件に合うセッションを返す｡f が nil なら results をそのまま返す｡
+func (f *ReportFilter) Apply(results []SessionResult) []SessionResult {
+	if f == nil {
+		return results
+	}
+	filtered := make([]SessionResult, 0, len(results))
+	for _, r := range results {
+		if !f.matchSession(r) {
+			continue
+		}
+		if f.Model != "" || !f.since.IsZero() || !f.until.IsZero() {
+			r = f.restrictCalls(r)
+			if r.APICallCount == 0 {
+				continue
+			}
+		}
+		if f.MinTokens > 0 && r.ContextTokens()+r.TotalOutputTokens < f.MinTokens {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+func (f *ReportFilter) matchSession(r SessionResult) bool {
+	if f.Project != "" && f.Project != r.Project && f.Project != r.ProjectID && f.Project != r.ProjectRoot {
+		return false
+	}
+	if f.Session != "" && !strings.HasPrefix(r.SessionID, f.Session) &&
+		!(r.IsSidechain && strings.HasPrefix(r.ParentSessionID, f.Session)) {
+		return false
+	}
+	return true
+}
+
+func (f *ReportFilter) matchCall(c APICall) bool {
+	if f.Model != "" && c.Model != f.Model {
+		return false
+	}
+	if !f.since.IsZero() && c.Timestamp.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !c.Timestamp.Before(f.until) {
+		return false
+	}
+	return true
+}
+
+// restrictCalls は条件に合う API コールだけから token 数・モデル別集計・ツール使用・スキル使用を集計し直したコピーを返す｡
+// ループ検出に使うツール呼び出しの履歴も条件に合うコールのものだけを残す｡ターン番号はセッション全体での番号のまま｡
+// user メッセージ数・スラッシュコマンド・tool_result のサイズはコール単位で記録していないため、セッション全体の値のまま残す｡
+func (f *ReportFilter) restrictCalls(r SessionResult) SessionResult {
+	calls := r.Calls
+	matched := make([]bool, len(calls))
+	r.Calls = nil
+	r.APICallCount = 0
+	r.TotalInputTokens, r.TotalOutputTokens, r.TotalCacheCreationTokens, r.TotalCacheReadTokens = 0, 0, 0, 0
+	r.CostUSD = 0
+	r.ModelUsage = make(map[string]ModelTokens)
+	r.ToolUsage = make(map[string]int)
+	for i, c := range calls {
+		if !f.matchCall(c) {
+			continue
+		}
+		matched[i] = true
+		r.Calls = append(r.Calls, c)
+		r.APICallCount++
+		r.CostUSD += c.CostUSD
+		addUsage(&r, c.Model, c.Usage, true)
+		for _, tool := range c.Tools {
+			r.ToolUsage[tool]++
+		}
+	}
+	toolCalls := r.toolCalls
+	r.toolCalls = nil
+	r.SkillUsage = nil
+	for _, tc := range toolCalls {
+		if tc.Turn < 1 || tc.Turn > len(calls) || !matched[tc.Turn-1] {
+			continue
+		}
+		r.toolCalls = append(r.toolCalls, tc)
+		if tc.Name == "Skill" && tc.Detail != "" {
+			if r.SkillUsage == nil {
+				r.SkillUsage = make(map[string]int)
+			}
+			r.SkillUsage[tc.Detail]++
+		}
+	}
+	var compactions []CompactionEvent
+	for _, c := range r.Compactions {
+		if f.matchCall(APICall{Model: f.Model, Timestamp: c.Timestamp}) {
+			compactions = append(compactions, c)
+		}
+	}
+	r.Compactions = compactions
+	r.updateCacheMetrics()
+	return r
+}
+
+// String は指定された条件を "project=api model=claude-opus-4-6" の形式で返す｡
+func (f *ReportFilter) String() string {
+	if f == nil {
+		return ""
+	}
+	var parts []string
+	for _, kv := range [][2]string{
+		{"project", f.Project}, {"model", f.Model}, {"since", f.Since}, {"until", f.Until}, {"session", f.Session},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	if f.MinTokens > 0 {
+		parts = append(parts, fmt.Sprintf("min_tokens=%d", f.MinTokens))
+	}
+	return strings.Join(parts, " ")
+}
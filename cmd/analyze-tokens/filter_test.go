@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildFilter(t *testing.T, args ...string) (*ReportFilter, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	ff := registerFilterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return ff.build()
+}
+
+func TestReportFilterFlags(t *testing.T) {
+	if f, err := buildFilter(t); f != nil || err != nil {
+		t.Errorf("条件がなければ nil: %+v, %v", f, err)
+	}
+	f, err := buildFilter(t, "--project", "api", "--since", "2026-09-01", "--until", "2026-09-15", "--min-tokens", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.String(); got != "project=api since=2026-09-01 until=2026-09-15 min_tokens=1000" {
+		t.Errorf("String = %q", got)
+	}
+	now := time.Date(2026, 9, 30, 12, 0, 0, 0, time.Local)
+	if days := f.ScanDays(7, now); days != 30 {
+		t.Errorf("ScanDays = %d, want 30", days)
+	}
+	if days := (*ReportFilter)(nil).ScanDays(7, now); days != 7 {
+		t.Errorf("nil ScanDays = %d", days)
+	}
+	future, err := buildFilter(t, "--since", "2026-10-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days := future.ScanDays(7, now); days != 1 {
+		t.Errorf("未来の --since の ScanDays = %d, want 1", days)
+	}
+	for _, args := range [][]string{
+		{"--since", "2026/09/01"},
+		{"--since", "2026-09-15", "--until", "2026-09-01"},
+	} {
+		if _, err := buildFilter(t, args...); err == nil {
+			t.Errorf("%v はエラーになるべき", args)
+		}
+	}
+}
+
+func TestReportFilterApply(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 9, d, 10, 0, 0, 0, time.Local) }
+	results := []SessionResult{
+		{SessionID: "aaa-1", Project: "api", ProjectID: "github.com/org/api", APICallCount: 3,
+			TotalInputTokens: 600, TotalOutputTokens: 30,
+			ModelUsage: map[string]ModelTokens{"claude-opus-4-6": {InputTokens: 400, CallCount: 2}, "claude-haiku-4-5": {InputTokens: 200, CallCount: 1}},
+			ToolUsage:  map[string]int{"Read": 2, "Bash": 1, "Skill": 2},
+			SkillUsage: map[string]int{"git-workflow": 1, "tdd": 1},
+			Calls: []APICall{
+				{Timestamp: day(1), Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 100, OutputTokens: 10}, Tools: []string{"Read", "Skill"}},
+				{Timestamp: day(10), Model: "claude-haiku-4-5", Usage: tokenUsage{InputTokens: 200, OutputTokens: 10}, Tools: []string{"Read", "Skill"}},
+				{Timestamp: day(20), Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 300, OutputTokens: 10}, Tools: []string{"Bash"}},
+			},
+			toolCalls: []toolCall{
+				{Turn: 1, Name: "Read", Detail: "/repo/a.go"},
+				{Turn: 1, Name: "Skill", Detail: "git-workflow"},
+				{Turn: 2, Name: "Read", Detail: "/repo/a.go"},
+				{Turn: 2, Name: "Skill", Detail: "tdd"},
+				{Turn: 3, Name: "Bash", Detail: "make test", Failed: true},
+			},
+			Compactions: []CompactionEvent{{Timestamp: day(1)}, {Timestamp: day(20)}}},
+		{SessionID: "bbb-1", Project: "web", APICallCount: 1, TotalInputTokens: 50,
+			ModelUsage: map[string]ModelTokens{"claude-opus-4-6": {InputTokens: 50, CallCount: 1}},
+			Calls:      []APICall{{Timestamp: day(5), Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 50}}}},
+		{SessionID: "agent-x", Project: "web", IsSidechain: true, ParentSessionID: "bbb-1", APICallCount: 1, TotalInputTokens: 10,
+			Calls: []APICall{{Timestamp: day(5), Model: "claude-haiku-4-5", Usage: tokenUsage{InputTokens: 10}}}},
+	}
+	ids := func(rs []SessionResult) string {
+		var s []string
+		for _, r := range rs {
+			s = append(s, r.SessionID)
+		}
+		return strings.Join(s, ",")
+	}
+
+	t.Run("プロジェクトは表示名かIDで指定", func(t *testing.T) {
+		for _, p := range []string{"api", "github.com/org/api"} {
+			if got := ids((&ReportFilter{Project: p}).Apply(results)); got != "aaa-1" {
+				t.Errorf("%s: %s", p, got)
+			}
+		}
+	})
+
+	t.Run("セッションは前方一致でsidechainは起動元でも一致", func(t *testing.T) {
+		if got := ids((&ReportFilter{Session: "bbb"}).Apply(results)); got != "bbb-1,agent-x" {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("モデルはコール単位で集計し直す", func(t *testing.T) {
+		got := (&ReportFilter{Model: "claude-haiku-4-5"}).Apply(results)
+		if ids(got) != "aaa-1,agent-x" {
+			t.Fatalf("got %s", ids(got))
+		}
+		r := got[0]
+		if r.APICallCount != 1 || r.TotalInputTokens != 200 || len(r.ModelUsage) != 1 || r.ToolUsage["Read"] != 1 || r.ToolUsage["Bash"] != 0 {
+			t.Errorf("aaa-1 = %+v", r)
+		}
+		if len(r.SkillUsage) != 1 || r.SkillUsage["tdd"] != 1 {
+			t.Errorf("SkillUsage = %v", r.SkillUsage)
+		}
+		// ツール呼び出しの履歴も条件に合うコールのものだけを残し、ターン番号は変えない
+		if len(r.toolCalls) != 2 || r.toolCalls[0].Turn != 2 || r.toolCalls[1].Name != "Skill" {
+			t.Errorf("toolCalls = %+v", r.toolCalls)
+		}
+		if results[0].APICallCount != 3 || len(results[0].ModelUsage) != 2 {
+			t.Error("元の結果を変更してはいけない")
+		}
+	})
+
+	t.Run("期間はコールの時刻で絞り込む", func(t *testing.T) {
+		f, err := buildFilter(t, "--since", "2026-09-05", "--until", "2026-09-10")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := f.Apply(results)
+		if ids(got) != "aaa-1,bbb-1,agent-x" || got[0].TotalInputTokens != 200 || len(got[0].Compactions) != 0 {
+			t.Errorf("got %+v", got)
+		}
+	})
+
+	t.Run("min-tokensは絞り込み後の合計で判定", func(t *testing.T) {
+		if got := ids((&ReportFilter{MinTokens: 100}).Apply(results)); got != "aaa-1" {
+			t.Errorf("got %s", got)
+		}
+		if got := ids((&ReportFilter{Model: "claude-haiku-4-5", MinTokens: 300}).Apply(results)); got != "" {
+			t.Errorf("got %s", got)
+		}
+	})
+
+	t.Run("条件はサマリに記録される", func(t *testing.T) {
+		f := &ReportFilter{Project: "api"}
+		report := GenerateReport(f.Apply(results), 10)
+		report.Summary.Filter = f
+		if text := FormatReportSummary(report); !strings.Contains(text, "Filter: project=api") {
+			t.Errorf("text = %s", text)
+		}
+	})
+}
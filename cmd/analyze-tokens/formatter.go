@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 出力形式｡
+const (
+	FormatJSON     = "json"
+	FormatSummary  = "summary"
+	FormatTable    = "table"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+)
+
+// summaryRowLimit は summary 形式で各表に表示する最大行数｡
+const summaryRowLimit = 5
+
+// ValidateFormat は --format の値を検証する｡
+// --group-by 指定時の時系列は json と csv のみ対応する｡
+func ValidateFormat(format string, timeSeries bool) error {
+	switch format {
+	case FormatJSON, FormatCSV:
+		return nil
+	case FormatSummary, FormatTable, FormatMarkdown:
+		if !timeSeries {
+			return nil
+		}
+		return fmt.Errorf("--group-by と併用できる出力形式は json または csv です: %s", format)
+	default:
+		return fmt.Errorf("不明な出力形式: %s (json, summary, table, csv, markdown のいずれか)", format)
+	}
+}
+
+// humanizeTokens は token 数を 12.3K / 4.56M / 1.20B の形式に丸める｡
+func humanizeTokens(n int64) string {
+	abs := math.Abs(float64(n))
+	switch {
+	case abs >= 1e9:
+		return fmt.Sprintf("%.2fB", float64(n)/1e9)
+	case abs >= 1e6:
+		return fmt.Sprintf("%.2fM", float64(n)/1e6)
+	case abs >= 1e3:
+		return fmt.Sprintf("%.1fK", float64(n)/1e3)
+	default:
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+func formatPercent(ratio float64) string {
+	return fmt.Sprintf("%.1f%%", ratio*100)
+}
+
+func formatCost(usd float64) string {
+	if usd == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// textTable は列揃えして出力する表｡numeric の列は右寄せする｡
+type textTable struct {
+	title   string
+	headers []string
+	numeric []bool
+	rows    [][]string
+	// raw は CSV 用の丸めていない値｡
+	raw [][]string
+}
+
+func (t *textTable) add(row, raw []string) {
+	t.rows = append(t.rows, row)
+	t.raw = append(t.raw, raw)
+}
+
+// render は列幅を揃えたテキストを返す｡limit > 0 ならその行数までに切り詰める｡
+func (t *textTable) render(b *strings.Builder, indent string, limit int) {
+	rows := t.rows
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+	}
+	widths := make([]int, len(t.headers))
+	for i, h := range t.headers {
+		widths[i] = utf8.RuneCountInString(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	line := func(cells []string) {
+		b.WriteString(indent)
+		for i, cell := range cells {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			if t.numeric[i] {
+				b.WriteString(pad + cell)
+			} else if i < len(cells)-1 {
+				b.WriteString(cell + pad)
+			} else {
+				b.WriteString(cell)
+			}
+		}
+		b.WriteString("\n")
+	}
+	line(t.headers)
+	for _, row := range rows {
+		line(row)
+	}
+	if len(t.rows) > len(rows) {
+		fmt.Fprintf(b, "%s... and %d more (use --format table for full list)\n", indent, len(t.rows)-len(rows))
+	}
+}
+
+func (t *textTable) renderMarkdown(b *strings.Builder) {
+	fmt.Fprintf(b, "## %s\n\n", t.title)
+	fmt.Fprintf(b, "| %s |\n", strings.Join(t.headers, " | "))
+	seps := make([]string, len(t.headers))
+	for i := range seps {
+		seps[i] = "---"
+		if t.numeric[i] {
+			seps[i] = "---:"
+		}
+	}
+	fmt.Fprintf(b, "| %s |\n", strings.Join(seps, " | "))
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = strings.ReplaceAll(c, "|", `\|`)
+		}
+		fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+	}
+	b.WriteString("\n")
+}
+
+func i64(n int64) string { return strconv.FormatInt(n, 10) }
+
+func ratioString(r float64) string { return strconv.FormatFloat(r, 'f', 3, 64) }
+
+func costString(usd float64) string { return strconv.FormatFloat(usd, 'f', 4, 64) }
+
+// reportTables はレポートの Top セッション・プロジェクト・モデルの表を作る｡
+func reportTables(r Report) []*textTable {
+	sessions := &textTable{
+		title:   "Top Sessions",
+		headers: []string{"session", "project", "model", "calls", "input", "output", "avg ctx", "cache hit", "cost"},
+		numeric: []bool{false, false, false, true, true, true, true, true, true},
+	}
+	for _, s := range r.TopSessions {
+		label := shortID(s.SessionID)
+		switch {
+		case s.IsSidechain:
+			label += " (subagent)"
+		case s.SubagentSessions > 0:
+			label += fmt.Sprintf(" +%d agents", s.SubagentSessions)
+		}
+		sessions.add(
+			[]string{label, s.Project, s.Model, strconv.Itoa(s.APICallCount),
+				humanizeTokens(s.TotalInputTokens), humanizeTokens(s.TotalOutputTokens),
+				humanizeTokens(s.AverageContextPerCall), formatPercent(s.CacheHitRatio), formatCost(s.CostUSD)},
+			[]string{s.SessionID, s.Project, s.Model, strconv.Itoa(s.APICallCount),
+				i64(s.TotalInputTokens), i64(s.TotalOutputTokens),
+				i64(s.AverageContextPerCall), ratioString(s.CacheHitRatio), costString(s.CostUSD)},
+		)
+	}
+
+	projects := &textTable{
+		title:   "Projects",
+		headers: []string{"project", "sessions", "calls", "input", "output", "avg ctx", "cache hit", "cost"},
+		numeric: []bool{false, true, true, true, true, true, true, true},
+	}
+	for _, p := range r.ProjectSummary {
+		projects.add(
+			[]string{p.Project, strconv.Itoa(p.SessionCount), strconv.Itoa(p.TotalAPICalls),
+				humanizeTokens(p.TotalInputTokens), humanizeTokens(p.TotalOutputTokens),
+				humanizeTokens(p.AverageContextPerCall), formatPercent(p.CacheHitRatio), formatCost(p.CostUSD)},
+			[]string{p.Project, strconv.Itoa(p.SessionCount), strconv.Itoa(p.TotalAPICalls),
+				i64(p.TotalInputTokens), i64(p.TotalOutputTokens),
+				i64(p.AverageContextPerCall), ratioString(p.CacheHitRatio), costString(p.CostUSD)},
+		)
+	}
+
+	models := &textTable{
+		title:   "Models",
+		headers: []string{"model", "calls", "input", "output", "avg ctx", "cache hit", "cost"},
+		numeric: []bool{false, true, true, true, true, true, true},
+	}
+	for _, m := range r.ModelSummary {
+		models.add(
+			[]string{m.Model, strconv.Itoa(m.CallCount),
+				humanizeTokens(m.InputTokens), humanizeTokens(m.OutputTokens),
+				humanizeTokens(m.AverageContextPerCall), formatPercent(m.CacheHitRatio), formatCost(m.CostUSD)},
+			[]string{m.Model, strconv.Itoa(m.CallCount),
+				i64(m.InputTokens), i64(m.OutputTokens),
+				i64(m.AverageContextPerCall), ratioString(m.CacheHitRatio), costString(m.CostUSD)},
+		)
+	}
+	return []*textTable{sessions, projects, models}
+}
+
+// shortID は表示用にセッションIDを先頭8文字に切り詰める｡
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// warningTarget は警告の対象(プロジェクト・セッション・ツール・モデル・補足・ターン範囲)を返す｡
+func warningTarget(w Warning) string {
+	var parts []string
+	if w.Project != "" {
+		parts = append(parts, w.Project)
+	}
+	if w.SessionID != "" {
+		parts = append(parts, shortID(w.SessionID))
+	}
+	if w.Tool != "" {
+		parts = append(parts, w.Tool)
+	}
+	if w.Model != "" {
+		parts = append(parts, w.Model)
+	}
+	if w.Detail != "" {
+		parts = append(parts, truncateDetail(w.Detail, 60))
+	}
+	if turns := formatTurnRange(w); turns != "" {
+		parts = append(parts, turns)
+	}
+	if len(parts) == 0 {
+		return "global"
+	}
+	return strings.Join(parts, " / ")
+}
+
+func writeWarnings(b *strings.Builder, warnings []Warning) {
+	for _, w := range warnings {
+		fmt.Fprintf(b, "  [%s] %s: %s (value %d, threshold %d)\n", w.Type, warningTarget(w), w.Message, w.Value, w.Threshold)
+		if w.Recommendation != "" {
+			fmt.Fprintf(b, "    -> %s\n", w.Recommendation)
+		}
+	}
+}
+
+func writeSummaryHeader(b *strings.Builder, s ReportSummary) {
+	fmt.Fprintf(b, "Period: %d days | Sessions: %d | API Calls: %d\n", s.Days, s.TotalSessions, s.TotalAPICalls)
+	if s.Filter != nil {
+		fmt.Fprintf(b, "Filter: %s\n", s.Filter)
+	}
+	fmt.Fprintf(b, "Tokens: %s input / %s output / %s cache read / %s cache write\n",
+		humanizeTokens(s.TotalInputTokens), humanizeTokens(s.TotalOutputTokens),
+		humanizeTokens(s.TotalCacheReadTokens), humanizeTokens(s.TotalCacheCreationTokens))
+	fmt.Fprintf(b, "Avg context/call: %s | Cache hit: %s", humanizeTokens(s.AverageContextPerCall), formatPercent(s.CacheHitRatio))
+	if s.TotalCostUSD > 0 {
+		fmt.Fprintf(b, " | Cost: %s", formatCost(s.TotalCostUSD))
+	}
+	fmt.Fprintln(b)
+}
+
+// FormatReportSummary はレポートを警告優先のコンパクトなテキストに変換する｡
+// 各表は先頭 summaryRowLimit 行のみ表示する｡
+func FormatReportSummary(r Report) string {
+	return formatText(r, summaryRowLimit)
+}
+
+// FormatReportTable はレポートの全行を列揃えしたテキストに変換する｡
+func FormatReportTable(r Report) string {
+	return formatText(r, 0)
+}
+
+func formatText(r Report, limit int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== Token Usage Report ===\n")
+	writeSummaryHeader(&b, r.Summary)
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintf(&b, "\n[WARNINGS] %d\n", len(r.Warnings))
+		writeWarnings(&b, r.Warnings)
+	}
+	for _, t := range reportTables(r) {
+		if len(t.rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n[%s]\n", strings.ToUpper(t.title))
+		t.render(&b, "  ", limit)
+	}
+	return b.String()
+}
+
+// FormatReportMarkdown はレポートを Markdown に変換する｡
+func FormatReportMarkdown(r Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Token Usage Report\n\n")
+	s := r.Summary
+	fmt.Fprintf(&b, "- Period: %d days\n- Sessions: %d\n- API calls: %d\n", s.Days, s.TotalSessions, s.TotalAPICalls)
+	if s.Filter != nil {
+		fmt.Fprintf(&b, "- Filter: `%s`\n", s.Filter)
+	}
+	fmt.Fprintf(&b, "- Tokens: %s input / %s output / %s cache read / %s cache write\n",
+		humanizeTokens(s.TotalInputTokens), humanizeTokens(s.TotalOutputTokens),
+		humanizeTokens(s.TotalCacheReadTokens), humanizeTokens(s.TotalCacheCreationTokens))
+	fmt.Fprintf(&b, "- Avg context/call: %s\n- Cache hit: %s\n", humanizeTokens(s.AverageContextPerCall), formatPercent(s.CacheHitRatio))
+	if s.TotalCostUSD > 0 {
+		fmt.Fprintf(&b, "- Cost: %s\n", formatCost(s.TotalCostUSD))
+	}
+	b.WriteString("\n")
+
+	if len(r.Warnings) > 0 {
+		fmt.Fprintf(&b, "## Warnings\n\n")
+		for _, w := range r.Warnings {
+			fmt.Fprintf(&b, "- **%s** (%s): %s (value %d, threshold %d)", w.Type, warningTarget(w), w.Message, w.Value, w.Threshold)
+			if w.Recommendation != "" {
+				fmt.Fprintf(&b, "<br>%s", w.Recommendation)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	for _, t := range reportTables(r) {
+		if len(t.rows) > 0 {
+			t.renderMarkdown(&b)
+		}
+	}
+	return b.String()
+}
+
+// WriteReportCSV は警告と各表を CSV で書き出す｡
+// 先頭列を表の名前とし、表ごとにヘッダ行を出力する｡数値は丸めない｡
+func WriteReportCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{{"warnings", "type", "project", "session_id", "tool", "model", "detail", "turn_start", "turn_end", "value", "threshold", "message"}}
+	for _, wn := range r.Warnings {
+		records = append(records, []string{"warnings", wn.Type, wn.Project, wn.SessionID, wn.Tool, wn.Model, wn.Detail,
+			strconv.Itoa(wn.TurnStart), strconv.Itoa(wn.TurnEnd), i64(wn.Value), i64(wn.Threshold), wn.Message})
+	}
+	for _, t := range reportTables(r) {
+		section := strings.ReplaceAll(strings.ToLower(t.title), " ", "_")
+		header := []string{section}
+		for _, h := range t.headers {
+			header = append(header, strings.ReplaceAll(h, " ", "_"))
+		}
+		records = append(records, header)
+		for _, row := range t.raw {
+			records = append(records, append([]string{section}, row...))
+		}
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestHumanizeTokens(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{12_345, "12.3K"},
+		{4_560_000, "4.56M"},
+		{1_200_000_000, "1.20B"},
+		{-15_000, "-15.0K"},
+	}
+	for _, tt := range tests {
+		if got := humanizeTokens(tt.n); got != tt.want {
+			t.Errorf("humanizeTokens(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, f := range []string{"json", "summary", "table", "csv", "markdown"} {
+		if err := ValidateFormat(f, false); err != nil {
+			t.Errorf("ValidateFormat(%q) = %v", f, err)
+		}
+	}
+	if err := ValidateFormat("summary", true); err == nil {
+		t.Error("--group-by と summary の併用はエラーになるべき")
+	}
+	if err := ValidateFormat("csv", true); err != nil {
+		t.Errorf("--group-by と csv は併用できる: %v", err)
+	}
+	if err := ValidateFormat("yaml", false); err == nil {
+		t.Error("不明な形式はエラーになるべき")
+	}
+}
+
+func TestWarningTarget(t *testing.T) {
+	// Resolver が同名のプロジェクトを区別した表示名をそのまま使う
+	for _, project := range []string{"a/api", "b/api"} {
+		if got := warningTarget(Warning{Project: project, Tool: "Read"}); got != project+" / Read" {
+			t.Errorf("warningTarget(%q) = %q", project, got)
+		}
+	}
+	if got := warningTarget(Warning{}); got != "global" {
+		t.Errorf("warningTarget = %q, want global", got)
+	}
+}
+
+func formatterTestReport() Report {
+	results := []SessionResult{
+		{SessionID: "0123456789abcdef", Project: "alpha", Model: "claude-opus-4-6", TotalInputTokens: 1_500_000, APICallCount: 10, UserMessageCount: 1},
+		{SessionID: "s2", Project: "beta", Model: "claude-opus-4-6", TotalInputTokens: 20_000, APICallCount: 10, UserMessageCount: 2},
+	}
+	for i := range results {
+		results[i].ModelUsage = map[string]ModelTokens{
+			results[i].Model: {InputTokens: results[i].TotalInputTokens, CallCount: results[i].APICallCount},
+		}
+	}
+	report := GenerateReport(results, 10)
+	report.Summary.Days = 7
+	return report
+}
+
+func TestFormatReportSummary(t *testing.T) {
+	out := FormatReportSummary(formatterTestReport())
+
+	for _, want := range []string{
+		"=== Token Usage Report ===",
+		"Period: 7 days | Sessions: 2 | API Calls: 20",
+		"Tokens: 1.52M input",
+		"[WARNINGS] 2",
+		"[global_high_avg] global:",
+		"[high_avg_input] alpha:",
+		"    -> ",
+		"[TOP SESSIONS]",
+		"01234567  alpha",
+		"[PROJECTS]",
+		"[MODELS]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれていない:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "[WARNINGS]") > strings.Index(out, "[TOP SESSIONS]") {
+		t.Errorf("警告は表より先に出力されるべき:\n%s", out)
+	}
+}
+
+func TestTextTableRender(t *testing.T) {
+	tbl := &textTable{headers: []string{"name", "count"}, numeric: []bool{false, true}}
+	for _, r := range [][]string{{"alpha", "1"}, {"b", "1000"}, {"c", "2"}} {
+		tbl.add(r, r)
+	}
+
+	var b strings.Builder
+	tbl.render(&b, "", 0)
+	want := "name   count\nalpha      1\nb       1000\nc          2\n"
+	if b.String() != want {
+		t.Errorf("render =\n%q\nwant\n%q", b.String(), want)
+	}
+
+	b.Reset()
+	tbl.render(&b, "", 2)
+	if !strings.Contains(b.String(), "... and 1 more") {
+		t.Errorf("limit指定時は残り件数を表示すべき:\n%s", b.String())
+	}
+}
+
+func TestFormatReportMarkdown(t *testing.T) {
+	out := FormatReportMarkdown(formatterTestReport())
+	for _, want := range []string{
+		"# Token Usage Report",
+		"## Warnings",
+		"- **high_avg_input** (alpha)",
+		"## Projects",
+		"| project | sessions | calls |",
+		"| --- | ---: | ---: |",
+		"| alpha | 1 | 10 | 1.50M |",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q が含まれていない:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteReportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReportCSV(&buf, formatterTestReport()); err != nil {
+		t.Fatalf("WriteReportCSV失敗: %v", err)
+	}
+	r := csv.NewReader(&buf)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("CSVパース失敗: %v", err)
+	}
+
+	sections := map[string]int{}
+	var alpha []string
+	for _, rec := range records {
+		sections[rec[0]]++
+		if rec[0] == "projects" && rec[1] == "alpha" {
+			alpha = rec
+		}
+	}
+	// ヘッダ行 + データ行
+	if sections["warnings"] != 3 || sections["top_sessions"] != 3 || sections["projects"] != 3 || sections["models"] != 2 {
+		t.Errorf("sections = %v", sections)
+	}
+	if len(alpha) < 5 || alpha[4] != "1500000" {
+		t.Errorf("CSVの数値は丸めない: %v", alpha)
+	}
+}
+
+func TestWriteReportWarningsOnly(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeReport(&buf, formatterTestReport(), FormatSummary, true); err != nil {
+		t.Fatalf("writeReport失敗: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "[WARNINGS]") || strings.Contains(out, "[PROJECTS]") {
+		t.Errorf("warnings-only では表を出力しない:\n%s", out)
+	}
+}
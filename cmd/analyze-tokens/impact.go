@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// skillListingOverheadBytes はスキル1つをシステムプロンプトの一覧に載せる際の name と description 以外の分｡
+const skillListingOverheadBytes = 40
+
+// mcpToolSchemaTokens は MCP ツール1つの定義(名前・説明・入力スキーマ)の概算 token 数｡
+const mcpToolSchemaTokens = 400
+
+// mcpAssumedServerTools は設定済みの MCP サーバー1つが提供すると仮定するツール数｡
+// ツール定義はトランスクリプトに残らず、呼び出されないツールの数は分からないため、
+// 観測したツール数がこれに満たないサーバーはこの数で推定する｡
+const mcpAssumedServerTools = 10
+
+// ConfigImpact はセッション開始時に常に読み込まれる設定の推定コンテキスト消費と、期間中の使用状況｡
+// EstimatedTokens はファイルサイズからの概算で、実際の token 数とは異なる｡
+type ConfigImpact struct {
+	TotalEstimatedTokens int64            `json:"total_estimated_tokens"`
+	Skills               []SkillImpact    `json:"skills,omitempty"`
+	Plugins              []PluginImpact   `json:"plugins,omitempty"`
+	ClaudeMD             []ClaudeMDImpact `json:"claude_md,omitempty"`
+	MCPServers           []MCPImpact      `json:"mcp_servers,omitempty"`
+	// UnusedEstimatedTokens は期間中に一度も使われなかったスキル・プラグイン・MCP サーバーの推定消費の合計｡
+	UnusedEstimatedTokens int64 `json:"unused_estimated_tokens"`
+}
+
+// SkillImpact はグローバルスキル1つの一覧への掲載コストと呼び出し回数｡
+type SkillImpact struct {
+	Name            string `json:"name"`
+	EstimatedTokens int64  `json:"estimated_tokens"`
+	Invocations     int    `json:"invocations"`
+	Sessions        int    `json:"sessions"`
+}
+
+// PluginImpact は有効なプラグイン1つが提供するスキル・コマンド・エージェントの掲載コストと呼び出し回数｡
+type PluginImpact struct {
+	Name            string   `json:"name"`
+	InstallPath     string   `json:"install_path,omitempty"`
+	Skills          int      `json:"skills"`
+	Commands        int      `json:"commands"`
+	Agents          int      `json:"agents"`
+	MCPServers      []string `json:"mcp_servers,omitempty"`
+	EstimatedTokens int64    `json:"estimated_tokens"`
+	Invocations     int      `json:"invocations"`
+	Sessions        int      `json:"sessions"`
+}
+
+// ClaudeMDImpact は CLAUDE.md 1ファイルの推定消費｡Sessions はそのファイルを読み込んだセッション数｡
+type ClaudeMDImpact struct {
+	Path            string `json:"path"`
+	Scope           string `json:"scope"`
+	Project         string `json:"project,omitempty"`
+	Bytes           int64  `json:"bytes"`
+	EstimatedTokens int64  `json:"estimated_tokens"`
+	Sessions        int    `json:"sessions"`
+}
+
+// MCPImpact は MCP サーバー1つの推定定義コストと使用状況｡
+type MCPImpact struct {
+	Server string `json:"server"`
+	// Source は設定元: user・local (~/.claude.json)、project (.mcp.json)、plugin:<name>｡設定が見つからなければ空｡
+	Source          string `json:"source,omitempty"`
+	ToolsSeen       int    `json:"tools_seen"`
+	Calls           int    `json:"calls"`
+	EstimatedTokens int64  `json:"estimated_tokens"`
+	// ToolsAssumed は EstimatedTokens を観測したツール数ではなく mcpAssumedServerTools から推定したか｡
+	ToolsAssumed bool `json:"tools_assumed,omitempty"`
+}
+
+// ImpactSources は設定の読み込み元｡
+type ImpactSources struct {
+	Home           string
+	SkillsDir      string
+	EnabledPlugins []string
+}
+
+// NewImpactSources は home 配下の標準の配置を使う ImpactSources を返す｡
+func NewImpactSources(home string, enabledPlugins []string) ImpactSources {
+	return ImpactSources{
+		Home:           home,
+		SkillsDir:      filepath.Join(home, ".claude", "skills"),
+		EnabledPlugins: enabledPlugins,
+	}
+}
+
+// skillUse はスキル1つの期間中の呼び出し回数と呼び出したセッション数｡
+type skillUse struct {
+	invocations int
+	sessions    int
+}
+
+// collectSkillUse はセッションの Skill ツール呼び出しをスキル名ごとに合算する｡
+func collectSkillUse(results []SessionResult) map[string]skillUse {
+	uses := make(map[string]skillUse)
+	for _, r := range results {
+		for name, n := range r.SkillUsage {
+			u := uses[name]
+			u.invocations += n
+			u.sessions++
+			uses[name] = u
+		}
+	}
+	return uses
+}
+
+// BuildConfigImpact はスキル・プラグイン・CLAUDE.md・MCP サーバーの推定コンテキスト消費を集計する｡
+func BuildConfigImpact(src ImpactSources, results []SessionResult) *ConfigImpact {
+	uses := collectSkillUse(results)
+	impact := &ConfigImpact{}
+
+	for _, name := range ListGlobalSkillNames(src.SkillsDir) {
+		s := SkillImpact{Name: name, EstimatedTokens: skillListingTokens(filepath.Join(src.SkillsDir, name, "SKILL.md"), name)}
+		s.Invocations, s.Sessions = uses[name].invocations, uses[name].sessions
+		impact.Skills = append(impact.Skills, s)
+		impact.TotalEstimatedTokens += s.EstimatedTokens
+		if s.Invocations == 0 {
+			impact.UnusedEstimatedTokens += s.EstimatedTokens
+		}
+	}
+
+	installed := loadInstalledPlugins(filepath.Join(src.Home, ".claude", "plugins", "installed_plugins.json"))
+	pluginServers := make(map[string]string)
+	for _, name := range src.EnabledPlugins {
+		p := buildPluginImpact(name, installed[name], results)
+		for _, server := range p.MCPServers {
+			// プラグインの MCP ツールは mcp__plugin_<plugin>_<server>__<tool> の名前になる
+			pluginServers["plugin_"+pluginShortName(name)+"_"+server] = "plugin:" + name
+		}
+		impact.Plugins = append(impact.Plugins, p)
+		impact.TotalEstimatedTokens += p.EstimatedTokens
+		if p.Invocations == 0 {
+			impact.UnusedEstimatedTokens += p.EstimatedTokens
+		}
+	}
+
+	impact.ClaudeMD = buildClaudeMDImpact(src.Home, results)
+	for _, c := range impact.ClaudeMD {
+		impact.TotalEstimatedTokens += c.EstimatedTokens
+	}
+	impact.MCPServers = buildMCPImpact(src.Home, results, pluginServers)
+	for _, m := range impact.MCPServers {
+		impact.TotalEstimatedTokens += m.EstimatedTokens
+		if m.Calls == 0 {
+			impact.UnusedEstimatedTokens += m.EstimatedTokens
+		}
+	}
+
+	sort.SliceStable(impact.Skills, func(i, j int) bool { return impact.Skills[i].EstimatedTokens > impact.Skills[j].EstimatedTokens })
+	sort.SliceStable(impact.Plugins, func(i, j int) bool { return impact.Plugins[i].EstimatedTokens > impact.Plugins[j].EstimatedTokens })
+	return impact
+}
+
+// readFrontmatter は Markdown 先頭の "---" で囲まれた YAML frontmatter の1行の key: value を読む｡
+// 複数行の値や入れ子は扱わない｡
+func readFrontmatter(path string) map[string]string {
+	f, err := os.Open(path) // #nosec G304 -- CLIツール: パスはスキル・プラグインのディレクトリ由来
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return nil
+	}
+	fields := make(map[string]string)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return fields
+}
+
+// skillListingTokens は SKILL.md などの frontmatter の name と description がシステムプロンプトに占める token 数を概算する｡
+// 本文は呼び出されたときにだけ読み込まれるため含めない｡
+func skillListingTokens(path, fallbackName string) int64 {
+	fm := readFrontmatter(path)
+	name := fm["name"]
+	if name == "" {
+		name = fallbackName
+	}
+	return estimateTokens(int64(len(name) + len(fm["description"]) + skillListingOverheadBytes))
+}
+
+// pluginShortName は "name@marketplace" 形式のプラグイン名から name を返す｡
+func pluginShortName(name string) string {
+	short, _, _ := strings.Cut(name, "@")
+	return short
+}
+
+// installedPlugin は installed_plugins.json のプラグイン1件｡
+type installedPlugin struct {
+	InstallPath string `json:"installPath"`
+}
+
+// loadInstalledPlugins は installed_plugins.json からプラグイン名(name@marketplace)とインストール先の対応を読む｡
+// 1プラグインに複数のインストール(スコープ別)がある形式では先頭を使う｡
+func loadInstalledPlugins(path string) map[string]string {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはホームディレクトリ由来
+	if err != nil {
+		return nil
+	}
+	var file struct {
+		Plugins map[string]json.RawMessage `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil
+	}
+	paths := make(map[string]string, len(file.Plugins))
+	for name, raw := range file.Plugins {
+		var list []installedPlugin
+		if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+			paths[name] = list[0].InstallPath
+			continue
+		}
+		var single installedPlugin
+		if err := json.Unmarshal(raw, &single); err == nil {
+			paths[name] = single.InstallPath
+		}
+	}
+	return paths
+}
+
+// pluginInvocations はセッション中のプラグインのスキル・コマンド・エージェント・MCP ツールの呼び出し回数を返す｡
+// スキル・コマンド・エージェントは "<plugin>:<name>"、MCP ツールは "mcp__plugin_<plugin>_<server>__<tool>" の名前になる｡
+func pluginInvocations(r *SessionResult, short string) int {
+	prefix := short + ":"
+	count := func(usage map[string]int, prefix string) int {
+		n := 0
+		for name, c := range usage {
+			if strings.HasPrefix(name, prefix) {
+				n += c
+			}
+		}
+		return n
+	}
+	n := count(r.SkillUsage, prefix) + count(r.CommandUsage, prefix) + count(r.ToolUsage, "mcp__plugin_"+short+"_")
+	for _, task := range r.tasks {
+		if strings.HasPrefix(task.AgentType, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// buildPluginImpact はプラグインのスキル・コマンド・エージェントの掲載コストと呼び出し回数を集計する｡
+func buildPluginImpact(name, installPath string, results []SessionResult) PluginImpact {
+	p := PluginImpact{Name: name, InstallPath: installPath}
+	for i := range results {
+		if n := pluginInvocations(&results[i], pluginShortName(name)); n > 0 {
+			p.Invocations += n
+			p.Sessions++
+		}
+	}
+	if installPath == "" {
+		return p
+	}
+
+	skills, _ := filepath.Glob(filepath.Join(installPath, "skills", "*", "SKILL.md"))
+	for _, path := range skills {
+		p.EstimatedTokens += skillListingTokens(path, filepath.Base(filepath.Dir(path)))
+	}
+	p.Skills = len(skills)
+	for _, kind := range []string{"commands", "agents"} {
+		files, _ := filepath.Glob(filepath.Join(installPath, kind, "*.md"))
+		for _, path := range files {
+			p.EstimatedTokens += skillListingTokens(path, strings.TrimSuffix(filepath.Base(path), ".md"))
+		}
+		if kind == "commands" {
+			p.Commands = len(files)
+		} else {
+			p.Agents = len(files)
+		}
+	}
+	p.MCPServers = readMCPServerNames(filepath.Join(installPath, ".mcp.json"))
+	return p
+}
+
+// buildClaudeMDImpact はグローバルと各プロジェクトの CLAUDE.md のサイズを集計する｡
+// @ による import は展開しない｡
+func buildClaudeMDImpact(home string, results []SessionResult) []ClaudeMDImpact {
+	var impacts []ClaudeMDImpact
+	add := func(path, scope, project string, sessions int) {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return
+		}
+		impacts = append(impacts, ClaudeMDImpact{
+			Path:            path,
+			Scope:           scope,
+			Project:         project,
+			Bytes:           info.Size(),
+			EstimatedTokens: estimateTokens(info.Size()),
+			Sessions:        sessions,
+		})
+	}
+	add(filepath.Join(home, ".claude", "CLAUDE.md"), "global", "", len(results))
+
+	type projectRoot struct {
+		project  string
+		sessions int
+	}
+	roots := make(map[string]*projectRoot)
+	for _, r := range results {
+		if r.ProjectRoot == "" || r.IsSidechain {
+			continue
+		}
+		if roots[r.ProjectRoot] == nil {
+			roots[r.ProjectRoot] = &projectRoot{project: r.Project}
+		}
+		roots[r.ProjectRoot].sessions++
+	}
+	paths := make([]string, 0, len(roots))
+	for root := range roots {
+		paths = append(paths, root)
+	}
+	sort.Strings(paths)
+	for _, root := range paths {
+		pr := roots[root]
+		for _, name := range []string{"CLAUDE.md", filepath.Join(".claude", "CLAUDE.md"), "CLAUDE.local.md"} {
+			add(filepath.Join(root, name), "project", pr.project, pr.sessions)
+		}
+	}
+	sort.SliceStable(impacts, func(i, j int) bool { return impacts[i].EstimatedTokens > impacts[j].EstimatedTokens })
+	return impacts
+}
+
+// mcpServersFile は .mcp.json と ~/.claude.json の MCP サーバー定義｡
+// ~/.claude.json の projects はプロジェクトのディレクトリごとの local スコープの定義｡
+type mcpServersFile struct {
+	MCPServers map[string]json.RawMessage `json:"mcpServers"`
+	Projects   map[string]struct {
+		MCPServers map[string]json.RawMessage `json:"mcpServers"`
+	} `json:"projects"`
+}
+
+func readMCPServersFile(path string) *mcpServersFile {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはホーム・プロジェクト・プラグインのディレクトリ由来
+	if err != nil {
+		return nil
+	}
+	var file mcpServersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil
+	}
+	return &file
+}
+
+// readMCPServerNames は .mcp.json 形式のファイルの mcpServers のサーバー名を返す｡
+func readMCPServerNames(path string) []string {
+	file := readMCPServersFile(path)
+	if file == nil {
+		return nil
+	}
+	return sortedKeys(file.MCPServers)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mcpServerSources は MCP サーバー名から設定元への対応を返す｡
+// 同名のサーバーは user、local、project、プラグインの順に優先する｡
+func mcpServerSources(home string, results []SessionResult, pluginServers map[string]string) map[string]string {
+	sources := make(map[string]string)
+	setDefault := func(name, src string) {
+		if _, ok := sources[name]; !ok {
+			sources[name] = src
+		}
+	}
+	roots := make(map[string]bool)
+	for _, r := range results {
+		if r.ProjectRoot != "" {
+			roots[r.ProjectRoot] = true
+		}
+	}
+	if file := readMCPServersFile(filepath.Join(home, ".claude.json")); file != nil {
+		for name := range file.MCPServers {
+			setDefault(name, "user")
+		}
+		for dir, p := range file.Projects {
+			if roots[dir] {
+				for name := range p.MCPServers {
+					setDefault(name, "local")
+				}
+			}
+		}
+	}
+	for root := range roots {
+		for _, name := range readMCPServerNames(filepath.Join(root, ".mcp.json")) {
+			setDefault(name, "project")
+		}
+	}
+	for name, src := range pluginServers {
+		setDefault(name, src)
+	}
+	return sources
+}
+
+// buildMCPImpact は設定済みの MCP サーバーと、セッション中に呼び出された MCP ツールを突き合わせる｡
+// 設定済みのサーバーは呼び出しの有無に関わらずツール定義を読み込むため、
+// 観測したツール数が mcpAssumedServerTools に満たなければ仮定のツール数で推定する｡
+// 設定が見つからないサーバーは観測したツール数からの下限とする｡
+func buildMCPImpact(home string, results []SessionResult, pluginServers map[string]string) []MCPImpact {
+	sources := mcpServerSources(home, results, pluginServers)
+	servers := make(map[string]*MCPImpact)
+	tools := make(map[string]map[string]bool)
+	get := func(name string) *MCPImpact {
+		if servers[name] == nil {
+			servers[name] = &MCPImpact{Server: name, Source: sources[name]}
+			tools[name] = make(map[string]bool)
+		}
+		return servers[name]
+	}
+	for name := range sources {
+		get(name)
+	}
+	for _, r := range results {
+		for tool, n := range r.ToolUsage {
+			server := MCPServerName(tool)
+			if server == "" {
+				continue
+			}
+			get(server).Calls += n
+			tools[server][tool] = true
+		}
+	}
+
+	impacts := make([]MCPImpact, 0, len(servers))
+	for name, m := range servers {
+		m.ToolsSeen = len(tools[name])
+		n := m.ToolsSeen
+		if m.Source != "" && n < mcpAssumedServerTools {
+			n = mcpAssumedServerTools
+			m.ToolsAssumed = true
+		}
+		m.EstimatedTokens = int64(n) * mcpToolSchemaTokens
+		impacts = append(impacts, *m)
+	}
+	sort.Slice(impacts, func(i, j int) bool {
+		if impacts[i].Calls != impacts[j].Calls {
+			return impacts[i].Calls > impacts[j].Calls
+		}
+		return impacts[i].Server < impacts[j].Server
+	})
+	return impacts
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeImpactFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupImpactHome はスキル・プラグイン・CLAUDE.md・MCP 設定を持つホームディレクトリとプロジェクトを作る｡
+func setupImpactHome(t *testing.T) (home, repo string) {
+	t.Helper()
+	home = t.TempDir()
+	repo = t.TempDir()
+	claude := filepath.Join(home, ".claude")
+
+	writeImpactFile(t, filepath.Join(claude, "skills", "git-workflow", "SKILL.md"),
+		"---\nname: git-workflow\ndescription: \"gitの操作手順をまとめたスキルで、コミット・ブランチ・PRの作成に使う\"\n---\n\n本文は一覧に含めない\n")
+	writeImpactFile(t, filepath.Join(claude, "skills", "unused", "SKILL.md"),
+		"---\nname: unused\ndescription: 一度も使われない長い説明のスキル。一度も使われない長い説明のスキル。一度も使われない長い説明のスキル。\n---\n")
+
+	plugin := filepath.Join(claude, "plugins", "cache", "market", "superpowers", "1.0.0")
+	writeImpactFile(t, filepath.Join(claude, "plugins", "installed_plugins.json"),
+		`{"version":2,"plugins":{"superpowers@market":[{"scope":"user","installPath":"`+plugin+`"}],"gopls@market":{"installPath":"/nonexistent"}}}`)
+	writeImpactFile(t, filepath.Join(plugin, "skills", "brainstorming", "SKILL.md"), "---\nname: brainstorming\ndescription: アイデアを広げる\n---\n")
+	writeImpactFile(t, filepath.Join(plugin, "skills", "tdd", "SKILL.md"), "---\nname: tdd\ndescription: テスト駆動開発\n---\n")
+	writeImpactFile(t, filepath.Join(plugin, "commands", "plan.md"), "---\ndescription: 計画を立てる\n---\n")
+	writeImpactFile(t, filepath.Join(plugin, ".mcp.json"), `{"mcpServers":{"docs":{"command":"docs-server"}}}`)
+
+	writeImpactFile(t, filepath.Join(claude, "CLAUDE.md"), string(make([]byte, 4000)))
+	writeImpactFile(t, filepath.Join(repo, "CLAUDE.md"), string(make([]byte, 800)))
+	writeImpactFile(t, filepath.Join(repo, ".mcp.json"), `{"mcpServers":{"github":{"command":"gh-mcp"}}}`)
+	writeImpactFile(t, filepath.Join(home, ".claude.json"),
+		`{"mcpServers":{"serena":{"command":"serena"},"idle":{"command":"idle"}},"projects":{"`+repo+`":{"mcpServers":{"db":{"command":"db"}}}}}`)
+	return home, repo
+}
+
+func TestBuildConfigImpact(t *testing.T) {
+	home, repo := setupImpactHome(t)
+	results := []SessionResult{
+		{
+			SessionID:   "s1",
+			Project:     "repo",
+			ProjectRoot: repo,
+			SkillUsage:  map[string]int{"git-workflow": 2, "superpowers:brainstorming": 1},
+			ToolUsage: map[string]int{
+				"Read":                              3,
+				"mcp__serena__find_symbol":          4,
+				"mcp__serena__get_overview":         1,
+				"mcp__github__create_pr":            1,
+				"mcp__plugin_superpowers_docs__get": 2,
+			},
+		},
+		{
+			SessionID:    "s2",
+			Project:      "repo",
+			ProjectRoot:  repo,
+			SkillUsage:   map[string]int{"git-workflow": 1},
+			CommandUsage: map[string]int{"superpowers:plan": 1, "clear": 2},
+			tasks:        map[string]TaskInfo{"toolu_1": {AgentType: "superpowers:code-reviewer"}, "toolu_2": {AgentType: "Explore"}},
+		},
+	}
+	impact := BuildConfigImpact(NewImpactSources(home, []string{"superpowers@market", "gopls@market"}), results)
+
+	t.Run("スキルの掲載コストと呼び出し回数", func(t *testing.T) {
+		skills := make(map[string]SkillImpact)
+		for _, s := range impact.Skills {
+			skills[s.Name] = s
+		}
+		if s := skills["git-workflow"]; s.Invocations != 3 || s.Sessions != 2 || s.EstimatedTokens == 0 {
+			t.Errorf("git-workflow = %+v", s)
+		}
+		if s := skills["unused"]; s.Invocations != 0 || s.EstimatedTokens <= skills["git-workflow"].EstimatedTokens {
+			t.Errorf("unused = %+v", s)
+		}
+		if impact.Skills[0].Name != "unused" {
+			t.Errorf("推定消費の大きい順: %+v", impact.Skills)
+		}
+	})
+
+	t.Run("プラグインの内訳と呼び出し回数", func(t *testing.T) {
+		plugins := make(map[string]PluginImpact)
+		for _, p := range impact.Plugins {
+			plugins[p.Name] = p
+		}
+		// スキル1回・MCPツール2回・コマンド1回・エージェント1回
+		p := plugins["superpowers@market"]
+		if p.Skills != 2 || p.Commands != 1 || p.Agents != 0 || p.Invocations != 5 || p.Sessions != 2 || p.EstimatedTokens == 0 {
+			t.Errorf("superpowers = %+v", p)
+		}
+		if len(p.MCPServers) != 1 || p.MCPServers[0] != "docs" {
+			t.Errorf("MCPServers = %v", p.MCPServers)
+		}
+		// インストール先にファイルがなければ推定は0
+		if g := plugins["gopls@market"]; g.EstimatedTokens != 0 || g.InstallPath != "/nonexistent" {
+			t.Errorf("gopls = %+v", g)
+		}
+	})
+
+	t.Run("CLAUDE.mdのサイズ", func(t *testing.T) {
+		if len(impact.ClaudeMD) != 2 {
+			t.Fatalf("ClaudeMD = %+v", impact.ClaudeMD)
+		}
+		global, proj := impact.ClaudeMD[0], impact.ClaudeMD[1]
+		if global.Scope != "global" || global.EstimatedTokens != 1000 || global.Sessions != 2 {
+			t.Errorf("global = %+v", global)
+		}
+		if proj.Scope != "project" || proj.Project != "repo" || proj.EstimatedTokens != 200 || proj.Sessions != 2 {
+			t.Errorf("project = %+v", proj)
+		}
+	})
+
+	t.Run("MCPサーバーの設定元と使用状況", func(t *testing.T) {
+		servers := make(map[string]MCPImpact)
+		for _, m := range impact.MCPServers {
+			servers[m.Server] = m
+		}
+		// 設定済みのサーバーは呼び出されなくても仮定のツール数で推定する
+		assumed := int64(mcpAssumedServerTools) * mcpToolSchemaTokens
+		tests := []struct {
+			server string
+			source string
+			tools  int
+			calls  int
+		}{
+			{"serena", "user", 2, 5},
+			{"idle", "user", 0, 0},
+			{"db", "local", 0, 0},
+			{"github", "project", 1, 1},
+			{"plugin_superpowers_docs", "plugin:superpowers@market", 1, 2},
+		}
+		for _, tt := range tests {
+			m, ok := servers[tt.server]
+			if !ok {
+				t.Errorf("%s が見つからない", tt.server)
+				continue
+			}
+			if m.Source != tt.source || m.ToolsSeen != tt.tools || m.Calls != tt.calls || m.EstimatedTokens != assumed || !m.ToolsAssumed {
+				t.Errorf("%s = %+v", tt.server, m)
+			}
+		}
+		if impact.MCPServers[0].Server != "serena" {
+			t.Errorf("呼び出し回数の多い順: %+v", impact.MCPServers)
+		}
+	})
+
+	t.Run("設定が見つからないMCPサーバーは観測したツール数で推定", func(t *testing.T) {
+		many := make(map[string]int)
+		for i := range mcpAssumedServerTools + 2 {
+			many[fmt.Sprintf("mcp__serena__tool%d", i)] = 1
+		}
+		many["mcp__adhoc__run"] = 1
+		got := BuildConfigImpact(NewImpactSources(t.TempDir(), nil), []SessionResult{{SessionID: "s1", ToolUsage: many}})
+		for _, m := range got.MCPServers {
+			want := int64(m.ToolsSeen) * mcpToolSchemaTokens
+			if m.ToolsAssumed || m.EstimatedTokens != want {
+				t.Errorf("%s = %+v", m.Server, m)
+			}
+		}
+		if got.UnusedEstimatedTokens != 0 {
+			t.Errorf("UnusedEstimatedTokens = %d", got.UnusedEstimatedTokens)
+		}
+	})
+
+	t.Run("未使用の推定消費と警告", func(t *testing.T) {
+		var unused int64
+		for _, s := range impact.Skills {
+			if s.Invocations == 0 {
+				unused += s.EstimatedTokens
+			}
+		}
+		// 呼び出されていない MCP サーバー(idle, db)も含める
+		for _, m := range impact.MCPServers {
+			if m.Calls == 0 {
+				unused += m.EstimatedTokens
+			}
+		}
+		if impact.UnusedEstimatedTokens != unused || unused == 0 {
+			t.Errorf("UnusedEstimatedTokens = %d, want %d", impact.UnusedEstimatedTokens, unused)
+		}
+		th := DefaultThresholds()
+		th.UnusedConfigTokens = unused
+		if w := generateImpactWarnings(impact, th); len(w) != 0 {
+			t.Errorf("閾値以下なら警告なし: %+v", w)
+		}
+		th.UnusedConfigTokens = unused - 1
+		w := generateImpactWarnings(impact, th)
+		if len(w) != 1 || w[0].Type != "unused_config_overhead" || w[0].Value != unused {
+			t.Fatalf("warnings = %+v", w)
+		}
+		if !strings.Contains(w[0].Message, "mcp:idle") || !strings.Contains(w[0].Message, "mcp:db") {
+			t.Errorf("未使用のMCPサーバーを挙げる: %s", w[0].Message)
+		}
+	})
+}
+
+func TestReadFrontmatter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "SKILL.md")
+	writeImpactFile(t, path, "---\nname: demo\ndescription: 'quoted: value'\nmetadata:\n  nested: ignored\n---\nname: body\n")
+	fm := readFrontmatter(path)
+	if fm["name"] != "demo" || fm["description"] != "quoted: value" || fm["nested"] != "" {
+		t.Errorf("frontmatter = %v", fm)
+	}
+	writeImpactFile(t, path, "# no frontmatter\n")
+	if fm := readFrontmatter(path); fm != nil {
+		t.Errorf("frontmatterがなければ nil: %v", fm)
+	}
+}
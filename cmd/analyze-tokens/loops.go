@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/usadamasa/claude-config/internal/jsonlscan"
+)
+
+// toolCallDetailMax はツール呼び出しの履歴に残すファイルパスやコマンドの最大バイト数｡
+const toolCallDetailMax = 200
+
+// toolCall はツール呼び出し1回の記録｡Key は入力の JSON のハッシュで、同じ入力の呼び出しは同じ Key になる｡
+type toolCall struct {
+	ID   string `json:"id,omitempty"`
+	Turn int    `json:"turn"`
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Detail は Read のファイルパス、Bash のコマンド、Skill のスキル名｡
+	Detail string `json:"detail,omitempty"`
+	Failed bool   `json:"failed,omitempty"`
+}
+
+func newToolCall(block jsonlscan.ContentBlock, turn int) toolCall {
+	c := toolCall{ID: block.ID, Turn: turn, Name: block.Name}
+	var compact bytes.Buffer
+	input := []byte(block.Input)
+	if json.Compact(&compact, input) == nil {
+		input = compact.Bytes()
+	}
+	sum := sha256.Sum256(input)
+	c.Key = hex.EncodeToString(sum[:8])
+
+	var fields struct {
+		FilePath string `json:"file_path"`
+		Command  string `json:"command"`
+	}
+	if json.Unmarshal(block.Input, &fields) == nil {
+		switch block.Name {
+		case "Read":
+			c.Detail = fields.FilePath
+		case "Bash":
+			c.Detail = fields.Command
+		case "Skill":
+			c.Detail = skillName(block)
+		}
+	}
+	if len(c.Detail) > toolCallDetailMax {
+		c.Detail = strings.ToValidUTF8(c.Detail[:toolCallDetailMax], "")
+	}
+	return c
+}
+
+// markToolCallFailed は tool_result が失敗だった呼び出しに印を付ける｡
+// 結果は通常直前の呼び出しに対応するため、新しい方から探す｡
+func markToolCallFailed(result *SessionResult, toolUseID string) {
+	if toolUseID == "" {
+		return
+	}
+	for i := len(result.toolCalls) - 1; i >= 0; i-- {
+		if result.toolCalls[i].ID == toolUseID {
+			result.toolCalls[i].Failed = true
+			return
+		}
+	}
+}
+
+// toolCallGroup は同じとみなす呼び出しの集まり｡
+type toolCallGroup struct {
+	name      string
+	detail    string
+	count     int
+	firstTurn int
+	lastTurn  int
+}
+
+// groupToolCalls は keyFn が同じ値を返す呼び出しをまとめ、件数の多い順に返す｡keyFn が空文字を返す呼び出しは除く｡
+// window が正なら、同じ値の前の呼び出しから window コールより離れた呼び出しは別の区間としてまとめる｡
+func groupToolCalls(calls []toolCall, keyFn func(toolCall) string, window int64) []toolCallGroup {
+	var groups []toolCallGroup
+	// current は値ごとの最後の区間の groups の添字｡
+	current := make(map[string]int)
+	for _, c := range calls {
+		key := keyFn(c)
+		if key == "" {
+			continue
+		}
+		i, ok := current[key]
+		if ok && window > 0 && int64(c.Turn-groups[i].lastTurn) > window {
+			ok = false
+		}
+		if !ok {
+			groups = append(groups, toolCallGroup{name: c.Name, detail: c.Detail, firstTurn: c.Turn})
+			i = len(groups) - 1
+			current[key] = i
+		}
+		groups[i].count++
+		groups[i].lastTurn = c.Turn
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+	return groups
+}
+
+// loopWarning はセッション内の繰り返しを警告にする｡
+func loopWarning(r SessionResult, typ, message, recommendation string, g toolCallGroup, threshold int64) Warning {
+	return Warning{
+		Type:           typ,
+		Message:        message,
+		Recommendation: recommendation,
+		Project:        r.Project,
+		SessionID:      r.SessionID,
+		Tool:           g.name,
+		Detail:         g.detail,
+		TurnStart:      g.firstTurn,
+		TurnEnd:        g.lastTurn,
+		Value:          int64(g.count),
+		Threshold:      threshold,
+	}
+}
+
+// detectRepeatedToolCalls は同じ入力で同じツールを RepeatedCallWindow コール以内の間隔で何度も呼んだ区間を検出する｡
+// Read はファイル単位の detectRepeatedFileReads で扱う｡
+func detectRepeatedToolCalls(r SessionResult, th Thresholds) []Warning {
+	var warnings []Warning
+	groups := groupToolCalls(r.toolCalls, func(c toolCall) string {
+		if c.Name == "Read" {
+			return ""
+		}
+		return c.Name + "\x00" + c.Key
+	}, th.RepeatedCallWindow)
+	for _, g := range groups {
+		if int64(g.count) <= th.RepeatedToolCalls {
+			break
+		}
+		warnings = append(warnings, loopWarning(r, "repeated_tool_call",
+			fmt.Sprintf("同じ入力のツール呼び出しを%dコール以内の間隔で繰り返しています｡ループしている可能性があります", th.RepeatedCallWindow),
+			"該当ターンのトランスクリプトを確認し、結果が変わらない呼び出しを繰り返していないか、CLAUDE.mdやスキルの指示が矛盾していないか見直してください",
+			g, th.RepeatedToolCalls))
+	}
+	return warnings
+}
+
+// detectRepeatedFileReads は同じファイルを何度も Read したセッションを検出する｡
+func detectRepeatedFileReads(r SessionResult, th Thresholds) []Warning {
+	var warnings []Warning
+	groups := groupToolCalls(r.toolCalls, func(c toolCall) string {
+		if c.Name != "Read" {
+			return ""
+		}
+		return c.Detail
+	}, 0)
+	for _, g := range groups {
+		if int64(g.count) <= th.RepeatedFileReads {
+			break
+		}
+		warnings = append(warnings, loopWarning(r, "repeated_file_read",
+			"同じファイルを何度も読み込んでいます",
+			"compactionで内容が失われていないか確認し、大きなファイルはoffset/limitで必要な範囲だけを読むか、要点をメモに残すよう指示してください",
+			g, th.RepeatedFileReads))
+	}
+	return warnings
+}
+
+// detectFailingBashRetries は同じ Bash コマンドが RepeatedCallWindow コール以内の間隔で何度も失敗した区間を検出する｡
+func detectFailingBashRetries(r SessionResult, th Thresholds) []Warning {
+	var warnings []Warning
+	groups := groupToolCalls(r.toolCalls, func(c toolCall) string {
+		if c.Name != "Bash" || !c.Failed {
+			return ""
+		}
+		return c.Key
+	}, th.RepeatedCallWindow)
+	for _, g := range groups {
+		if int64(g.count) <= th.FailingBashRetries {
+			break
+		}
+		warnings = append(warnings, loopWarning(r, "failing_bash_retries",
+			"失敗したBashコマンドを同じ内容で再実行しています",
+			"失敗の原因(権限・依存関係・環境)を解消するか、permissionsやhookでの拒否理由をClaudeに伝わるようにしてください",
+			g, th.FailingBashRetries))
+	}
+	return warnings
+}
+
+// detectRapidCompactions は compaction の間隔が RapidCompactionWindow コール以下で続いた区間を検出する｡
+// 連続した compaction の数が RapidCompactions を超えた区間ごとに警告する｡
+func detectRapidCompactions(r SessionResult, th Thresholds) []Warning {
+	var warnings []Warning
+	flush := func(run []CompactionEvent) {
+		if int64(len(run)) <= th.RapidCompactions {
+			return
+		}
+		warnings = append(warnings, Warning{
+			Type:           "rapid_compaction",
+			Message:        fmt.Sprintf("compactionが%dコール以内の間隔で繰り返されています", th.RapidCompactionWindow),
+			Recommendation: "compaction直後にも大きなtool_resultや長いファイルを読み込んでいないか確認し、タスクを分割するか/clearで新しいセッションを始めてください",
+			Project:        r.Project,
+			SessionID:      r.SessionID,
+			TurnStart:      run[0].CallIndex + 1,
+			TurnEnd:        run[len(run)-1].CallIndex + 1,
+			Value:          int64(len(run)),
+			Threshold:      th.RapidCompactions,
+		})
+	}
+	var run []CompactionEvent
+	for _, c := range r.Compactions {
+		if len(run) > 0 && int64(c.CallIndex-run[len(run)-1].CallIndex) > th.RapidCompactionWindow {
+			flush(run)
+			run = nil
+		}
+		run = append(run, c)
+	}
+	flush(run)
+	return warnings
+}
+
+// generateLoopWarnings はセッションごとに繰り返しやリトライの多発を検出する｡
+func generateLoopWarnings(results []SessionResult, th Thresholds) []Warning {
+	var warnings []Warning
+	for _, r := range results {
+		warnings = append(warnings, detectRepeatedToolCalls(r, th)...)
+		warnings = append(warnings, detectRepeatedFileReads(r, th)...)
+		warnings = append(warnings, detectFailingBashRetries(r, th)...)
+		warnings = append(warnings, detectRapidCompactions(r, th)...)
+	}
+	return warnings
+}
+
+// formatTurnRange は警告のターン範囲を "turns 3-17" の形式で返す｡範囲がなければ空文字｡
+func formatTurnRange(w Warning) string {
+	switch {
+	case w.TurnStart == 0:
+		return ""
+	case w.TurnStart == w.TurnEnd:
+		return fmt.Sprintf("turn %d", w.TurnStart)
+	default:
+		return fmt.Sprintf("turns %d-%d", w.TurnStart, w.TurnEnd)
+	}
+}
+
+// truncateDetail は表示用に長いファイルパスやコマンドを切り詰める｡
+func truncateDetail(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
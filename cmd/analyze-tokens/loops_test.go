@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loopTranscript は同じ Grep の繰り返し、同じファイルの Read、失敗する Bash の再実行、連続した compaction を含むトランスクリプトを作る｡
+func loopTranscript() string {
+	var b strings.Builder
+	assistant := func(n int, tool, input string) {
+		fmt.Fprintf(&b, `{"type":"assistant","cwd":"/Users/test/project","sessionId":"loop-1","message":{"id":"msg_%d","model":"claude-opus-4-6","content":[{"type":"tool_use","id":"toolu_%d","name":"%s","input":%s}],"usage":{"input_tokens":100,"output_tokens":10}}}`+"\n", n, n, tool, input)
+	}
+	result := func(n int, isError bool) {
+		fmt.Fprintf(&b, `{"type":"user","sessionId":"loop-1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_%d","content":"out","is_error":%t}]}}`+"\n", n, isError)
+	}
+	n := 0
+	for range 5 {
+		n++
+		assistant(n, "Grep", `{"pattern":"TODO", "path":"."}`)
+		result(n, false)
+	}
+	for i := range 5 {
+		n++
+		// offset が違っても同じファイルとして数える
+		assistant(n, "Read", fmt.Sprintf(`{"file_path":"/repo/main.go","offset":%d}`, i*100))
+		result(n, false)
+	}
+	for range 3 {
+		n++
+		assistant(n, "Bash", `{"command":"make test"}`)
+		result(n, true)
+	}
+	// 成功した Bash は失敗の再実行に含めない
+	n++
+	assistant(n, "Bash", `{"command":"make test"}`)
+	result(n, false)
+	for range 2 {
+		b.WriteString(`{"type":"system","subtype":"compact_boundary","sessionId":"loop-1","compactMetadata":{"trigger":"auto","preTokens":150000}}` + "\n")
+		n++
+		assistant(n, "Glob", fmt.Sprintf(`{"pattern":"*.%d"}`, n))
+	}
+	return b.String()
+}
+
+func TestLoopWarnings(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJSONL(t, dir, "loop.jsonl", loopTranscript())
+	result, err := ScanSessionFile(filepath.Join(dir, "loop.jsonl"))
+	if err != nil {
+		t.Fatalf("ScanSessionFile失敗: %v", err)
+	}
+	warnings := generateLoopWarnings([]SessionResult{*result}, DefaultThresholds())
+	byType := make(map[string]Warning)
+	for _, w := range warnings {
+		if _, dup := byType[w.Type]; dup {
+			t.Errorf("%s が重複: %+v", w.Type, warnings)
+		}
+		byType[w.Type] = w
+	}
+
+	t.Run("同じ入力のツール呼び出し", func(t *testing.T) {
+		w, ok := byType["repeated_tool_call"]
+		if !ok || w.Tool != "Grep" || w.Value != 5 || w.TurnStart != 1 || w.TurnEnd != 5 || w.SessionID != "loop-1" {
+			t.Errorf("repeated_tool_call = %+v", w)
+		}
+	})
+
+	t.Run("同じファイルのRead", func(t *testing.T) {
+		w, ok := byType["repeated_file_read"]
+		if !ok || w.Detail != "/repo/main.go" || w.Value != 5 || w.TurnStart != 6 || w.TurnEnd != 10 {
+			t.Errorf("repeated_file_read = %+v", w)
+		}
+	})
+
+	t.Run("失敗したBashの再実行", func(t *testing.T) {
+		w, ok := byType["failing_bash_retries"]
+		if !ok || w.Detail != "make test" || w.Value != 3 || w.TurnStart != 11 || w.TurnEnd != 13 {
+			t.Errorf("failing_bash_retries = %+v", w)
+		}
+	})
+
+	t.Run("連続したcompaction", func(t *testing.T) {
+		w, ok := byType["rapid_compaction"]
+		if !ok || w.Value != 2 || w.TurnStart != 15 || w.TurnEnd != 16 {
+			t.Errorf("rapid_compaction = %+v", w)
+		}
+	})
+
+	t.Run("閾値を上げると警告なし", func(t *testing.T) {
+		th := DefaultThresholds()
+		th.RepeatedToolCalls, th.RepeatedFileReads, th.FailingBashRetries, th.RapidCompactions = 5, 5, 3, 2
+		if ws := generateLoopWarnings([]SessionResult{*result}, th); len(ws) != 0 {
+			t.Errorf("warnings = %+v", ws)
+		}
+	})
+
+	t.Run("間隔の空いたcompactionは別の区間", func(t *testing.T) {
+		r := SessionResult{SessionID: "s", Compactions: []CompactionEvent{{CallIndex: 10}, {CallIndex: 200}, {CallIndex: 220}, {CallIndex: 240}}}
+		ws := detectRapidCompactions(r, DefaultThresholds())
+		if len(ws) != 1 || ws[0].Value != 3 || ws[0].TurnStart != 201 || ws[0].TurnEnd != 241 {
+			t.Errorf("warnings = %+v", ws)
+		}
+	})
+
+	t.Run("セッション中に散らばった同じ呼び出しは繰り返しとして数えない", func(t *testing.T) {
+		th := DefaultThresholds()
+		var calls []toolCall
+		for i := range 6 {
+			turn := 1 + i*int(th.RepeatedCallWindow+1)
+			calls = append(calls,
+				toolCall{Turn: turn, Name: "Grep", Key: "grep-todo"},
+				toolCall{Turn: turn, Name: "Bash", Key: "make-test", Detail: "make test", Failed: true})
+		}
+		r := SessionResult{SessionID: "s", toolCalls: calls}
+		if ws := detectRepeatedToolCalls(r, th); len(ws) != 0 {
+			t.Errorf("repeated_tool_call = %+v", ws)
+		}
+		if ws := detectFailingBashRetries(r, th); len(ws) != 0 {
+			t.Errorf("failing_bash_retries = %+v", ws)
+		}
+
+		// 散らばった呼び出しの後に続けて繰り返した区間だけを警告する
+		last := calls[len(calls)-1].Turn
+		for i := range 3 {
+			r.toolCalls = append(r.toolCalls, toolCall{Turn: last + 1 + i, Name: "Bash", Key: "make-test", Detail: "make test", Failed: true})
+		}
+		ws := detectFailingBashRetries(r, th)
+		if len(ws) != 1 || ws[0].Value != 4 || ws[0].TurnStart != last || ws[0].TurnEnd != last+3 {
+			t.Errorf("failing_bash_retries = %+v", ws)
+		}
+	})
+
+	t.Run("レポートの警告に含まれターン範囲を表示する", func(t *testing.T) {
+		report := GenerateReport([]SessionResult{*result}, 10)
+		found := false
+		for _, w := range report.Warnings {
+			if w.Type == "failing_bash_retries" {
+				found = true
+				if target := warningTarget(w); !strings.Contains(target, "make test") || !strings.Contains(target, "turns 11-13") {
+					t.Errorf("target = %q", target)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("warnings = %+v", report.Warnings)
+		}
+	})
+}
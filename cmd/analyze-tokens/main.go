@@ -4,11 +4,16 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
 	"github.com/usadamasa/claude-config/internal/settings"
 )
 
@@ -19,8 +24,15 @@ type Warning struct {
 	Recommendation string `json:"recommendation"`
 	Project        string `json:"project,omitempty"`
 	SessionID      string `json:"session_id,omitempty"`
-	Value          int64  `json:"value"`
-	Threshold      int64  `json:"threshold"`
+	Tool           string `json:"tool,omitempty"`
+	Model          string `json:"model,omitempty"`
+	// Detail は対象のファイルパスやコマンドなど、警告の補足｡
+	Detail string `json:"detail,omitempty"`
+	// TurnStart と TurnEnd は警告の対象になった API コールの範囲(1始まり)｡
+	TurnStart int   `json:"turn_start,omitempty"`
+	TurnEnd   int   `json:"turn_end,omitempty"`
+	Value     int64 `json:"value"`
+	Threshold int64 `json:"threshold"`
 }
 
 // ConfigHealth はグローバル設定の健全性情報を表す｡
@@ -35,87 +47,138 @@ type ConfigHealth struct {
 type Report struct {
 	Summary        ReportSummary    `json:"summary"`
 	ConfigHealth   *ConfigHealth    `json:"config_health,omitempty"`
+	ConfigImpact   *ConfigImpact    `json:"config_impact,omitempty"`
 	Warnings       []Warning        `json:"warnings"`
 	TopSessions    []SessionResult  `json:"top_sessions"`
 	ProjectSummary []ProjectSummary `json:"project_summary"`
 	ModelSummary   []ModelSummary   `json:"model_summary"`
+	DailyUsage     []TimeBucket     `json:"daily_usage,omitempty"`
+	// SubagentSummary は subagent に使われた token の多いセッション順｡
+	SubagentSummary []SessionSubagents `json:"subagent_summary,omitempty"`
+	// ToolCost と MCPServerCost は tool_result によるコンテキスト消費の大きい順｡
+	ToolCost      []ToolCostSummary      `json:"tool_cost,omitempty"`
+	MCPServerCost []MCPServerCostSummary `json:"mcp_server_cost,omitempty"`
 }
 
 // ReportSummary は全体統計｡
 type ReportSummary struct {
-	TotalSessions      int   `json:"total_sessions"`
-	TotalInputTokens   int64 `json:"total_input_tokens"`
-	TotalOutputTokens  int64 `json:"total_output_tokens"`
-	TotalAPICalls      int   `json:"total_api_calls"`
-	AverageInputPerCall int64 `json:"average_input_per_call"`
-	Days               int   `json:"days"`
+	TotalSessions            int     `json:"total_sessions"`
+	TotalInputTokens         int64   `json:"total_input_tokens"`
+	TotalOutputTokens        int64   `json:"total_output_tokens"`
+	TotalAPICalls            int     `json:"total_api_calls"`
+	AverageInputPerCall      int64   `json:"average_input_per_call"`
+	TotalCacheCreationTokens int64   `json:"total_cache_creation_tokens"`
+	TotalCacheReadTokens     int64   `json:"total_cache_read_tokens"`
+	AverageContextPerCall    int64   `json:"average_context_per_call"`
+	CacheHitRatio            float64 `json:"cache_hit_ratio"`
+	// EstimatedToolResultTokens は tool_result のサイズから概算した token 数 (bytes/4)｡
+	EstimatedToolResultTokens int64    `json:"estimated_tool_result_tokens"`
+	TotalCostUSD              float64  `json:"total_cost_usd,omitempty"`
+	PricingFile               string   `json:"pricing_file,omitempty"`
+	UnpricedModels            []string `json:"unpriced_models,omitempty"`
+	Days                      int      `json:"days"`
+	// Filter はレポートの絞り込み条件｡指定がなければ省略する｡
+	Filter *ReportFilter `json:"filter,omitempty"`
 }
 
 // ProjectSummary はプロジェクト別の集計｡
 type ProjectSummary struct {
-	Project            string `json:"project"`
-	TotalInputTokens   int64  `json:"total_input_tokens"`
-	TotalOutputTokens  int64  `json:"total_output_tokens"`
-	SessionCount       int    `json:"session_count"`
-	TotalAPICalls      int    `json:"total_api_calls"`
-	AverageInputPerCall int64 `json:"average_input_per_call"`
+	Project                  string  `json:"project"`
+	TotalInputTokens         int64   `json:"total_input_tokens"`
+	TotalOutputTokens        int64   `json:"total_output_tokens"`
+	SessionCount             int     `json:"session_count"`
+	TotalAPICalls            int     `json:"total_api_calls"`
+	AverageInputPerCall      int64   `json:"average_input_per_call"`
+	TotalCacheCreationTokens int64   `json:"total_cache_creation_tokens"`
+	TotalCacheReadTokens     int64   `json:"total_cache_read_tokens"`
+	AverageContextPerCall    int64   `json:"average_context_per_call"`
+	CacheHitRatio            float64 `json:"cache_hit_ratio"`
+	CostUSD                  float64 `json:"cost_usd,omitempty"`
 }
 
 // ModelSummary はモデル別の集計｡
 type ModelSummary struct {
-	Model        string `json:"model"`
-	InputTokens  int64  `json:"input_tokens"`
-	OutputTokens int64  `json:"output_tokens"`
-	CallCount    int    `json:"call_count"`
+	Model                 string  `json:"model"`
+	InputTokens           int64   `json:"input_tokens"`
+	OutputTokens          int64   `json:"output_tokens"`
+	CacheCreationTokens   int64   `json:"cache_creation_tokens"`
+	CacheReadTokens       int64   `json:"cache_read_tokens"`
+	CallCount             int     `json:"call_count"`
+	AverageContextPerCall int64   `json:"average_context_per_call"`
+	CacheHitRatio         float64 `json:"cache_hit_ratio"`
+	CostUSD               float64 `json:"cost_usd,omitempty"`
 }
 
-// GenerateReport はセッション結果からレポートを生成する｡
+// GenerateReport はセッション結果から組み込みの閾値でレポートを生成する｡
 func GenerateReport(results []SessionResult, topN int) Report {
+	return GenerateReportWithConfig(results, topN, DefaultConfig())
+}
+
+// GenerateReportWithConfig は設定の閾値とカスタムルールでレポートを生成する｡
+func GenerateReportWithConfig(results []SessionResult, topN int, cfg *Config) Report {
 	report := Report{}
 
 	if len(results) == 0 {
 		return report
 	}
 
-	// 全体統計
-	var totalInput, totalOutput int64
+	report.Summary = buildReportSummary(results)
+	report.TopSessions = topSessions(results, topN)
+	report.ProjectSummary = buildProjectSummary(results)
+	report.ModelSummary = buildModelSummary(results)
+	report.DailyUsage = BuildTimeSeries(results, GroupByDay, time.Time{})
+	report.SubagentSummary = BuildSubagentSummary(results, topN)
+	report.ToolCost, report.MCPServerCost = BuildToolCost(results, 0)
+
+	// 警告生成｡ツール別の警告は表示件数で切り詰める前の全ツールで判定する
+	report.Warnings = generateWarnings(report, results, cfg.Thresholds)
+	for _, rule := range cfg.rules {
+		report.Warnings = append(report.Warnings, rule.Evaluate(report, results)...)
+	}
+	if topN > 0 && len(report.ToolCost) > topN {
+		report.ToolCost = report.ToolCost[:topN]
+	}
+
+	return report
+}
+
+// buildReportSummary は全体統計を集計する｡
+func buildReportSummary(results []SessionResult) ReportSummary {
+	var totalInput, totalOutput, totalCacheCreation, totalCacheRead int64
 	var totalCalls int
+	var totalCost float64
+	var toolResultBytes int64
 	for _, r := range results {
 		totalInput += r.TotalInputTokens
 		totalOutput += r.TotalOutputTokens
+		totalCacheCreation += r.TotalCacheCreationTokens
+		totalCacheRead += r.TotalCacheReadTokens
 		totalCalls += r.APICallCount
+		totalCost += r.CostUSD
+		for _, st := range r.ToolResults {
+			toolResultBytes += st.ResultBytes
+		}
 	}
 
-	var avgPerCall int64
-	if totalCalls > 0 {
-		avgPerCall = totalInput / int64(totalCalls)
-	}
-
-	report.Summary = ReportSummary{
-		TotalSessions:      len(results),
-		TotalInputTokens:   totalInput,
-		TotalOutputTokens:  totalOutput,
-		TotalAPICalls:      totalCalls,
-		AverageInputPerCall: avgPerCall,
-	}
-
-	// Top N セッション(input tokens降順)
-	sorted := make([]SessionResult, len(results))
-	copy(sorted, results)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].TotalInputTokens > sorted[j].TotalInputTokens
-	})
-
-	if topN > 0 && topN < len(sorted) {
-		report.TopSessions = sorted[:topN]
-	} else if topN > 0 {
-		report.TopSessions = sorted
-	} else {
-		report.TopSessions = []SessionResult{}
+	return ReportSummary{
+		TotalSessions:             countSessions(results),
+		TotalInputTokens:          totalInput,
+		TotalOutputTokens:         totalOutput,
+		TotalAPICalls:             totalCalls,
+		AverageInputPerCall:       averagePerCall(totalInput, totalCalls),
+		TotalCacheCreationTokens:  totalCacheCreation,
+		TotalCacheReadTokens:      totalCacheRead,
+		AverageContextPerCall:     averagePerCall(contextTokens(totalInput, totalCacheRead, totalCacheCreation), totalCalls),
+		CacheHitRatio:             cacheHitRatio(totalInput, totalCacheRead, totalCacheCreation),
+		TotalCostUSD:              totalCost,
+		EstimatedToolResultTokens: estimateTokens(toolResultBytes),
 	}
+}
 
-	// プロジェクト別集計
+// buildProjectSummary はプロジェクト別に集計し、input tokens の多い順に返す｡
+func buildProjectSummary(results []SessionResult) []ProjectSummary {
 	projMap := make(map[string]*ProjectSummary)
+	projSessions := make(map[string]map[string]bool)
 	for _, r := range results {
 		proj := r.Project
 		if proj == "" {
@@ -125,24 +188,33 @@ func GenerateReport(results []SessionResult, topN int) Report {
 		if !ok {
 			ps = &ProjectSummary{Project: proj}
 			projMap[proj] = ps
+			projSessions[proj] = make(map[string]bool)
 		}
 		ps.TotalInputTokens += r.TotalInputTokens
 		ps.TotalOutputTokens += r.TotalOutputTokens
-		ps.SessionCount++
+		projSessions[proj][r.rootSessionID()] = true
 		ps.TotalAPICalls += r.APICallCount
+		ps.TotalCacheCreationTokens += r.TotalCacheCreationTokens
+		ps.TotalCacheReadTokens += r.TotalCacheReadTokens
+		ps.CostUSD += r.CostUSD
 	}
-	for _, ps := range projMap {
-		if ps.TotalAPICalls > 0 {
-			ps.AverageInputPerCall = ps.TotalInputTokens / int64(ps.TotalAPICalls)
-		}
-		report.ProjectSummary = append(report.ProjectSummary, *ps)
+	var summary []ProjectSummary
+	for proj, ps := range projMap {
+		ps.SessionCount = len(projSessions[proj])
+		ps.AverageInputPerCall = averagePerCall(ps.TotalInputTokens, ps.TotalAPICalls)
+		ps.AverageContextPerCall = averagePerCall(contextTokens(ps.TotalInputTokens, ps.TotalCacheReadTokens, ps.TotalCacheCreationTokens), ps.TotalAPICalls)
+		ps.CacheHitRatio = cacheHitRatio(ps.TotalInputTokens, ps.TotalCacheReadTokens, ps.TotalCacheCreationTokens)
+		summary = append(summary, *ps)
 	}
 	// input tokens降順でソート
-	sort.Slice(report.ProjectSummary, func(i, j int) bool {
-		return report.ProjectSummary[i].TotalInputTokens > report.ProjectSummary[j].TotalInputTokens
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].TotalInputTokens > summary[j].TotalInputTokens
 	})
+	return summary
+}
 
-	// モデル別集計
+// buildModelSummary はモデル別に集計し、input tokens の多い順に返す｡
+func buildModelSummary(results []SessionResult) []ModelSummary {
 	modelMap := make(map[string]*ModelSummary)
 	for _, r := range results {
 		for model, mt := range r.ModelUsage {
@@ -153,61 +225,186 @@ func GenerateReport(results []SessionResult, topN int) Report {
 			}
 			ms.InputTokens += mt.InputTokens
 			ms.OutputTokens += mt.OutputTokens
+			ms.CacheCreationTokens += mt.CacheCreationTokens
+			ms.CacheReadTokens += mt.CacheReadTokens
 			ms.CallCount += mt.CallCount
+			ms.CostUSD += mt.CostUSD
 		}
 	}
+	var summary []ModelSummary
 	for _, ms := range modelMap {
-		report.ModelSummary = append(report.ModelSummary, *ms)
+		ms.AverageContextPerCall = averagePerCall(contextTokens(ms.InputTokens, ms.CacheReadTokens, ms.CacheCreationTokens), ms.CallCount)
+		ms.CacheHitRatio = cacheHitRatio(ms.InputTokens, ms.CacheReadTokens, ms.CacheCreationTokens)
+		summary = append(summary, *ms)
 	}
-	sort.Slice(report.ModelSummary, func(i, j int) bool {
-		return report.ModelSummary[i].InputTokens > report.ModelSummary[j].InputTokens
+	sort.Slice(summary, func(i, j int) bool {
+		return summary[i].InputTokens > summary[j].InputTokens
 	})
+	return summary
+}
 
-	// 警告生成
-	report.Warnings = generateWarnings(report, results)
+// countSessions は sidechain を起動元のセッションに含めてセッション数を数える｡
+func countSessions(results []SessionResult) int {
+	sessions := make(map[string]bool, len(results))
+	for i := range results {
+		sessions[results[i].rootSessionID()] = true
+	}
+	return len(sessions)
+}
 
-	return report
+// topSessions は input tokens の多い順に最大 topN 件のセッションを返す｡
+// sidechain は起動元のセッションに token 数・コール数・コストを合算し、SubagentSessions に数を記録する｡
+// 起動元にも progress として記録されている subagent は、BuildSubagentSummary と同じく sidechain 側の値を使う｡
+// 起動元が集計対象にない sidechain は IsSidechain のまま単独で並べる｡
+func topSessions(results []SessionResult, topN int) []SessionResult {
+	if topN <= 0 {
+		return []SessionResult{}
+	}
+	var sorted []SessionResult
+	parents := make(map[string]int)
+	for _, r := range results {
+		if !r.IsSidechain {
+			parents[r.SessionID] = len(sorted)
+			sorted = append(sorted, r)
+		}
+	}
+	for _, r := range results {
+		if !r.IsSidechain {
+			continue
+		}
+		i, ok := parents[r.ParentSessionID]
+		if !ok {
+			sorted = append(sorted, r)
+			continue
+		}
+		p := &sorted[i]
+		if toolUseID, ok := p.agentTasks[r.AgentID]; ok {
+			p.subtractSubagentCalls(toolUseID)
+		}
+		p.TotalInputTokens += r.TotalInputTokens
+		p.TotalOutputTokens += r.TotalOutputTokens
+		p.TotalCacheCreationTokens += r.TotalCacheCreationTokens
+		p.TotalCacheReadTokens += r.TotalCacheReadTokens
+		p.APICallCount += r.APICallCount
+		p.CostUSD += r.CostUSD
+		p.SubagentSessions++
+	}
+	for i := range sorted {
+		sorted[i].updateCacheMetrics()
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TotalInputTokens > sorted[j].TotalInputTokens
+	})
+	if topN < len(sorted) {
+		sorted = sorted[:topN]
+	}
+	return sorted
 }
 
-const (
-	thresholdProjectAvgInput  int64 = 80000
-	thresholdGlobalAvgInput   int64 = 60000
-	thresholdCallMessageRatio int64 = 50
-)
+// subtractSubagentCalls は toolUseID の Task から起動された progress コールを token 数・コール数・コストから除く｡
+func (r *SessionResult) subtractSubagentCalls(toolUseID string) {
+	for _, c := range r.Calls {
+		if !c.Subagent || c.ParentToolUseID != toolUseID {
+			continue
+		}
+		r.TotalInputTokens -= c.Usage.InputTokens
+		r.TotalOutputTokens -= c.Usage.OutputTokens
+		r.TotalCacheCreationTokens -= c.Usage.CacheCreationInputTokens
+		r.TotalCacheReadTokens -= c.Usage.CacheReadInputTokens
+		r.APICallCount--
+		r.CostUSD -= c.CostUSD
+	}
+}
 
-func generateWarnings(report Report, results []SessionResult) []Warning {
+func generateWarnings(report Report, results []SessionResult, th Thresholds) []Warning {
 	var warnings []Warning
 
-	// 全体avg > 60K
-	if report.Summary.AverageInputPerCall > thresholdGlobalAvgInput {
+	// 全体avg > 閾値
+	if report.Summary.AverageInputPerCall > th.GlobalAvgInput {
 		warnings = append(warnings, Warning{
 			Type:           "global_high_avg",
 			Message:        "全体のaverage_input_per_callが閾値を超えています",
 			Recommendation: "settings.jsonのenabledPluginsで不要なプラグインをfalseに設定し、~/.claude/skills/から未使用スキルを移動してください",
 			Value:          report.Summary.AverageInputPerCall,
-			Threshold:      thresholdGlobalAvgInput,
+			Threshold:      th.GlobalAvgInput,
+		})
+	}
+	if report.Summary.AverageContextPerCall > th.GlobalAvgContext {
+		warnings = append(warnings, Warning{
+			Type:           "global_high_avg_context",
+			Message:        "全体のaverage_context_per_callが閾値を超えています｡会話履歴がコンテキストの大半を占めています",
+			Recommendation: "タスクの区切りで/clearや/compactを使い、長いセッションを分割してください",
+			Value:          report.Summary.AverageContextPerCall,
+			Threshold:      th.GlobalAvgContext,
 		})
 	}
 
-	// プロジェクト別 avg > 80K
+	// プロジェクト別 avg > 閾値
 	for _, ps := range report.ProjectSummary {
-		if ps.AverageInputPerCall > thresholdProjectAvgInput {
+		if ps.AverageInputPerCall > th.ProjectAvgInput {
 			warnings = append(warnings, Warning{
 				Type:           "high_avg_input",
 				Message:        "プロジェクトのaverage_input_per_callが閾値を超えています",
 				Recommendation: "プロジェクトのCLAUDE.mdが肥大化していないか確認し、プロジェクト固有のMCP設定やスキルを見直してください",
 				Project:        ps.Project,
 				Value:          ps.AverageInputPerCall,
-				Threshold:      thresholdProjectAvgInput,
+				Threshold:      th.ProjectAvgInput,
+			})
+		}
+		if ps.AverageContextPerCall > th.ProjectAvgContext {
+			warnings = append(warnings, Warning{
+				Type:           "high_avg_context",
+				Message:        "プロジェクトのaverage_context_per_callが閾値を超えています｡会話履歴がコンテキストの大半を占めています",
+				Recommendation: "大きなファイルや長いコマンド出力を繰り返し読み込んでいないか確認し、タスクの区切りで/clearや/compactを使ってください",
+				Project:        ps.Project,
+				Value:          ps.AverageContextPerCall,
+				Threshold:      th.ProjectAvgContext,
 			})
 		}
 	}
 
-	// セッション別 api_calls/user_messages > 50
+	// プロジェクト別 cache hit ratio < 閾値
+	for _, ps := range report.ProjectSummary {
+		if int64(ps.TotalAPICalls) < th.CacheMinCalls {
+			continue
+		}
+		percent := int64(math.Round(ps.CacheHitRatio * 100))
+		if percent < th.CacheHitPercent {
+			warnings = append(warnings, Warning{
+				Type:           "low_cache_hit_ratio",
+				Message:        "プロジェクトのcache_hit_ratio(%)が閾値を下回っています｡prompt cacheが効いていません",
+				Recommendation: "セッション途中でのモデル切替、CLAUDE.mdやMCPツール定義の頻繁な変更、5分以上のアイドルによるcache失効がないか確認してください",
+				Project:        ps.Project,
+				Value:          percent,
+				Threshold:      th.CacheHitPercent,
+			})
+		}
+	}
+
+	// ツール別 tool_result 平均 > 閾値
+	for _, tc := range report.ToolCost {
+		if int64(tc.ResultCount) < th.ToolResultMinCount || tc.AverageTokens <= th.ToolResultAvgTokens {
+			continue
+		}
+		recommendation := "Readはoffset/limitで範囲を絞り、GrepやBashは出力を絞り込むオプションを使ってください"
+		if tc.MCPServer != "" {
+			recommendation = "MCPサーバ " + tc.MCPServer + " の応答サイズを制限するか、不要なツールをpermissions.denyで無効にしてください"
+		}
+		warnings = append(warnings, Warning{
+			Type:           "large_tool_results",
+			Message:        "ツールのtool_result平均サイズ(推定tokens)が閾値を超えています",
+			Recommendation: recommendation,
+			Tool:           tc.Tool,
+			Value:          tc.AverageTokens,
+			Threshold:      th.ToolResultAvgTokens,
+		})
+	}
+
+	// セッション別 api_calls/user_messages > 閾値
 	for _, r := range results {
 		if r.UserMessageCount > 0 {
 			ratio := int64(r.APICallCount) / int64(r.UserMessageCount)
-			if ratio > thresholdCallMessageRatio {
+			if ratio > th.CallMessageRatio {
 				warnings = append(warnings, Warning{
 					Type:           "high_call_ratio",
 					Message:        "api_calls/user_messages比率が高すぎます｡subagent多段呼び出しの可能性があります",
@@ -215,12 +412,14 @@ func generateWarnings(report Report, results []SessionResult) []Warning {
 					SessionID:      r.SessionID,
 					Project:        r.Project,
 					Value:          ratio,
-					Threshold:      thresholdCallMessageRatio,
+					Threshold:      th.CallMessageRatio,
 				})
 			}
 		}
 	}
 
+	warnings = append(warnings, generateLoopWarnings(results, th)...)
+
 	if warnings == nil {
 		warnings = []Warning{}
 	}
@@ -228,37 +427,79 @@ func generateWarnings(report Report, results []SessionResult) []Warning {
 	return warnings
 }
 
-const (
-	thresholdEnabledPlugins int64 = 10
-	thresholdGlobalSkills   int64 = 15
-)
+// collectConfigHealth はグローバル設定の健全性情報を集める｡settingsPath が空なら ~/.claude/settings.json を読む｡
+func collectConfigHealth(home, settingsPath string) ConfigHealth {
+	if settingsPath == "" {
+		settingsPath = filepath.Join(home, ".claude", "settings.json")
+	}
+	skillsDir := filepath.Join(home, ".claude", "skills")
+	health := ConfigHealth{
+		GlobalSkills:     CountGlobalSkills(skillsDir),
+		GlobalSkillNames: ListGlobalSkillNames(skillsDir),
+	}
+	if s, err := settings.Load(settingsPath); err == nil {
+		health.EnabledPlugins = s.CountEnabledPlugins()
+		health.EnabledPluginNames = s.ListEnabledPluginNames()
+	}
+	return health
+}
 
-func generateConfigWarnings(health ConfigHealth) []Warning {
+func generateConfigWarnings(health ConfigHealth, th Thresholds) []Warning {
 	var warnings []Warning
 
-	if int64(health.EnabledPlugins) > thresholdEnabledPlugins {
+	if int64(health.EnabledPlugins) > th.EnabledPlugins {
 		warnings = append(warnings, Warning{
 			Type:           "too_many_plugins",
 			Message:        "有効プラグイン数が多すぎます",
 			Recommendation: "settings.jsonのenabledPluginsを確認し、使用頻度の低いプラグインをfalseに設定してください｡LSP系(gopls,jdtls)は必要な言語のみ有効にしてください",
 			Value:          int64(health.EnabledPlugins),
-			Threshold:      thresholdEnabledPlugins,
+			Threshold:      th.EnabledPlugins,
 		})
 	}
 
-	if int64(health.GlobalSkills) > thresholdGlobalSkills {
+	if int64(health.GlobalSkills) > th.GlobalSkills {
 		warnings = append(warnings, Warning{
 			Type:           "too_many_skills",
 			Message:        "グローバルスキル数が多すぎます",
 			Recommendation: "~/.claude/skills/を確認し、プロジェクト固有のスキルは各リポジトリの.claude/skills/に移動してください",
 			Value:          int64(health.GlobalSkills),
-			Threshold:      thresholdGlobalSkills,
+			Threshold:      th.GlobalSkills,
 		})
 	}
 
 	return warnings
 }
 
+// generateImpactWarnings は期間中に使われないスキル・プラグイン・MCP サーバーの推定消費が閾値を超えれば警告する｡
+func generateImpactWarnings(impact *ConfigImpact, th Thresholds) []Warning {
+	if impact == nil || impact.UnusedEstimatedTokens <= th.UnusedConfigTokens {
+		return nil
+	}
+	var unused []string
+	for _, s := range impact.Skills {
+		if s.Invocations == 0 {
+			unused = append(unused, s.Name)
+		}
+	}
+	for _, p := range impact.Plugins {
+		if p.Invocations == 0 {
+			unused = append(unused, p.Name)
+		}
+	}
+	for _, m := range impact.MCPServers {
+		if m.Calls == 0 {
+			unused = append(unused, "mcp:"+m.Server)
+		}
+	}
+	return []Warning{{
+		Type:           "unused_config_overhead",
+		Message:        fmt.Sprintf("期間中に使われないスキル・プラグイン・MCPサーバーが毎セッションのコンテキストを消費しています (%s)", strings.Join(unused, ", ")),
+		Recommendation: "config_impactの使用回数を確認し、不要なスキルは~/.claude/skills/から外し、プラグインはsettings.jsonのenabledPluginsでfalseに、MCPサーバーは設定から削除してください",
+		Value:          impact.UnusedEstimatedTokens,
+		Threshold:      th.UnusedConfigTokens,
+	}}
+}
+
 // ListGlobalSkillNames は指定ディレクトリ内のスキル名一覧をソート済みで返す｡
 // symlink先がディレクトリの場合もカウントする｡
 func ListGlobalSkillNames(skillsDir string) []string {
@@ -288,13 +529,108 @@ func CountGlobalSkills(skillsDir string) int {
 	return len(ListGlobalSkillNames(skillsDir))
 }
 
+// runSubcommand は name のサブコマンドを実行して終了コードを返す｡サブコマンドでなければ false を返す｡
+func runSubcommand(name string, args []string) (int, bool) {
+	switch name {
+	case "session":
+		return runSession(args, os.Stdout, os.Stderr), true
+	case "budget":
+		return runBudget(args, os.Stdin, os.Stdout, os.Stderr), true
+	case "diff":
+		return runDiff(args, os.Stdout, os.Stderr), true
+	case "serve":
+		return runServe(args, os.Stderr), true
+	case "export":
+		return runExport(args, os.Stdout, os.Stderr), true
+	}
+	return 0, false
+}
+
+// reportFlags はレポート(サブコマンドなし)のコマンドラインフラグ｡
+type reportFlags struct {
+	days, topN                                                                     *int
+	projectsDir, settingsPath, pricingPath, groupBy, format, configPath, cachePath *string
+	warningsOnly, noCache                                                          *bool
+	thresholds                                                                     thresholdFlags
+	filter                                                                         *filterFlags
+}
+
+// registerReportFlags はレポートのフラグを fs に登録する｡
+func registerReportFlags(fs *flag.FlagSet) *reportFlags {
+	f := &reportFlags{
+		days:         fs.Int("days", 30, "分析対象期間(日数)"),
+		topN:         fs.Int("top", 10, "表示するTop Nセッション数"),
+		projectsDir:  fs.String("dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)"),
+		settingsPath: fs.String("settings", "", "settings.jsonのパス (デフォルト: ~/.claude/settings.json)"),
+		warningsOnly: fs.Bool("warnings-only", false, "警告とconfig_healthのみ出力"),
+		pricingPath:  fs.String("pricing", "", "料金表JSONのパス (デフォルト: ~/.claude/"+pricingFileName+" があれば使用)"),
+		groupBy:      fs.String("group-by", "", "時系列で出力する集計単位: hour, day, week"),
+		format:       fs.String("format", FormatJSON, "出力形式: json, summary, table, csv, markdown (--group-by 指定時は json または csv)"),
+		configPath:   fs.String("config", "", "設定ファイルのパス (デフォルト: ~/.claude/"+configFileName+" があれば使用)"),
+		cachePath:    fs.String("cache", "", "スキャン結果のキャッシュファイル (デフォルト: ユーザーキャッシュディレクトリ配下)"),
+		noCache:      fs.Bool("no-cache", false, "キャッシュを使わずに全ファイルを解析する"),
+	}
+	fs.Var(&f.thresholds, "threshold", "閾値の上書き name=value (繰り返し指定可、例: project_avg_context=200000)")
+	f.filter = registerFilterFlags(fs)
+	return f
+}
+
+// validate は出力形式と集計単位を検証し、絞り込み条件を返す｡
+func (f *reportFlags) validate() (*ReportFilter, error) {
+	if *f.groupBy != "" {
+		if err := ValidateGroupBy(*f.groupBy); err != nil {
+			return nil, err
+		}
+	}
+	if err := ValidateFormat(*f.format, *f.groupBy != ""); err != nil {
+		return nil, err
+	}
+	return f.filter.build()
+}
+
+// loadReportConfig は設定ファイルがあれば読み込み、なければ組み込みの設定を返す｡
+func loadReportConfig(configPath, home string) (*Config, error) {
+	cPath := ResolveConfigPath(configPath, home)
+	if cPath == "" {
+		return DefaultConfig(), nil
+	}
+	cfg, err := LoadConfig(cPath)
+	if err != nil {
+		return nil, fmt.Errorf("設定ファイルの読み込み失敗: %w", err)
+	}
+	return cfg, nil
+}
+
+// assembleReport はセッションの集計に料金・絞り込み条件・設定の健全性と推定消費を加えたレポートを作る｡
+func assembleReport(results []SessionResult, cfg *Config, f *reportFlags, filter *ReportFilter, home, pricingPath string, unpriced []string) Report {
+	report := GenerateReportWithConfig(results, *f.topN, cfg)
+	report.Summary.Days = filter.ScanDays(*f.days, time.Now())
+	report.Summary.PricingFile = pricingPath
+	report.Summary.UnpricedModels = unpriced
+	report.Summary.Filter = filter
+
+	health := collectConfigHealth(home, *f.settingsPath)
+	report.ConfigHealth = &health
+	report.ConfigImpact = BuildConfigImpact(NewImpactSources(home, health.EnabledPluginNames), results)
+	report.Warnings = append(report.Warnings, generateConfigWarnings(health, cfg.Thresholds)...)
+	report.Warnings = append(report.Warnings, generateImpactWarnings(report.ConfigImpact, cfg.Thresholds)...)
+	return report
+}
+
 func main() {
-	days := flag.Int("days", 30, "分析対象期間(日数)")
-	topN := flag.Int("top", 10, "表示するTop Nセッション数")
-	projectsDir := flag.String("dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)")
-	settingsPath := flag.String("settings", "", "settings.jsonのパス (デフォルト: ~/.claude/settings.json)")
-	warningsOnly := flag.Bool("warnings-only", false, "警告とconfig_healthのみ出力")
+	if len(os.Args) > 1 {
+		if code, ok := runSubcommand(os.Args[1], os.Args[2:]); ok {
+			os.Exit(code)
+		}
+	}
+
+	f := registerReportFlags(flag.CommandLine)
 	flag.Parse()
+	filter, err := f.validate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
 
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -302,56 +638,101 @@ func main() {
 		os.Exit(1)
 	}
 
-	dir := pathutil.ResolveProjectsDir(*projectsDir, home)
+	cfg, err := loadReportConfig(*f.configPath, home)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	for _, t := range f.thresholds {
+		if err := cfg.Thresholds.Set(t); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+	}
 
-	results, err := ScanProjectsDir(dir, *days)
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	dir := pathutil.ResolveProjectsDir(*f.projectsDir, home)
+
+	results, err := scanProjects(dir, filter.ScanDays(*f.days, time.Now()), filter.ScanProject(), *f.cachePath, *f.noCache, resolver, os.Stderr)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "スキャン失敗: %v\n", err)
 		os.Exit(1)
 	}
+	results = filter.Apply(results)
 
-	report := GenerateReport(results, *topN)
-	report.Summary.Days = *days
+	pPath := ResolvePricingPath(*f.pricingPath, home)
+	unpriced, err := applyPricingFile(results, pPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
-	// ConfigHealth: グローバル設定の健全性チェック
-	sPath := *settingsPath
-	if sPath == "" {
-		sPath = filepath.Join(home, ".claude", "settings.json")
+	if *f.groupBy != "" {
+		since := filter.WindowStart(*f.days, time.Now())
+		if err := writeTimeSeries(os.Stdout, results, *f.groupBy, *f.format, *f.days, since); err != nil {
+			fmt.Fprintf(os.Stderr, "時系列出力失敗: %v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
-	skillsDir := filepath.Join(home, ".claude", "skills")
-	health := ConfigHealth{
-		GlobalSkills:   CountGlobalSkills(skillsDir),
-		GlobalSkillNames: ListGlobalSkillNames(skillsDir),
+
+	report := assembleReport(results, cfg, f, filter, home, pPath, unpriced)
+	if err := writeReport(os.Stdout, report, *f.format, *f.warningsOnly); err != nil {
+		fmt.Fprintf(os.Stderr, "出力失敗: %v\n", err)
+		os.Exit(1)
 	}
-	if s, err := settings.Load(sPath); err == nil {
-		health.EnabledPlugins = s.CountEnabledPlugins()
-		health.EnabledPluginNames = s.ListEnabledPluginNames()
+}
+
+// writeReport はレポートを指定の形式で書き出す｡
+// warningsOnly なら summary、config_health、config_impact、warnings のみ出力する｡
+func writeReport(w io.Writer, report Report, format string, warningsOnly bool) error {
+	if warningsOnly {
+		report = Report{Summary: report.Summary, ConfigHealth: report.ConfigHealth, ConfigImpact: report.ConfigImpact, Warnings: report.Warnings}
+	}
+	switch format {
+	case FormatSummary:
+		_, err := fmt.Fprint(w, FormatReportSummary(report))
+		return err
+	case FormatTable:
+		_, err := fmt.Fprint(w, FormatReportTable(report))
+		return err
+	case FormatMarkdown:
+		_, err := fmt.Fprint(w, FormatReportMarkdown(report))
+		return err
+	case FormatCSV:
+		return WriteReportCSV(w, report)
 	}
-	report.ConfigHealth = &health
-	report.Warnings = append(report.Warnings, generateConfigWarnings(health)...)
 
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-
-	if *warningsOnly {
+	if warningsOnly {
 		compact := struct {
 			Summary      ReportSummary `json:"summary"`
 			ConfigHealth *ConfigHealth `json:"config_health,omitempty"`
+			ConfigImpact *ConfigImpact `json:"config_impact,omitempty"`
 			Warnings     []Warning     `json:"warnings"`
 		}{
 			Summary:      report.Summary,
 			ConfigHealth: report.ConfigHealth,
+			ConfigImpact: report.ConfigImpact,
 			Warnings:     report.Warnings,
 		}
-		if err := encoder.Encode(compact); err != nil {
-			fmt.Fprintf(os.Stderr, "JSON出力失敗: %v\n", err)
-			os.Exit(1)
-		}
-		return
+		return encoder.Encode(compact)
 	}
+	return encoder.Encode(report)
+}
 
-	if err := encoder.Encode(report); err != nil {
-		fmt.Fprintf(os.Stderr, "JSON出力失敗: %v\n", err)
-		os.Exit(1)
+// writeTimeSeries は --group-by 指定時の時系列を JSON または CSV で書き出す｡
+func writeTimeSeries(w io.Writer, results []SessionResult, groupBy, format string, days int, since time.Time) error {
+	buckets := BuildTimeSeries(results, groupBy, since)
+	if format == FormatCSV {
+		return WriteTimeSeriesCSV(w, buckets)
 	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(TimeSeries{GroupBy: groupBy, Days: days, Buckets: buckets})
 }
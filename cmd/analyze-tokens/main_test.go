@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -225,13 +226,91 @@ func TestGenerateWarnings(t *testing.T) {
 	})
 }
 
+func TestCacheEfficiency(t *testing.T) {
+	results := []SessionResult{
+		{SessionID: "s1", Project: "cached", APICallCount: 20, TotalInputTokens: 2000, TotalCacheReadTokens: 90000, TotalCacheCreationTokens: 8000,
+			ModelUsage: map[string]ModelTokens{"claude-opus-4-6": {InputTokens: 2000, CacheReadTokens: 90000, CacheCreationTokens: 8000, CallCount: 20}}},
+		{SessionID: "s2", Project: "uncached", APICallCount: 20, TotalInputTokens: 10000, TotalCacheReadTokens: 20000, TotalCacheCreationTokens: 70000},
+		{SessionID: "s3", Project: "few-calls", APICallCount: 5, TotalInputTokens: 5000},
+	}
+	report := GenerateReport(results, 10)
+
+	t.Run("全体の実効コンテキストとcache hit ratio", func(t *testing.T) {
+		if report.Summary.AverageContextPerCall != 4555 {
+			t.Errorf("AverageContextPerCall = %d, want %d", report.Summary.AverageContextPerCall, 4555)
+		}
+		if report.Summary.CacheHitRatio != 0.537 {
+			t.Errorf("CacheHitRatio = %v, want %v", report.Summary.CacheHitRatio, 0.537)
+		}
+		if report.Summary.TotalCacheReadTokens != 110000 || report.Summary.TotalCacheCreationTokens != 78000 {
+			t.Errorf("cache tokens = (%d, %d)", report.Summary.TotalCacheReadTokens, report.Summary.TotalCacheCreationTokens)
+		}
+	})
+
+	t.Run("プロジェクト・モデル・セッション別のcache hit ratio", func(t *testing.T) {
+		for _, ps := range report.ProjectSummary {
+			if ps.Project == "cached" && (ps.CacheHitRatio != 0.9 || ps.AverageContextPerCall != 5000) {
+				t.Errorf("cached = %+v", ps)
+			}
+		}
+		if len(report.ModelSummary) != 1 || report.ModelSummary[0].CacheHitRatio != 0.9 {
+			t.Errorf("ModelSummary = %+v", report.ModelSummary)
+		}
+		for _, s := range report.TopSessions {
+			if s.SessionID == "s2" && s.CacheHitRatio != 0.2 {
+				t.Errorf("s2 CacheHitRatio = %v, want 0.2", s.CacheHitRatio)
+			}
+		}
+	})
+
+	t.Run("cache hit ratioが低いプロジェクトで警告", func(t *testing.T) {
+		var got []Warning
+		for _, w := range report.Warnings {
+			if w.Type == "low_cache_hit_ratio" {
+				got = append(got, w)
+			}
+		}
+		// few-calls はサンプル不足で対象外
+		if len(got) != 1 || got[0].Project != "uncached" || got[0].Value != 20 {
+			t.Errorf("low_cache_hit_ratio警告 = %+v, want uncached(20%%)のみ", got)
+		}
+	})
+
+	t.Run("inputの平均警告はcacheを含めずに判定", func(t *testing.T) {
+		results := []SessionResult{
+			{SessionID: "s1", Project: "cached", APICallCount: 10, TotalInputTokens: 1000, TotalCacheReadTokens: 850000, TotalCacheCreationTokens: 49000},
+		}
+		for _, w := range GenerateReport(results, 10).Warnings {
+			if w.Type == "high_avg_input" || w.Type == "global_high_avg" || w.Type == "high_avg_context" {
+				t.Errorf("cacheが効いた平均90Kのコンテキストで警告してはいけない: %+v", w)
+			}
+		}
+	})
+
+	t.Run("実効コンテキストの平均が閾値を超えると警告", func(t *testing.T) {
+		results := []SessionResult{
+			{SessionID: "s1", Project: "heavy", APICallCount: 10, TotalInputTokens: 1000, TotalCacheReadTokens: 1550000, TotalCacheCreationTokens: 49000},
+		}
+		var got []string
+		for _, w := range GenerateReport(results, 10).Warnings {
+			got = append(got, w.Type)
+			if w.Type == "high_avg_context" && (w.Project != "heavy" || w.Value != 160000) {
+				t.Errorf("high_avg_context = %+v, want heavy/160000", w)
+			}
+		}
+		if !reflect.DeepEqual(got, []string{"global_high_avg_context", "high_avg_context"}) {
+			t.Errorf("warnings = %v", got)
+		}
+	})
+}
+
 func TestConfigHealth(t *testing.T) {
 	t.Run("プラグイン数が多い場合に警告", func(t *testing.T) {
 		health := ConfigHealth{
 			EnabledPlugins: 15,
 			GlobalSkills:   10,
 		}
-		warnings := generateConfigWarnings(health)
+		warnings := generateConfigWarnings(health, DefaultThresholds())
 		found := false
 		for _, w := range warnings {
 			if w.Type == "too_many_plugins" {
@@ -251,7 +330,7 @@ func TestConfigHealth(t *testing.T) {
 			EnabledPlugins: 5,
 			GlobalSkills:   20,
 		}
-		warnings := generateConfigWarnings(health)
+		warnings := generateConfigWarnings(health, DefaultThresholds())
 		found := false
 		for _, w := range warnings {
 			if w.Type == "too_many_skills" {
@@ -271,7 +350,7 @@ func TestConfigHealth(t *testing.T) {
 			EnabledPlugins: 10,
 			GlobalSkills:   15,
 		}
-		warnings := generateConfigWarnings(health)
+		warnings := generateConfigWarnings(health, DefaultThresholds())
 		if len(warnings) != 0 {
 			t.Errorf("len(warnings) = %d, want 0", len(warnings))
 		}
@@ -344,7 +423,7 @@ func TestWarningRecommendations(t *testing.T) {
 
 		// config warnings も追加
 		health := ConfigHealth{EnabledPlugins: 15, GlobalSkills: 20}
-		report.Warnings = append(report.Warnings, generateConfigWarnings(health)...)
+		report.Warnings = append(report.Warnings, generateConfigWarnings(health, DefaultThresholds())...)
 
 		for _, w := range report.Warnings {
 			if w.Recommendation == "" {
@@ -389,3 +468,60 @@ func TestTopNSelection(t *testing.T) {
 		}
 	})
 }
+
+func TestSidechainSessions(t *testing.T) {
+	results := []SessionResult{
+		{SessionID: "parent", Project: "proj-a", TotalInputTokens: 10000, APICallCount: 5, CostUSD: 1},
+		{SessionID: "agent-1", Project: "proj-a", IsSidechain: true, ParentSessionID: "parent", TotalInputTokens: 30000, APICallCount: 10, CostUSD: 2},
+		{SessionID: "agent-2", Project: "proj-a", IsSidechain: true, ParentSessionID: "parent", TotalInputTokens: 5000, APICallCount: 2},
+		{SessionID: "other", Project: "proj-a", TotalInputTokens: 20000, APICallCount: 5},
+		{SessionID: "agent-3", Project: "proj-b", IsSidechain: true, ParentSessionID: "outside", TotalInputTokens: 1000, APICallCount: 1},
+	}
+	report := GenerateReport(results, 10)
+
+	t.Run("sidechainは起動元のセッションとして数える", func(t *testing.T) {
+		if report.Summary.TotalSessions != 3 {
+			t.Errorf("TotalSessions = %d, want 3", report.Summary.TotalSessions)
+		}
+		for _, ps := range report.ProjectSummary {
+			if want := map[string]int{"proj-a": 2, "proj-b": 1}[ps.Project]; ps.SessionCount != want {
+				t.Errorf("%s SessionCount = %d, want %d", ps.Project, ps.SessionCount, want)
+			}
+		}
+	})
+
+	t.Run("Topセッションはsidechainを起動元に合算する", func(t *testing.T) {
+		if len(report.TopSessions) != 3 {
+			t.Fatalf("len(TopSessions) = %d, want 3: %+v", len(report.TopSessions), report.TopSessions)
+		}
+		top := report.TopSessions[0]
+		if top.SessionID != "parent" || top.TotalInputTokens != 45000 || top.APICallCount != 17 || top.CostUSD != 3 || top.SubagentSessions != 2 {
+			t.Errorf("TopSessions[0] = %+v", top)
+		}
+		if orphan := report.TopSessions[2]; orphan.SessionID != "agent-3" || !orphan.IsSidechain {
+			t.Errorf("起動元のないsidechainは単独で残る: %+v", orphan)
+		}
+		if results[0].TotalInputTokens != 10000 {
+			t.Error("入力のセッション結果を変更してはいけない")
+		}
+	})
+
+	t.Run("起動元のprogressとsidechainの両方にあるsubagentは二重に数えない", func(t *testing.T) {
+		both := []SessionResult{
+			{SessionID: "parent", TotalInputTokens: 10000 + 3000, APICallCount: 5 + 1, CostUSD: 1.5,
+				Calls: []APICall{
+					{Usage: tokenUsage{InputTokens: 10000}, CostUSD: 1},
+					{Usage: tokenUsage{InputTokens: 3000}, CostUSD: 0.5, Subagent: true, ParentToolUseID: "toolu_t1"},
+				},
+				agentTasks: map[string]string{"a1": "toolu_t1"}},
+			{SessionID: "a1", AgentID: "a1", IsSidechain: true, ParentSessionID: "parent", TotalInputTokens: 3000, APICallCount: 1, CostUSD: 0.5},
+		}
+		top := topSessions(both, 10)
+		if len(top) != 1 || top[0].TotalInputTokens != 13000 || top[0].APICallCount != 6 || top[0].CostUSD != 1.5 || top[0].SubagentSessions != 1 {
+			t.Errorf("TopSessions = %+v", top)
+		}
+		if both[0].TotalInputTokens != 13000 {
+			t.Error("入力のセッション結果を変更してはいけない")
+		}
+	})
+}
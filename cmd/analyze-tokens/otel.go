@@ -0,0 +1,445 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+// otelScopeName は OTLP の instrumentation scope 名｡
+const otelScopeName = "github.com/usadamasa/claude-config/analyze-tokens"
+
+// otelServiceName は resource の service.name｡
+const otelServiceName = "claude-code"
+
+// OTLP の enum 値｡
+const (
+	otlpSpanKindInternal       = 1
+	otlpSpanKindClient         = 3
+	otlpTemporalityCumulative  = 2
+	otlpSignalTraces           = "traces"
+	otlpSignalMetrics          = "metrics"
+	otlpDefaultExportSignalSet = otlpSignalTraces + "," + otlpSignalMetrics
+)
+
+// OTLP/JSON のメッセージ｡フィールド名は protobuf の JSON マッピング(lowerCamelCase)に従い、
+// 64bit 整数と時刻は文字列、trace ID と span ID は16進文字列で表す｡
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+// otlpTracesData は ExportTraceServiceRequest の JSON 表現｡
+type otlpTracesData struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             *string        `json:"asInt,omitempty"`
+	AsDouble          *float64       `json:"asDouble,omitempty"`
+}
+
+type otlpSum struct {
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
+	Sum         otlpSum `json:"sum"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+// otlpMetricsData は ExportMetricsServiceRequest の JSON 表現｡
+type otlpMetricsData struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func otlpString(key, v string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &v}}
+}
+
+func otlpInt(key string, v int64) otlpKeyValue {
+	s := strconv.FormatInt(v, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpDouble(key string, v float64) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &v}}
+}
+
+func otlpBool(key string, v bool) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{BoolValue: &v}}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpResourceAttributes() otlpResource {
+	return otlpResource{Attributes: []otlpKeyValue{otlpString("service.name", otelServiceName)}}
+}
+
+// otlpID は key から決まる bytes バイトの ID を16進文字列で返す｡
+// 同じセッションを再度エクスポートしても同じ ID になり、バックエンドで重複を判別できる｡
+func otlpID(key string, bytes int) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:bytes])
+}
+
+// sessionTraceKey はセッションが属するトレースの鍵｡sidechain は起動元のセッションのトレースに含める｡
+func sessionTraceKey(r SessionResult) string {
+	if r.IsSidechain && r.ParentSessionID != "" {
+		return r.ParentSessionID
+	}
+	return r.SessionID
+}
+
+// sessionSpanKey はセッションのルートスパンの鍵｡
+func sessionSpanKey(r SessionResult) string {
+	if r.IsSidechain && r.ParentSessionID != "" {
+		return r.ParentSessionID + "/agent/" + r.SessionID
+	}
+	return r.SessionID
+}
+
+// sessionTimeRange はセッションの最初と最後の API コールの時刻を返す｡
+func sessionTimeRange(r SessionResult) (start, end time.Time) {
+	for _, c := range r.Calls {
+		if start.IsZero() || c.Timestamp.Before(start) {
+			start = c.Timestamp
+		}
+		if c.Timestamp.After(end) {
+			end = c.Timestamp
+		}
+	}
+	return start, end
+}
+
+// sessionSpans はセッション1つをルートスパンと API コールごとの子スパンに変換する｡
+// ツール呼び出しは子スパンのイベント、compaction はルートスパンのイベントとする｡
+// sidechain のルートスパンは起動元セッションのルートスパンの子にする｡
+func sessionSpans(r SessionResult) []otlpSpan {
+	traceID := otlpID(sessionTraceKey(r), 16)
+	rootKey := sessionSpanKey(r)
+	start, end := sessionTimeRange(r)
+	root := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            otlpID(rootKey, 8),
+		Name:              "session",
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: otlpTime(start),
+		EndTimeUnixNano:   otlpTime(end),
+		Attributes: []otlpKeyValue{
+			otlpString("session.id", r.SessionID),
+			otlpString("project", r.Project),
+			otlpInt("gen_ai.usage.input_tokens", r.TotalInputTokens),
+			otlpInt("gen_ai.usage.output_tokens", r.TotalOutputTokens),
+			otlpInt("gen_ai.usage.cache_read_input_tokens", r.TotalCacheReadTokens),
+			otlpInt("gen_ai.usage.cache_creation_input_tokens", r.TotalCacheCreationTokens),
+			otlpInt("api_calls", int64(r.APICallCount)),
+			otlpInt("user_messages", int64(r.UserMessageCount)),
+		},
+	}
+	if r.ProjectID != "" {
+		root.Attributes = append(root.Attributes, otlpString("project.id", r.ProjectID))
+	}
+	if r.CostUSD > 0 {
+		root.Attributes = append(root.Attributes, otlpDouble("cost_usd", r.CostUSD))
+	}
+	if r.IsSidechain {
+		root.Name = "subagent"
+		if r.ParentSessionID != "" {
+			root.ParentSpanID = otlpID(r.ParentSessionID, 8)
+		}
+		root.Attributes = append(root.Attributes, otlpString("agent.id", r.AgentID), otlpBool("sidechain", true))
+	}
+	for _, c := range r.Compactions {
+		root.Events = append(root.Events, otlpEvent{
+			TimeUnixNano: otlpTime(c.Timestamp),
+			Name:         "compaction",
+			Attributes:   []otlpKeyValue{otlpString("trigger", c.Trigger), otlpInt("pre_tokens", c.PreTokens)},
+		})
+	}
+
+	spans := []otlpSpan{root}
+	for i, c := range r.Calls {
+		spans = append(spans, callSpan(traceID, root.SpanID, rootKey, i, c))
+	}
+	return spans
+}
+
+// callSpan は API コール1回を子スパンに変換する｡トランスクリプトには応答の時刻しかないため、開始と終了は同じ時刻になる｡
+func callSpan(traceID, parentSpanID, rootKey string, i int, c APICall) otlpSpan {
+	ts := otlpTime(c.Timestamp)
+	span := otlpSpan{
+		TraceID:           traceID,
+		SpanID:            otlpID(rootKey+"/call/"+strconv.Itoa(i), 8),
+		ParentSpanID:      parentSpanID,
+		Name:              "chat " + c.Model,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: ts,
+		EndTimeUnixNano:   ts,
+		Attributes: []otlpKeyValue{
+			otlpString("gen_ai.system", "anthropic"),
+			otlpString("gen_ai.request.model", c.Model),
+			otlpInt("gen_ai.usage.input_tokens", c.Usage.InputTokens),
+			otlpInt("gen_ai.usage.output_tokens", c.Usage.OutputTokens),
+			otlpInt("gen_ai.usage.cache_read_input_tokens", c.Usage.CacheReadInputTokens),
+			otlpInt("gen_ai.usage.cache_creation_input_tokens", c.Usage.CacheCreationInputTokens),
+		},
+	}
+	if c.CostUSD > 0 {
+		span.Attributes = append(span.Attributes, otlpDouble("cost_usd", c.CostUSD))
+	}
+	if c.Subagent {
+		span.Attributes = append(span.Attributes, otlpBool("subagent", true), otlpString("parent_tool_use_id", c.ParentToolUseID))
+	}
+	for _, tool := range c.Tools {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: ts,
+			Name:         "tool_use",
+			Attributes:   []otlpKeyValue{otlpString("tool.name", tool)},
+		})
+	}
+	return span
+}
+
+// BuildOTLPTraces はセッションを OTLP/JSON のトレースに変換する｡
+func BuildOTLPTraces(results []SessionResult) otlpTracesData {
+	var spans []otlpSpan
+	for _, r := range results {
+		spans = append(spans, sessionSpans(r)...)
+	}
+	return otlpTracesData{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResourceAttributes(),
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: otelScopeName}, Spans: spans}},
+	}}}
+}
+
+// BuildOTLPMetrics はセッション・モデルごとの token 数と API コール数を累積 sum に変換する｡
+// 各データポイントの区間はセッションの最初から最後の API コールまで｡
+func BuildOTLPMetrics(results []SessionResult) otlpMetricsData {
+	tokens := otlpMetric{Name: "gen_ai.client.token.usage", Description: "Tokens used per session and model.", Unit: "{token}",
+		Sum: otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}}
+	calls := otlpMetric{Name: "gen_ai.client.api_calls", Description: "API calls per session and model.", Unit: "{call}",
+		Sum: otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}}
+	cost := otlpMetric{Name: "gen_ai.client.cost", Description: "Estimated cost per session and model.", Unit: "USD",
+		Sum: otlpSum{AggregationTemporality: otlpTemporalityCumulative, IsMonotonic: true}}
+
+	for _, r := range results {
+		start, end := sessionTimeRange(r)
+		for _, model := range sortedKeys(r.ModelUsage) {
+			mt := r.ModelUsage[model]
+			attrs := func(extra ...otlpKeyValue) []otlpKeyValue {
+				return append([]otlpKeyValue{
+					otlpString("session.id", r.SessionID),
+					otlpString("project", r.Project),
+					otlpString("gen_ai.request.model", model),
+				}, extra...)
+			}
+			point := func(v int64, extra ...otlpKeyValue) otlpNumberDataPoint {
+				s := strconv.FormatInt(v, 10)
+				return otlpNumberDataPoint{Attributes: attrs(extra...), StartTimeUnixNano: otlpTime(start), TimeUnixNano: otlpTime(end), AsInt: &s}
+			}
+			for _, t := range []struct {
+				typ   string
+				value int64
+			}{
+				{"input", mt.InputTokens},
+				{"output", mt.OutputTokens},
+				{"cache_read", mt.CacheReadTokens},
+				{"cache_creation", mt.CacheCreationTokens},
+			} {
+				tokens.Sum.DataPoints = append(tokens.Sum.DataPoints, point(t.value, otlpString("gen_ai.token.type", t.typ)))
+			}
+			calls.Sum.DataPoints = append(calls.Sum.DataPoints, point(int64(mt.CallCount)))
+			if mt.CostUSD > 0 {
+				v := mt.CostUSD
+				cost.Sum.DataPoints = append(cost.Sum.DataPoints, otlpNumberDataPoint{
+					Attributes: attrs(), StartTimeUnixNano: otlpTime(start), TimeUnixNano: otlpTime(end), AsDouble: &v})
+			}
+		}
+	}
+
+	metrics := []otlpMetric{tokens, calls}
+	if len(cost.Sum.DataPoints) > 0 {
+		metrics = append(metrics, cost)
+	}
+	return otlpMetricsData{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResourceAttributes(),
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: otelScopeName}, Metrics: metrics}},
+	}}}
+}
+
+// parseSignals は --signal の "traces,metrics" 形式の指定をパースする｡
+func parseSignals(s string) (traces, metrics bool, err error) {
+	for _, sig := range strings.Split(s, ",") {
+		switch strings.TrimSpace(sig) {
+		case otlpSignalTraces:
+			traces = true
+		case otlpSignalMetrics:
+			metrics = true
+		default:
+			return false, false, fmt.Errorf("不明なシグナル: %q (traces または metrics)", sig)
+		}
+	}
+	return traces, metrics, nil
+}
+
+// WriteOTLP はトレースとメトリクスを1行1リクエストの OTLP/JSON Lines で書き出す｡
+// OpenTelemetry Collector の otlpjsonfile receiver でそのまま読み込める形式｡
+func WriteOTLP(w io.Writer, results []SessionResult, traces, metrics bool) error {
+	sorted := make([]SessionResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		si, _ := sessionTimeRange(sorted[i])
+		sj, _ := sessionTimeRange(sorted[j])
+		return si.Before(sj)
+	})
+	encoder := json.NewEncoder(w)
+	if traces {
+		if err := encoder.Encode(BuildOTLPTraces(sorted)); err != nil {
+			return err
+		}
+	}
+	if metrics {
+		if err := encoder.Encode(BuildOTLPMetrics(sorted)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runExport は export サブコマンドを実行し、終了コードを返す｡
+func runExport(args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("export", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	output := fset.String("output", "-", "出力先ファイル (- は標準出力)")
+	signals := fset.String("signal", otlpDefaultExportSignalSet, "出力するシグナル: traces, metrics (カンマ区切り)")
+	days := fset.Int("days", 30, "分析対象期間(日数)")
+	projectsDir := fset.String("dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)")
+	pricingPath := fset.String("pricing", "", "料金表JSONのパス (デフォルト: ~/.claude/"+pricingFileName+" があれば使用)")
+	cachePath := fset.String("cache", "", "スキャン結果のキャッシュファイル (デフォルト: ユーザーキャッシュディレクトリ配下)")
+	noCache := fset.Bool("no-cache", false, "キャッシュを使わずに全ファイルを解析する")
+	fset.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze-tokens export [flags]\nセッションを OTLP/JSON Lines で書き出す\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	traces, metrics, err := parseSignals(*signals)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 2
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(stderr, "ホームディレクトリ取得失敗: %v\n", err)
+		return 1
+	}
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	results, err := scanWithPricing(pathutil.ResolveProjectsDir(*projectsDir, home), *days,
+		ResolvePricingPath(*pricingPath, home), *cachePath, *noCache, resolver, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	if err := writeOTLPOutput(*output, stdout, results, traces, metrics); err != nil {
+		fmt.Fprintf(stderr, "OTLP出力失敗: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeOTLPOutput は output が "-" なら stdout に、それ以外はファイルに OTLP/JSON Lines を書き出す｡
+func writeOTLPOutput(output string, stdout io.Writer, results []SessionResult, traces, metrics bool) error {
+	if output == "-" {
+		return WriteOTLP(stdout, results, traces, metrics)
+	}
+	f, err := os.Create(output) // #nosec G304 -- CLIツール: パスはフラグ引数由来
+	if err != nil {
+		return err
+	}
+	if err := WriteOTLP(f, results, traces, metrics); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func otlpAttr(attrs []otlpKeyValue, key string) (otlpAnyValue, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return otlpAnyValue{}, false
+}
+
+func TestBuildOTLPTraces(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	parent := SessionResult{
+		SessionID: "sess-1", Project: "alpha", APICallCount: 2, TotalInputTokens: 300,
+		Calls: []APICall{
+			{Timestamp: t0, Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 100, OutputTokens: 10}, Tools: []string{"Read", "Task"}},
+			{Timestamp: t0.Add(time.Minute), Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 200, CacheReadInputTokens: 50}, CostUSD: 0.25},
+		},
+		Compactions: []CompactionEvent{{Timestamp: t0.Add(30 * time.Second), Trigger: "auto", PreTokens: 150000}},
+	}
+	agent := SessionResult{
+		SessionID: "agent-1", Project: "alpha", IsSidechain: true, AgentID: "agent-1", ParentSessionID: "sess-1",
+		Calls: []APICall{{Timestamp: t0.Add(10 * time.Second), Model: "claude-haiku-4-5", Usage: tokenUsage{InputTokens: 5}}},
+	}
+	data := BuildOTLPTraces([]SessionResult{parent, agent})
+	spans := data.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 5 {
+		t.Fatalf("spans = %d, want 5", len(spans))
+	}
+	root, call0, call1, agentRoot := spans[0], spans[1], spans[2], spans[3]
+
+	t.Run("セッションはルートスパン", func(t *testing.T) {
+		if root.Name != "session" || root.ParentSpanID != "" || len(root.TraceID) != 32 || len(root.SpanID) != 16 {
+			t.Errorf("root = %+v", root)
+		}
+		if root.StartTimeUnixNano != otlpTime(t0) || root.EndTimeUnixNano != otlpTime(t0.Add(time.Minute)) {
+			t.Errorf("root time = %s..%s", root.StartTimeUnixNano, root.EndTimeUnixNano)
+		}
+		if len(root.Events) != 1 || root.Events[0].Name != "compaction" {
+			t.Errorf("events = %+v", root.Events)
+		}
+	})
+
+	t.Run("APIコールは子スパンでツールはイベント", func(t *testing.T) {
+		if call0.ParentSpanID != root.SpanID || call0.TraceID != root.TraceID || call0.Name != "chat claude-opus-4-6" {
+			t.Errorf("call0 = %+v", call0)
+		}
+		if v, _ := otlpAttr(call0.Attributes, "gen_ai.usage.input_tokens"); v.IntValue == nil || *v.IntValue != "100" {
+			t.Errorf("input_tokens = %+v", v)
+		}
+		if len(call0.Events) != 2 || call0.Events[1].Name != "tool_use" {
+			t.Errorf("events = %+v", call0.Events)
+		}
+		if v, ok := otlpAttr(call1.Attributes, "cost_usd"); !ok || *v.DoubleValue != 0.25 {
+			t.Errorf("cost_usd = %+v", v)
+		}
+		if call0.SpanID == call1.SpanID {
+			t.Error("コールごとに異なる span ID")
+		}
+	})
+
+	t.Run("sidechainは起動元のトレースに含める", func(t *testing.T) {
+		if agentRoot.Name != "subagent" || agentRoot.TraceID != root.TraceID || agentRoot.ParentSpanID != root.SpanID {
+			t.Errorf("agent = %+v", agentRoot)
+		}
+	})
+
+	t.Run("同じセッションは同じID", func(t *testing.T) {
+		again := BuildOTLPTraces([]SessionResult{parent}).ResourceSpans[0].ScopeSpans[0].Spans
+		if again[0].TraceID != root.TraceID || again[1].SpanID != call0.SpanID {
+			t.Error("ID が決定的でない")
+		}
+	})
+}
+
+func TestBuildOTLPMetrics(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	r := SessionResult{
+		SessionID: "sess-1", Project: "alpha",
+		Calls:      []APICall{{Timestamp: t0}, {Timestamp: t0.Add(time.Hour)}},
+		ModelUsage: map[string]ModelTokens{"claude-opus-4-6": {InputTokens: 100, OutputTokens: 20, CallCount: 2, CostUSD: 1.5}},
+	}
+	metrics := BuildOTLPMetrics([]SessionResult{r}).ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 3 || metrics[0].Name != "gen_ai.client.token.usage" {
+		t.Fatalf("metrics = %+v", metrics)
+	}
+	tokens := metrics[0].Sum
+	if !tokens.IsMonotonic || tokens.AggregationTemporality != otlpTemporalityCumulative || len(tokens.DataPoints) != 4 {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	p := tokens.DataPoints[0]
+	if *p.AsInt != "100" || p.StartTimeUnixNano != otlpTime(t0) || p.TimeUnixNano != otlpTime(t0.Add(time.Hour)) {
+		t.Errorf("point = %+v", p)
+	}
+	if v, _ := otlpAttr(p.Attributes, "gen_ai.token.type"); *v.StringValue != "input" {
+		t.Errorf("type = %+v", v)
+	}
+	if *metrics[1].Sum.DataPoints[0].AsInt != "2" || *metrics[2].Sum.DataPoints[0].AsDouble != 1.5 {
+		t.Errorf("calls/cost = %+v / %+v", metrics[1], metrics[2])
+	}
+}
+
+func TestRunExport(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	dir := t.TempDir()
+	ts := time.Now().UTC().Format(time.RFC3339)
+	writeTestJSONL(t, dir, "s1.jsonl",
+		`{"type":"assistant","cwd":"/Users/test/alpha","sessionId":"s1","timestamp":"`+ts+`","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","name":"Read","input":{}}],"usage":{"input_tokens":5000,"output_tokens":100}}}
+`)
+
+	t.Run("ファイルに1行1リクエストで書き出す", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "usage.otlp.jsonl")
+		var stdout, stderr bytes.Buffer
+		if code := runExport([]string{"--dir", dir, "--output", out}, &stdout, &stderr); code != 0 {
+			t.Fatalf("code = %d, stderr = %s", code, stderr.String())
+		}
+		f, err := os.Open(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = f.Close() }()
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"resourceSpans"`) || !strings.HasPrefix(lines[1], `{"resourceMetrics"`) {
+			t.Fatalf("lines = %v", lines)
+		}
+		var traces otlpTracesData
+		if err := json.Unmarshal([]byte(lines[0]), &traces); err != nil {
+			t.Fatal(err)
+		}
+		if spans := traces.ResourceSpans[0].ScopeSpans[0].Spans; len(spans) != 2 || spans[1].Events[0].Name != "tool_use" {
+			t.Errorf("spans = %+v", spans)
+		}
+	})
+
+	t.Run("シグナルを選択できる", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runExport([]string{"--dir", dir, "--signal", "metrics"}, &stdout, &stderr); code != 0 {
+			t.Fatalf("code = %d, stderr = %s", code, stderr.String())
+		}
+		if strings.Count(stdout.String(), "\n") != 1 || !strings.Contains(stdout.String(), "resourceMetrics") {
+			t.Errorf("stdout = %s", stdout.String())
+		}
+		if code := runExport([]string{"--dir", dir, "--signal", "logs"}, &stdout, &stderr); code != 2 {
+			t.Errorf("不明なシグナルは使い方エラー: %d", code)
+		}
+	})
+}
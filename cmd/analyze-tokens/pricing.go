@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pricingFileName は ~/.claude 配下のデフォルト料金表ファイル名｡
+const pricingFileName = "token-pricing.json"
+
+// ModelPrice はモデル1つ分の料金(USD / 100万tokens)｡
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// Cost は usage の料金(USD)を返す｡
+func (p ModelPrice) Cost(usage tokenUsage) float64 {
+	return (float64(usage.InputTokens)*p.Input +
+		float64(usage.OutputTokens)*p.Output +
+		float64(usage.CacheCreationInputTokens)*p.CacheWrite +
+		float64(usage.CacheReadInputTokens)*p.CacheRead) / 1e6
+}
+
+// PricingTable はモデル名プレフィックスから料金を引く料金表｡
+type PricingTable struct {
+	Models map[string]ModelPrice `json:"models"`
+}
+
+// LoadPricing は料金表JSONを読み込む｡
+func LoadPricing(path string) (*PricingTable, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはフラグ引数由来
+	if err != nil {
+		return nil, err
+	}
+	var p PricingTable
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("料金表のパースに失敗 (%s): %w", path, err)
+	}
+	if len(p.Models) == 0 {
+		return nil, fmt.Errorf("料金表にモデルがありません (%s)", path)
+	}
+	return &p, nil
+}
+
+// ResolvePricingPath は料金表のパスを決定する｡
+// 未指定なら ~/.claude/token-pricing.json が存在する場合のみそれを使い、なければ空文字を返す｡
+func ResolvePricingPath(flagPath, home string) string {
+	if flagPath != "" {
+		return flagPath
+	}
+	path := filepath.Join(home, ".claude", pricingFileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// Lookup はモデル名に最長一致するプレフィックスの料金を返す｡
+func (t *PricingTable) Lookup(model string) (ModelPrice, bool) {
+	best := ""
+	for prefix := range t.Models {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return t.Models[best], true
+}
+
+// applyPricingFile は pricingPath の料金表を results に適用し、料金のないモデル名を返す｡
+// pricingPath が空なら何もしない｡
+func applyPricingFile(results []SessionResult, pricingPath string) ([]string, error) {
+	if pricingPath == "" {
+		return nil, nil
+	}
+	table, err := LoadPricing(pricingPath)
+	if err != nil {
+		return nil, fmt.Errorf("料金表の読み込み失敗: %w", err)
+	}
+	return ApplyPricing(results, table), nil
+}
+
+// ApplyPricing は各APIコール・セッション・モデル別集計に料金を設定する｡
+// 料金表にないモデル名をソート済みで返す｡
+func ApplyPricing(results []SessionResult, table *PricingTable) []string {
+	unpriced := make(map[string]bool)
+	for i := range results {
+		r := &results[i]
+		r.CostUSD = 0
+		for model, mt := range r.ModelUsage {
+			mt.CostUSD = 0
+			r.ModelUsage[model] = mt
+		}
+		for j := range r.Calls {
+			c := &r.Calls[j]
+			price, ok := table.Lookup(c.Model)
+			if !ok {
+				if c.Usage != (tokenUsage{}) {
+					unpriced[c.Model] = true
+				}
+				continue
+			}
+			c.CostUSD = price.Cost(c.Usage)
+			r.CostUSD += c.CostUSD
+			if mt, ok := r.ModelUsage[c.Model]; ok {
+				mt.CostUSD += c.CostUSD
+				r.ModelUsage[c.Model] = mt
+			}
+		}
+	}
+
+	var names []string
+	for model := range unpriced {
+		if model == "" {
+			model = "(unknown)"
+		}
+		names = append(names, model)
+	}
+	sort.Strings(names)
+	return names
+}
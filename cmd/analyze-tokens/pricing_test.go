@@ -0,0 +1,180 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestPricingTableLookup(t *testing.T) {
+	table := &PricingTable{Models: map[string]ModelPrice{
+		"claude-opus-4":   {Input: 15},
+		"claude-opus-4-5": {Input: 5},
+		"claude-haiku":    {Input: 1},
+	}}
+
+	tests := []struct {
+		model     string
+		wantInput float64
+		wantOK    bool
+	}{
+		{"claude-opus-4-5-20251101", 5, true},
+		{"claude-opus-4-20250514", 15, true},
+		{"claude-haiku-4-5-20251001", 1, true},
+		{"claude-sonnet-4-5", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, ok := table.Lookup(tt.model)
+			if ok != tt.wantOK || got.Input != tt.wantInput {
+				t.Errorf("Lookup(%q) = (%v, %v), want input %v, ok %v", tt.model, got, ok, tt.wantInput, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestModelPriceCost(t *testing.T) {
+	price := ModelPrice{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}
+	usage := tokenUsage{
+		InputTokens:              1_000_000,
+		OutputTokens:             100_000,
+		CacheCreationInputTokens: 200_000,
+		CacheReadInputTokens:     2_000_000,
+	}
+	// 3 + 1.5 + 0.75 + 0.6
+	if got := price.Cost(usage); !almostEqual(got, 5.85) {
+		t.Errorf("Cost = %v, want 5.85", got)
+	}
+}
+
+func TestLoadPricing(t *testing.T) {
+	t.Run("正常な料金表", func(t *testing.T) {
+		path := writeTestJSONL(t, t.TempDir(), "pricing.json",
+			`{"models":{"claude-opus-4":{"input":15,"output":75,"cache_write":18.75,"cache_read":1.5}}}`)
+		table, err := LoadPricing(path)
+		if err != nil {
+			t.Fatalf("LoadPricing失敗: %v", err)
+		}
+		if table.Models["claude-opus-4"].CacheRead != 1.5 {
+			t.Errorf("CacheRead = %v, want 1.5", table.Models["claude-opus-4"].CacheRead)
+		}
+	})
+
+	t.Run("不正なJSONはエラー", func(t *testing.T) {
+		path := writeTestJSONL(t, t.TempDir(), "pricing.json", `{"models":`)
+		if _, err := LoadPricing(path); err == nil {
+			t.Error("エラーが返されるべき")
+		}
+	})
+
+	t.Run("モデルが空ならエラー", func(t *testing.T) {
+		path := writeTestJSONL(t, t.TempDir(), "pricing.json", `{"models":{}}`)
+		if _, err := LoadPricing(path); err == nil {
+			t.Error("エラーが返されるべき")
+		}
+	})
+}
+
+func TestResolvePricingPath(t *testing.T) {
+	home := t.TempDir()
+
+	if got := ResolvePricingPath("", home); got != "" {
+		t.Errorf("料金表がない場合は空文字: got %q", got)
+	}
+	if got := ResolvePricingPath("/tmp/p.json", home); got != "/tmp/p.json" {
+		t.Errorf("フラグ指定が優先: got %q", got)
+	}
+
+	if err := os.MkdirAll(filepath.Join(home, ".claude"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := writeTestJSONL(t, filepath.Join(home, ".claude"), pricingFileName, `{}`)
+	if got := ResolvePricingPath("", home); got != path {
+		t.Errorf("デフォルトパス: got %q, want %q", got, path)
+	}
+}
+
+func TestApplyPricing(t *testing.T) {
+	table := &PricingTable{Models: map[string]ModelPrice{
+		"claude-opus-4":    {Input: 10, Output: 50},
+		"claude-haiku-4-5": {Input: 1, Output: 5},
+	}}
+	day1 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	day2 := time.Date(2026, 10, 2, 12, 0, 0, 0, time.Local)
+	results := []SessionResult{
+		{
+			Project: "alpha", APICallCount: 3,
+			ModelUsage: map[string]ModelTokens{
+				"claude-opus-4-6":           {InputTokens: 200_000, CallCount: 2},
+				"claude-haiku-4-5-20251001": {InputTokens: 1_000_000, CallCount: 1},
+			},
+			Calls: []APICall{
+				{Timestamp: day1, Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 100_000}},
+				{Timestamp: day2, Model: "claude-opus-4-6", Usage: tokenUsage{InputTokens: 100_000}},
+				{Timestamp: day2, Model: "claude-haiku-4-5-20251001", Usage: tokenUsage{InputTokens: 1_000_000}},
+			},
+		},
+		{
+			Project: "beta", APICallCount: 2,
+			ModelUsage: map[string]ModelTokens{"gpt-x": {InputTokens: 1000, CallCount: 1}},
+			Calls: []APICall{
+				{Timestamp: day1, Model: "gpt-x", Usage: tokenUsage{InputTokens: 1000}},
+				{Timestamp: day1, Model: "<synthetic>"},
+			},
+		},
+	}
+
+	unpriced := ApplyPricing(results, table)
+
+	t.Run("料金表にないモデルを返す", func(t *testing.T) {
+		if len(unpriced) != 1 || unpriced[0] != "gpt-x" {
+			t.Errorf("unpriced = %v, want [gpt-x]", unpriced)
+		}
+	})
+
+	t.Run("セッションとモデル別の料金", func(t *testing.T) {
+		if !almostEqual(results[0].CostUSD, 3) {
+			t.Errorf("session CostUSD = %v, want 3", results[0].CostUSD)
+		}
+		if got := results[0].ModelUsage["claude-opus-4-6"].CostUSD; !almostEqual(got, 2) {
+			t.Errorf("opus CostUSD = %v, want 2", got)
+		}
+		if results[1].CostUSD != 0 {
+			t.Errorf("料金表にないモデルの CostUSD = %v, want 0", results[1].CostUSD)
+		}
+	})
+
+	t.Run("レポートに集計される", func(t *testing.T) {
+		report := GenerateReport(results, 10)
+		if !almostEqual(report.Summary.TotalCostUSD, 3) {
+			t.Errorf("TotalCostUSD = %v, want 3", report.Summary.TotalCostUSD)
+		}
+		for _, ps := range report.ProjectSummary {
+			if ps.Project == "alpha" && !almostEqual(ps.CostUSD, 3) {
+				t.Errorf("alpha CostUSD = %v, want 3", ps.CostUSD)
+			}
+		}
+		for _, ms := range report.ModelSummary {
+			if ms.Model == "claude-haiku-4-5-20251001" && !almostEqual(ms.CostUSD, 1) {
+				t.Errorf("haiku CostUSD = %v, want 1", ms.CostUSD)
+			}
+		}
+		if len(report.DailyUsage) != 2 {
+			t.Fatalf("DailyUsage = %+v, want 2日分", report.DailyUsage)
+		}
+		d1, d2 := report.DailyUsage[0], report.DailyUsage[1]
+		if d1.Start != "2026-10-01" || d1.APICalls != 3 || !almostEqual(d1.CostUSD, 1) {
+			t.Errorf("day1 = %+v", d1)
+		}
+		if d2.Start != "2026-10-02" || d2.APICalls != 2 || !almostEqual(d2.CostUSD, 2) {
+			t.Errorf("day2 = %+v", d2)
+		}
+	})
+}
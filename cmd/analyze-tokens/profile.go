@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+// CallProfile はセッション内のAPIコール1回分のプロファイル｡
+type CallProfile struct {
+	Index               int       `json:"index"`
+	Timestamp           time.Time `json:"timestamp,omitzero"`
+	Model               string    `json:"model"`
+	InputTokens         int64     `json:"input_tokens"`
+	CacheReadTokens     int64     `json:"cache_read_tokens"`
+	CacheCreationTokens int64     `json:"cache_creation_tokens"`
+	OutputTokens        int64     `json:"output_tokens"`
+	ContextTokens       int64     `json:"context_tokens"`
+	// Growth は直前のメインチェーンのコールからの実効コンテキスト増分｡
+	// subagent のコールは別コンテキストのため 0｡
+	Growth   int64    `json:"growth"`
+	Tools    []string `json:"tools,omitempty"`
+	Subagent bool     `json:"subagent,omitempty"`
+}
+
+// SessionProfile は1セッションのターンごとのコンテキスト推移｡
+type SessionProfile struct {
+	SessionID   string            `json:"session_id"`
+	Project     string            `json:"project"`
+	FilePath    string            `json:"file_path"`
+	PeakContext int64             `json:"peak_context"`
+	Calls       []CallProfile     `json:"calls"`
+	Compactions []CompactionEvent `json:"compactions"`
+	TopGrowth   []CallProfile     `json:"top_growth"`
+}
+
+// BuildSessionProfile はスキャン結果からプロファイルを作る｡
+// TopGrowth はコンテキスト増分の大きい順に最大 topN 件｡topN が0以下なら全件｡
+func BuildSessionProfile(r *SessionResult, topN int) SessionProfile {
+	p := SessionProfile{
+		SessionID:   r.SessionID,
+		Project:     r.Project,
+		FilePath:    r.FilePath,
+		Calls:       []CallProfile{},
+		Compactions: r.Compactions,
+	}
+	if p.Compactions == nil {
+		p.Compactions = []CompactionEvent{}
+	}
+
+	var prev int64
+	hasPrev := false
+	for i, c := range r.Calls {
+		cp := CallProfile{
+			Index:               i + 1,
+			Timestamp:           c.Timestamp,
+			Model:               c.Model,
+			InputTokens:         c.Usage.InputTokens,
+			CacheReadTokens:     c.Usage.CacheReadInputTokens,
+			CacheCreationTokens: c.Usage.CacheCreationInputTokens,
+			OutputTokens:        c.Usage.OutputTokens,
+			ContextTokens:       c.ContextTokens(),
+			Tools:               c.Tools,
+			Subagent:            c.Subagent,
+		}
+		if !c.Subagent {
+			if hasPrev {
+				cp.Growth = cp.ContextTokens - prev
+			}
+			prev = cp.ContextTokens
+			hasPrev = true
+			p.PeakContext = max(p.PeakContext, cp.ContextTokens)
+		}
+		p.Calls = append(p.Calls, cp)
+	}
+
+	var growth []CallProfile
+	for _, cp := range p.Calls {
+		if cp.Growth > 0 {
+			growth = append(growth, cp)
+		}
+	}
+	sort.SliceStable(growth, func(i, j int) bool { return growth[i].Growth > growth[j].Growth })
+	if topN > 0 && len(growth) > topN {
+		growth = growth[:topN]
+	}
+	p.TopGrowth = growth
+	if p.TopGrowth == nil {
+		p.TopGrowth = []CallProfile{}
+	}
+	return p
+}
+
+// FindSessionFile はセッションIDに対応する JSONL ファイルを探す｡
+// id が既存ファイルのパスならそのまま返し、それ以外は <id>.jsonl、
+// 見つからなければ id で始まる一意なファイルを projectsDir 以下から探す｡
+func FindSessionFile(projectsDir, id string) (string, error) {
+	if info, err := os.Stat(id); err == nil && !info.IsDir() {
+		return id, nil
+	}
+
+	var exact string
+	var prefixed []string
+	err := filepath.WalkDir(projectsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".jsonl") {
+			return nil
+		}
+		name := strings.TrimSuffix(d.Name(), ".jsonl")
+		if name == id {
+			exact = path
+			return filepath.SkipAll
+		}
+		if strings.HasPrefix(name, id) {
+			prefixed = append(prefixed, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case exact != "":
+		return exact, nil
+	case len(prefixed) == 1:
+		return prefixed[0], nil
+	case len(prefixed) > 1:
+		return "", fmt.Errorf("セッションID %q に複数のファイルが一致します: %s", id, strings.Join(prefixed, ", "))
+	default:
+		return "", fmt.Errorf("セッション %q が見つかりません (%s)", id, projectsDir)
+	}
+}
+
+// FormatSessionProfile はプロファイルをテキスト表に整形する｡
+func FormatSessionProfile(p SessionProfile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Session: %s  Project: %s\n", p.SessionID, p.Project)
+	fmt.Fprintf(&b, "File:    %s\n", p.FilePath)
+	fmt.Fprintf(&b, "Calls: %d | Compactions: %d | Peak context: %d\n\n", len(p.Calls), len(p.Compactions), p.PeakContext)
+
+	fmt.Fprintf(&b, "%5s  %-8s  %-26s %9s %9s %9s %8s %9s %9s  %s\n",
+		"#", "time", "model", "input", "cache_r", "cache_w", "output", "context", "growth", "tools")
+	compactions := p.Compactions
+	for _, c := range p.Calls {
+		for len(compactions) > 0 && compactions[0].CallIndex < c.Index {
+			b.WriteString(formatCompaction(compactions[0]))
+			compactions = compactions[1:]
+		}
+		ts := "-"
+		if !c.Timestamp.IsZero() {
+			ts = c.Timestamp.Local().Format("15:04:05")
+		}
+		model := c.Model
+		if c.Subagent {
+			model = "↳ " + model
+		}
+		fmt.Fprintf(&b, "%5d  %-8s  %-26s %9d %9d %9d %8d %9d %+9d  %s\n",
+			c.Index, ts, model, c.InputTokens, c.CacheReadTokens, c.CacheCreationTokens,
+			c.OutputTokens, c.ContextTokens, c.Growth, strings.Join(c.Tools, ","))
+	}
+	for _, ev := range compactions {
+		b.WriteString(formatCompaction(ev))
+	}
+
+	if len(p.TopGrowth) > 0 {
+		fmt.Fprintf(&b, "\n[TOP GROWTH]\n")
+		for _, c := range p.TopGrowth {
+			fmt.Fprintf(&b, "  #%-5d %+9d -> %9d  tools: %s\n", c.Index, c.Growth, c.ContextTokens, toolsOrDash(c.Tools))
+		}
+	}
+	return b.String()
+}
+
+func formatCompaction(ev CompactionEvent) string {
+	detail := ev.Trigger
+	if ev.PreTokens > 0 {
+		detail = fmt.Sprintf("%s, pre %d tokens", detail, ev.PreTokens)
+	}
+	return fmt.Sprintf("  ---- compaction (%s) ----\n", strings.TrimPrefix(detail, ", "))
+}
+
+func toolsOrDash(tools []string) string {
+	if len(tools) == 0 {
+		return "-"
+	}
+	return strings.Join(tools, ",")
+}
+
+// runSession は session サブコマンドを実行し、終了コードを返す｡
+func runSession(args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("session", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	projectsDir := fset.String("dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)")
+	topN := fset.Int("top", 5, "コンテキスト増分の大きいターンの表示数")
+	format := fset.String("format", "summary", "出力形式: summary または json")
+	fset.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze-tokens session [flags] <session-id | path/to/session.jsonl>\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if fset.NArg() != 1 {
+		fset.Usage()
+		return 2
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(stderr, "ホームディレクトリ取得失敗: %v\n", err)
+		return 1
+	}
+	path, err := FindSessionFile(pathutil.ResolveProjectsDir(*projectsDir, home), fset.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	result, err := ScanSessionFileWithResolver(path, resolver)
+	if err != nil {
+		fmt.Fprintf(stderr, "スキャン失敗: %v\n", err)
+		return 1
+	}
+	profile := BuildSessionProfile(result, *topN)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(profile); err != nil {
+			fmt.Fprintf(stderr, "JSON出力失敗: %v\n", err)
+			return 1
+		}
+	case "summary":
+		fmt.Fprint(stdout, FormatSessionProfile(profile))
+	default:
+		fmt.Fprintf(stderr, "不明な出力形式: %s (summary または json を指定)\n", *format)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const profileTestJSONL = `{"type":"user","cwd":"/Users/test/project","sessionId":"abc-123","userType":"external","timestamp":"2026-10-01T09:00:00Z","message":{"content":[]}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"abc-123","timestamp":"2026-10-01T09:00:05Z","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","name":"Read","input":{}}],"usage":{"input_tokens":10,"cache_creation_input_tokens":20000,"cache_read_input_tokens":0,"output_tokens":100}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"abc-123","timestamp":"2026-10-01T09:00:10Z","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","name":"Bash","input":{}},{"type":"tool_use","name":"Grep","input":{}}],"usage":{"input_tokens":10,"cache_creation_input_tokens":50000,"cache_read_input_tokens":20000,"output_tokens":100}}}
+{"type":"progress","cwd":"/Users/test/project","sessionId":"abc-123","timestamp":"2026-10-01T09:00:12Z","data":{"message":{"type":"assistant","message":{"model":"claude-haiku-4-5-20251001","content":[],"usage":{"input_tokens":5000,"output_tokens":50}}}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"abc-123","timestamp":"2026-10-01T09:00:20Z","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":10,"cache_creation_input_tokens":5000,"cache_read_input_tokens":70000,"output_tokens":100}}}
+{"type":"system","subtype":"compact_boundary","sessionId":"abc-123","timestamp":"2026-10-01T09:01:00Z","content":"Conversation compacted","compactMetadata":{"trigger":"auto","preTokens":75010}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"abc-123","timestamp":"2026-10-01T09:01:05Z","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":10,"cache_creation_input_tokens":15000,"cache_read_input_tokens":0,"output_tokens":100}}}
+`
+
+func TestBuildSessionProfile(t *testing.T) {
+	path := writeTestJSONL(t, t.TempDir(), "abc-123.jsonl", profileTestJSONL)
+	result, err := ScanSessionFile(path)
+	if err != nil {
+		t.Fatalf("ScanSessionFile失敗: %v", err)
+	}
+	p := BuildSessionProfile(result, 2)
+
+	t.Run("コールごとのtokenとツール", func(t *testing.T) {
+		if len(p.Calls) != 5 {
+			t.Fatalf("len(Calls) = %d, want 5", len(p.Calls))
+		}
+		c := p.Calls[1]
+		if c.ContextTokens != 70010 || c.Growth != 50000 {
+			t.Errorf("Calls[1] context/growth = (%d, %d), want (70010, 50000)", c.ContextTokens, c.Growth)
+		}
+		if strings.Join(c.Tools, ",") != "Bash,Grep" {
+			t.Errorf("Calls[1].Tools = %v", c.Tools)
+		}
+	})
+
+	t.Run("subagentのコールは増分計算から除外", func(t *testing.T) {
+		if !p.Calls[2].Subagent || p.Calls[2].Growth != 0 {
+			t.Errorf("Calls[2] = %+v", p.Calls[2])
+		}
+		if p.Calls[3].Growth != 5000 {
+			t.Errorf("Calls[3].Growth = %d, want 5000 (subagentを挟んでもメインチェーンで比較)", p.Calls[3].Growth)
+		}
+	})
+
+	t.Run("compactionイベント", func(t *testing.T) {
+		if len(p.Compactions) != 1 {
+			t.Fatalf("Compactions = %+v", p.Compactions)
+		}
+		ev := p.Compactions[0]
+		if ev.CallIndex != 4 || ev.Trigger != "auto" || ev.PreTokens != 75010 {
+			t.Errorf("Compactions[0] = %+v", ev)
+		}
+		if p.Calls[4].Growth >= 0 {
+			t.Errorf("compaction後のGrowthは負であるべき: %d", p.Calls[4].Growth)
+		}
+	})
+
+	t.Run("増分の大きいターン", func(t *testing.T) {
+		if len(p.TopGrowth) != 2 || p.TopGrowth[0].Index != 2 || p.TopGrowth[1].Index != 4 {
+			t.Errorf("TopGrowth = %+v", p.TopGrowth)
+		}
+		// --top に0以下を指定しても範囲外にならず全件を返す
+		if all := BuildSessionProfile(result, -1); len(all.TopGrowth) != 2 {
+			t.Errorf("topN=-1: TopGrowth = %+v", all.TopGrowth)
+		}
+		if p.PeakContext != 75010 {
+			t.Errorf("PeakContext = %d, want 75010", p.PeakContext)
+		}
+	})
+
+	t.Run("テキスト出力", func(t *testing.T) {
+		out := FormatSessionProfile(p)
+		for _, want := range []string{
+			"Session: abc-123  Project: project",
+			"Calls: 5 | Compactions: 1 | Peak context: 75010",
+			"---- compaction (auto, pre 75010 tokens) ----",
+			"[TOP GROWTH]",
+			"Bash,Grep",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("出力に %q が含まれていない:\n%s", want, out)
+			}
+		}
+		if strings.Index(out, "compaction") > strings.LastIndex(out, "    5  ") {
+			t.Errorf("compactionは5番目のコールの前に表示されるべき:\n%s", out)
+		}
+	})
+}
+
+func TestFindSessionFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "proj-a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	exact := writeTestJSONL(t, filepath.Join(dir, "proj-a"), "abc-123.jsonl", "")
+	writeTestJSONL(t, dir, "abc-456.jsonl", "")
+	writeTestJSONL(t, dir, "def-789.jsonl", "")
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{"完全一致", "abc-123", exact, false},
+		{"一意な前方一致", "def", filepath.Join(dir, "def-789.jsonl"), false},
+		{"ファイルパス指定", exact, exact, false},
+		{"前方一致が複数", "abc", "", true},
+		{"見つからない", "zzz", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindSessionFile(dir, tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunSession(t *testing.T) {
+	dir := t.TempDir()
+	writeTestJSONL(t, dir, "abc-123.jsonl", profileTestJSONL)
+
+	t.Run("json出力", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runSession([]string{"--dir", dir, "--format", "json", "abc-123"}, &stdout, &stderr); code != 0 {
+			t.Fatalf("exit = %d, stderr = %s", code, stderr.String())
+		}
+		var p SessionProfile
+		if err := json.Unmarshal(stdout.Bytes(), &p); err != nil {
+			t.Fatalf("JSONパース失敗: %v", err)
+		}
+		if p.SessionID != "abc-123" || len(p.Calls) != 5 {
+			t.Errorf("profile = %+v", p)
+		}
+	})
+
+	t.Run("引数なしは使い方エラー", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := runSession([]string{"--dir", dir}, &stdout, &stderr); code != 2 {
+			t.Errorf("exit = %d, want 2", code)
+		}
+	})
+}
@@ -0,0 +1,631 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// カスタム警告ルールの条件式｡
+//
+//	expr    = or
+//	or      = and { "||" and }
+//	and     = not { "&&" not }
+//	not     = "!" not | cmp
+//	cmp     = sum [ ( ">" | ">=" | "<" | "<=" | "==" | "!=" ) sum ]
+//	sum     = term { ( "+" | "-" ) term }
+//	term    = unary { ( "*" | "/" ) unary }
+//	unary   = "-" unary | primary
+//	primary = number | string | scope "." field | "(" expr ")"
+//
+// 変数は summary / project / session / model / tool のスコープを持ち、
+// summary 以外のスコープを使うルールはその要素ごとに評価される｡
+// 型はコンパイル時に検査するため、評価時にエラーは起きない｡
+
+// ルールのスコープ｡
+const (
+	ScopeSummary = "summary"
+	ScopeProject = "project"
+	ScopeSession = "session"
+	ScopeModel   = "model"
+	ScopeTool    = "tool"
+)
+
+type valueKind int
+
+const (
+	kindNumber valueKind = iota
+	kindString
+	kindBool
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindNumber:
+		return "数値"
+	case kindString:
+		return "文字列"
+	default:
+		return "真偽値"
+	}
+}
+
+type value struct {
+	kind valueKind
+	num  float64
+	str  string
+	b    bool
+}
+
+func numValue(f float64) value { return value{kind: kindNumber, num: f} }
+func intValue(n int64) value   { return numValue(float64(n)) }
+func strValue(s string) value  { return value{kind: kindString, str: s} }
+func boolValue(b bool) value   { return value{kind: kindBool, b: b} }
+func (v value) truthy() bool   { return v.kind == kindBool && v.b }
+
+// env はスコープ付き変数名("project.avg_input" など)から値への対応｡
+type env map[string]value
+
+func summaryEnv(s ReportSummary) env {
+	return env{
+		"summary.sessions":           numValue(float64(s.TotalSessions)),
+		"summary.api_calls":          numValue(float64(s.TotalAPICalls)),
+		"summary.input_tokens":       intValue(s.TotalInputTokens),
+		"summary.output_tokens":      intValue(s.TotalOutputTokens),
+		"summary.avg_input":          intValue(s.AverageInputPerCall),
+		"summary.avg_context":        intValue(s.AverageContextPerCall),
+		"summary.cache_hit_ratio":    numValue(s.CacheHitRatio),
+		"summary.cost_usd":           numValue(s.TotalCostUSD),
+		"summary.tool_result_tokens": intValue(s.EstimatedToolResultTokens),
+	}
+}
+
+func projectEnv(p ProjectSummary) env {
+	return env{
+		"project.name":            strValue(p.Project),
+		"project.sessions":        numValue(float64(p.SessionCount)),
+		"project.api_calls":       numValue(float64(p.TotalAPICalls)),
+		"project.input_tokens":    intValue(p.TotalInputTokens),
+		"project.output_tokens":   intValue(p.TotalOutputTokens),
+		"project.avg_input":       intValue(p.AverageInputPerCall),
+		"project.avg_context":     intValue(p.AverageContextPerCall),
+		"project.cache_hit_ratio": numValue(p.CacheHitRatio),
+		"project.cost_usd":        numValue(p.CostUSD),
+	}
+}
+
+func sessionEnv(r SessionResult) env {
+	var callRatio float64
+	if r.UserMessageCount > 0 {
+		callRatio = float64(r.APICallCount) / float64(r.UserMessageCount)
+	}
+	return env{
+		"session.id":              strValue(r.SessionID),
+		"session.project":         strValue(r.Project),
+		"session.model":           strValue(r.Model),
+		"session.api_calls":       numValue(float64(r.APICallCount)),
+		"session.user_messages":   numValue(float64(r.UserMessageCount)),
+		"session.call_ratio":      numValue(callRatio),
+		"session.input_tokens":    intValue(r.TotalInputTokens),
+		"session.output_tokens":   intValue(r.TotalOutputTokens),
+		"session.context_tokens":  intValue(r.ContextTokens()),
+		"session.avg_input":       intValue(r.AverageInputTokensPerCall()),
+		"session.avg_context":     intValue(averagePerCall(r.ContextTokens(), r.APICallCount)),
+		"session.cache_hit_ratio": numValue(cacheHitRatio(r.TotalInputTokens, r.TotalCacheReadTokens, r.TotalCacheCreationTokens)),
+		"session.compactions":     numValue(float64(len(r.Compactions))),
+		"session.cost_usd":        numValue(r.CostUSD),
+	}
+}
+
+func modelEnv(m ModelSummary) env {
+	return env{
+		"model.name":            strValue(m.Model),
+		"model.calls":           numValue(float64(m.CallCount)),
+		"model.input_tokens":    intValue(m.InputTokens),
+		"model.output_tokens":   intValue(m.OutputTokens),
+		"model.avg_context":     intValue(m.AverageContextPerCall),
+		"model.cache_hit_ratio": numValue(m.CacheHitRatio),
+		"model.cost_usd":        numValue(m.CostUSD),
+	}
+}
+
+func toolEnv(t ToolCostSummary) env {
+	return env{
+		"tool.name":             strValue(t.Tool),
+		"tool.mcp_server":       strValue(t.MCPServer),
+		"tool.results":          numValue(float64(t.ResultCount)),
+		"tool.sessions":         numValue(float64(t.SessionCount)),
+		"tool.estimated_tokens": intValue(t.EstimatedTokens),
+		"tool.avg_tokens":       intValue(t.AverageTokens),
+		"tool.max_tokens":       intValue(t.MaxTokens),
+	}
+}
+
+// variableKinds は使用可能な変数とその型｡ゼロ値の env から作る｡
+var variableKinds = func() map[string]valueKind {
+	kinds := make(map[string]valueKind)
+	for _, e := range []env{
+		summaryEnv(ReportSummary{}), projectEnv(ProjectSummary{}), sessionEnv(SessionResult{}),
+		modelEnv(ModelSummary{}), toolEnv(ToolCostSummary{}),
+	} {
+		for name, v := range e {
+			kinds[name] = v.kind
+		}
+	}
+	return kinds
+}()
+
+// node は条件式の構文木｡
+type node interface {
+	eval(e env) value
+	kind() valueKind
+}
+
+type literal struct{ v value }
+
+func (n literal) eval(env) value  { return n.v }
+func (n literal) kind() valueKind { return n.v.kind }
+
+type variable struct {
+	name string
+	k    valueKind
+}
+
+func (n variable) eval(e env) value { return e[n.name] }
+func (n variable) kind() valueKind  { return n.k }
+
+type unaryOp struct {
+	op string
+	x  node
+}
+
+func (n unaryOp) eval(e env) value {
+	v := n.x.eval(e)
+	if n.op == "!" {
+		return boolValue(!v.b)
+	}
+	return numValue(-v.num)
+}
+
+func (n unaryOp) kind() valueKind { return n.x.kind() }
+
+type binaryOp struct {
+	op   string
+	x, y node
+}
+
+func (n binaryOp) kind() valueKind {
+	switch n.op {
+	case "+", "-", "*", "/":
+		return kindNumber
+	default:
+		return kindBool
+	}
+}
+
+func (n binaryOp) eval(e env) value {
+	// && と || は短絡評価する
+	switch n.op {
+	case "&&":
+		return boolValue(n.x.eval(e).truthy() && n.y.eval(e).truthy())
+	case "||":
+		return boolValue(n.x.eval(e).truthy() || n.y.eval(e).truthy())
+	}
+	x, y := n.x.eval(e), n.y.eval(e)
+	switch n.op {
+	case "+":
+		return numValue(x.num + y.num)
+	case "-":
+		return numValue(x.num - y.num)
+	case "*":
+		return numValue(x.num * y.num)
+	case "/":
+		if y.num == 0 {
+			return numValue(0)
+		}
+		return numValue(x.num / y.num)
+	case "==":
+		return boolValue(x == y)
+	case "!=":
+		return boolValue(x != y)
+	case ">":
+		return boolValue(x.num > y.num)
+	case ">=":
+		return boolValue(x.num >= y.num)
+	case "<":
+		return boolValue(x.num < y.num)
+	default: // "<="
+		return boolValue(x.num <= y.num)
+	}
+}
+
+// token は字句解析の結果｡kind は "num" / "str" / "ident" / "op" / "eof"｡
+type token struct {
+	kind string
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	// scan は i から pred を満たす間進めた位置を返す
+	scan := func(i int, pred func(rune) bool) int {
+		for i < len(runes) && pred(runes[i]) {
+			i++
+		}
+		return i
+	}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			end := scan(i, func(c rune) bool { return unicode.IsDigit(c) || c == '.' || c == '_' })
+			tokens = append(tokens, token{"num", string(runes[i:end]), i})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := scan(i, func(c rune) bool { return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' })
+			tokens = append(tokens, token{"ident", string(runes[i:end]), i})
+			i = end
+		case r == '"':
+			end := scan(i+1, func(c rune) bool { return c != '"' })
+			if end >= len(runes) {
+				return nil, fmt.Errorf("%d文字目: 文字列が閉じていません", i+1)
+			}
+			tokens = append(tokens, token{"str", string(runes[i+1 : end]), i})
+			i = end + 1
+		default:
+			op := tokenOperator(runes[i:])
+			if op == "" {
+				return nil, fmt.Errorf("%d文字目: 不正な文字 %q", i+1, r)
+			}
+			tokens = append(tokens, token{"op", op, i})
+			i += len([]rune(op))
+		}
+	}
+	return append(tokens, token{"eof", "", len(runes)}), nil
+}
+
+// tokenOperator は rest の先頭にある演算子を返す｡なければ空文字｡
+func tokenOperator(rest []rune) string {
+	if len(rest) >= 2 {
+		switch two := string(rest[:2]); two {
+		case "&&", "||", ">=", "<=", "==", "!=":
+			return two
+		}
+	}
+	if strings.ContainsRune("+-*/<>!()", rest[0]) {
+		return string(rest[0])
+	}
+	return ""
+}
+
+// parser は再帰下降で条件式をパースし、型検査を行う｡
+type parser struct {
+	tokens []token
+	pos    int
+	scopes map[string]bool
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%d文字目: %s", p.peek().pos+1, fmt.Sprintf(format, args...))
+}
+
+func expectKind(n node, want valueKind, op string) error {
+	if n.kind() != want {
+		return fmt.Errorf("%s の被演算子は%sである必要があります (%sが指定されました)", op, want, n.kind())
+	}
+	return nil
+}
+
+func (p *parser) parseLogical(ops []string, next func() (node, error)) (node, error) {
+	x, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(x, kindBool, op); err != nil {
+			return nil, err
+		}
+		if err := expectKind(y, kindBool, op); err != nil {
+			return nil, err
+		}
+		x = binaryOp{op, x, y}
+	}
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseLogical([]string{"||"}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseLogical([]string{"&&"}, p.parseNot)
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.accept("!"); ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(x, kindBool, "!"); err != nil {
+			return nil, err
+		}
+		return unaryOp{"!", x}, nil
+	}
+	return p.parseCmp()
+}
+
+func (p *parser) parseCmp() (node, error) {
+	x, err := p.parseArith([]string{"+", "-"}, p.parseTerm)
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept(">", ">=", "<", "<=", "==", "!=")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.parseArith([]string{"+", "-"}, p.parseTerm)
+	if err != nil {
+		return nil, err
+	}
+	if op == "==" || op == "!=" {
+		if x.kind() != y.kind() {
+			return nil, fmt.Errorf("%s の両辺の型が異なります (%s と %s)", op, x.kind(), y.kind())
+		}
+	} else {
+		if err := expectKind(x, kindNumber, op); err != nil {
+			return nil, err
+		}
+		if err := expectKind(y, kindNumber, op); err != nil {
+			return nil, err
+		}
+	}
+	return binaryOp{op, x, y}, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	return p.parseArith([]string{"*", "/"}, p.parseUnary)
+}
+
+func (p *parser) parseArith(ops []string, next func() (node, error)) (node, error) {
+	x, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(x, kindNumber, op); err != nil {
+			return nil, err
+		}
+		if err := expectKind(y, kindNumber, op); err != nil {
+			return nil, err
+		}
+		x = binaryOp{op, x, y}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := expectKind(x, kindNumber, "-"); err != nil {
+			return nil, err
+		}
+		return unaryOp{"-", x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case "num":
+		p.pos++
+		f, err := strconv.ParseFloat(strings.ReplaceAll(t.text, "_", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%d文字目: 不正な数値 %q", t.pos+1, t.text)
+		}
+		return literal{numValue(f)}, nil
+	case "str":
+		p.pos++
+		return literal{strValue(t.text)}, nil
+	case "ident":
+		p.pos++
+		switch t.text {
+		case "true", "false":
+			return literal{boolValue(t.text == "true")}, nil
+		}
+		k, ok := variableKinds[t.text]
+		if !ok {
+			return nil, fmt.Errorf("%d文字目: 不明な変数 %q (使用可能: %s)", t.pos+1, t.text, variablesInScope(t.text))
+		}
+		scope, _, _ := strings.Cut(t.text, ".")
+		p.scopes[scope] = true
+		return variable{t.text, k}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf(") がありません")
+		}
+		return x, nil
+	}
+	if t.kind == "eof" {
+		return nil, p.errorf("式が途中で終わっています")
+	}
+	return nil, p.errorf("予期しないトークン %q", t.text)
+}
+
+// variablesInScope はエラーメッセージ用に name と同じスコープの変数一覧を返す｡
+// スコープが不明なら全変数を返す｡
+func variablesInScope(name string) string {
+	scope, _, _ := strings.Cut(name, ".")
+	var names, all []string
+	for v := range variableKinds {
+		all = append(all, v)
+		if strings.HasPrefix(v, scope+".") {
+			names = append(names, v)
+		}
+	}
+	if len(names) == 0 {
+		names = all
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseExpr は式をパースし、構文木と参照しているスコープを返す｡
+func parseExpr(src string) (node, map[string]bool, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &parser{tokens: tokens, scopes: make(map[string]bool)}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t := p.peek(); t.kind != "eof" {
+		return nil, nil, p.errorf("予期しないトークン %q", t.text)
+	}
+	return n, p.scopes, nil
+}
+
+// Rule はコンパイル済みのカスタム警告ルール｡
+type Rule struct {
+	RuleConfig
+	scope string
+	when  node
+	value node
+}
+
+// CompileRule はルールの条件式と値式をパース・型検査する｡
+// summary 以外で参照できるスコープは1つだけ｡
+func CompileRule(rc RuleConfig) (*Rule, error) {
+	if rc.Type == "" {
+		return nil, fmt.Errorf("type が指定されていません")
+	}
+	when, scopes, err := parseExpr(rc.When)
+	if err != nil {
+		return nil, fmt.Errorf("when: %w", err)
+	}
+	if when.kind() != kindBool {
+		return nil, fmt.Errorf("when: 条件式は真偽値である必要があります (%sが指定されました)", when.kind())
+	}
+	r := &Rule{RuleConfig: rc, when: when}
+	if rc.Value != "" {
+		v, valueScopes, err := parseExpr(rc.Value)
+		if err != nil {
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		if v.kind() != kindNumber {
+			return nil, fmt.Errorf("value: 数値の式である必要があります (%sが指定されました)", v.kind())
+		}
+		for s := range valueScopes {
+			scopes[s] = true
+		}
+		r.value = v
+	}
+
+	r.scope = ScopeSummary
+	for s := range scopes {
+		if s == ScopeSummary {
+			continue
+		}
+		if r.scope != ScopeSummary && r.scope != s {
+			return nil, fmt.Errorf("複数のスコープ (%s と %s) を1つのルールで参照できません", r.scope, s)
+		}
+		r.scope = s
+	}
+	return r, nil
+}
+
+// Evaluate はルールをレポートに適用し、条件を満たした要素ごとに警告を返す｡
+func (r *Rule) Evaluate(report Report, results []SessionResult) []Warning {
+	base := summaryEnv(report.Summary)
+	var warnings []Warning
+	check := func(scoped env, w Warning) {
+		e := make(env, len(base)+len(scoped))
+		for k, v := range base {
+			e[k] = v
+		}
+		for k, v := range scoped {
+			e[k] = v
+		}
+		if !r.when.eval(e).truthy() {
+			return
+		}
+		w.Type = r.Type
+		w.Message = r.Message
+		w.Recommendation = r.Recommendation
+		w.Threshold = r.Threshold
+		if r.value != nil {
+			w.Value = int64(math.Round(r.value.eval(e).num))
+		}
+		warnings = append(warnings, w)
+	}
+
+	switch r.scope {
+	case ScopeProject:
+		for _, p := range report.ProjectSummary {
+			check(projectEnv(p), Warning{Project: p.Project})
+		}
+	case ScopeSession:
+		for _, s := range results {
+			check(sessionEnv(s), Warning{Project: s.Project, SessionID: s.SessionID})
+		}
+	case ScopeModel:
+		for _, m := range report.ModelSummary {
+			check(modelEnv(m), Warning{Model: m.Model})
+		}
+	case ScopeTool:
+		for _, t := range report.ToolCost {
+			check(toolEnv(t), Warning{Tool: t.Tool})
+		}
+	default:
+		check(nil, Warning{})
+	}
+	return warnings
+}
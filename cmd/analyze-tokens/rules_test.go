@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	e := summaryEnv(ReportSummary{TotalSessions: 3})
+	for k, v := range projectEnv(ProjectSummary{Project: "alpha", SessionCount: 6, AverageInputPerCall: 90000, CacheHitRatio: 0.4}) {
+		e[k] = v
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"project.avg_input > 80000 && project.sessions > 5", true},
+		{"project.avg_input > 80_000 && project.sessions > 10", false},
+		{"project.sessions >= 6 || project.avg_input < 0", true},
+		{`project.name == "alpha"`, true},
+		{`!(project.name == "alpha")`, false},
+		{"project.cache_hit_ratio * 100 < 50", true},
+		{"project.sessions / summary.sessions == 2", true},
+		{"project.sessions / 0 == 0", true},
+		{"-project.sessions + 10 == 4", true},
+		{"1 + 2 * 3 == 7", true},
+		{"true && !false", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, _, err := parseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parseExpr失敗: %v", err)
+			}
+			if got := n.eval(e).truthy(); got != tt.want {
+				t.Errorf("eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"project.avg_input >", "途中で終わって"},
+		{"project.unknown > 1", "不明な変数"},
+		{`project.name > 1`, "数値である必要"},
+		{`project.name == 1`, "型が異なります"},
+		{"project.sessions && true", "真偽値である必要"},
+		{`project.name == "alpha`, "閉じていません"},
+		{"project.sessions > 1 $", "不正な文字"},
+		{"(project.sessions > 1", ") がありません"},
+		{"project.sessions > 1 2", "予期しないトークン"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, _, err := parseExpr(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q を含むエラー", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileRule(t *testing.T) {
+	t.Run("スコープを推定", func(t *testing.T) {
+		r, err := CompileRule(RuleConfig{Type: "t", When: "session.call_ratio > 30 && summary.sessions > 1"})
+		if err != nil {
+			t.Fatalf("CompileRule失敗: %v", err)
+		}
+		if r.scope != ScopeSession {
+			t.Errorf("scope = %q, want session", r.scope)
+		}
+	})
+
+	tests := []struct {
+		name string
+		rc   RuleConfig
+	}{
+		{"type未指定", RuleConfig{When: "summary.sessions > 1"}},
+		{"条件式が真偽値でない", RuleConfig{Type: "t", When: "summary.sessions + 1"}},
+		{"複数スコープ", RuleConfig{Type: "t", When: "project.sessions > 1 && tool.results > 1"}},
+		{"valueが数値でない", RuleConfig{Type: "t", When: "project.sessions > 1", Value: "project.name"}},
+		{"valueとwhenでスコープが異なる", RuleConfig{Type: "t", When: "project.sessions > 1", Value: "model.calls"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompileRule(tt.rc); err == nil {
+				t.Error("エラーが返されるべき")
+			}
+		})
+	}
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	results := []SessionResult{
+		{SessionID: "s1", Project: "alpha", APICallCount: 120, UserMessageCount: 2},
+		{SessionID: "s2", Project: "beta", APICallCount: 10, UserMessageCount: 2},
+	}
+	report := GenerateReport(results, 10)
+
+	t.Run("セッションスコープは要素ごとに評価", func(t *testing.T) {
+		r, err := CompileRule(RuleConfig{Type: "chatty", When: "session.call_ratio > 30", Value: "session.call_ratio", Threshold: 30})
+		if err != nil {
+			t.Fatalf("CompileRule失敗: %v", err)
+		}
+		ws := r.Evaluate(report, results)
+		if len(ws) != 1 || ws[0].SessionID != "s1" || ws[0].Project != "alpha" || ws[0].Value != 60 || ws[0].Threshold != 30 {
+			t.Errorf("warnings = %+v", ws)
+		}
+	})
+
+	t.Run("ツールスコープは表示件数で切り詰める前の全ツールで評価", func(t *testing.T) {
+		results := []SessionResult{{SessionID: "s1", Project: "alpha", APICallCount: 1, ToolResults: map[string]ToolResultStats{
+			"Read":                   {ResultCount: 10, ResultBytes: 400_000},
+			"mcp__github__get_issue": {ResultCount: 2, ResultBytes: 8_000},
+		}}}
+		cfg := DefaultConfig()
+		cfg.Rules = []RuleConfig{{Type: "github_mcp", When: `tool.mcp_server == "github"`}}
+		if err := cfg.compileRules(); err != nil {
+			t.Fatalf("compileRules失敗: %v", err)
+		}
+		report := GenerateReportWithConfig(results, 1, cfg)
+		if len(report.ToolCost) != 1 || report.ToolCost[0].Tool != "Read" {
+			t.Errorf("ToolCost = %+v", report.ToolCost)
+		}
+		var found bool
+		for _, w := range report.Warnings {
+			found = found || (w.Type == "github_mcp" && w.Tool == "mcp__github__get_issue")
+		}
+		if !found {
+			t.Errorf("切り詰められたツールの警告がない: %+v", report.Warnings)
+		}
+	})
+
+	t.Run("summaryのみのルールは1回だけ評価", func(t *testing.T) {
+		r, err := CompileRule(RuleConfig{Type: "many", When: "summary.sessions >= 2"})
+		if err != nil {
+			t.Fatalf("CompileRule失敗: %v", err)
+		}
+		if ws := r.Evaluate(report, results); len(ws) != 1 || ws[0].Project != "" {
+			t.Errorf("warnings = %+v", ws)
+		}
+	})
+}
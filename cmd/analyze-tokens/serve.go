@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
+)
+
+// openMetricsContentType は OpenMetrics テキスト形式の Content-Type｡
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// metricPrefix はエクスポートするメトリクス名の接頭辞｡
+const metricPrefix = "analyze_tokens_"
+
+// exporter は定期的にセッションを集計し、最新の結果を OpenMetrics 形式で返す｡
+// 集計は1つの goroutine から行い、cache と resolver はスキャンをまたいで使い回す｡
+type exporter struct {
+	projectsDir string
+	days        int
+	cfg         *Config
+	pricing     *PricingTable
+	cache       *SessionCache
+	// saveCache はスキャン後にキャッシュをファイルへ書き出すか｡--no-cache ならメモリ上でのみ使う｡
+	saveCache bool
+	resolver  *project.Resolver
+	stderr    io.Writer
+
+	mu         sync.RWMutex
+	body       []byte
+	scans      int
+	scanErrors int
+}
+
+// scan はセッションを集計してメトリクスを更新する｡失敗した場合は前回の結果を残す｡
+func (e *exporter) scan(now time.Time) {
+	start := time.Now()
+	results, err := ScanProjectsDirWithCache(e.projectsDir, e.days, "", e.cache, e.resolver)
+	// 保存しないキャッシュも、期間外になったり削除されたりしたファイルのエントリを毎回捨てる
+	if err == nil && e.saveCache {
+		if saveErr := e.cache.Save(); saveErr != nil {
+			fmt.Fprintf(e.stderr, "キャッシュの保存に失敗: %v\n", saveErr)
+		}
+	} else if err == nil {
+		e.cache.Prune()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scans++
+	if err != nil {
+		e.scanErrors++
+		fmt.Fprintf(e.stderr, "スキャン失敗: %v\n", err)
+		return
+	}
+	if e.pricing != nil {
+		ApplyPricing(results, e.pricing)
+	}
+	report := GenerateReportWithConfig(results, 0, e.cfg)
+	var b strings.Builder
+	writeOpenMetrics(&b, results, report, e.days)
+	writeScanMetrics(&b, e.scans, e.scanErrors, now, time.Since(start))
+	b.WriteString("# EOF\n")
+	e.body = []byte(b.String())
+}
+
+// ServeHTTP は最新のメトリクスを返す｡最初のスキャンが終わるまでは 503 を返す｡
+func (e *exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.mu.RLock()
+	body := e.body
+	e.mu.RUnlock()
+	if body == nil {
+		http.Error(w, "初回のスキャン中です", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", openMetricsContentType)
+	_, _ = w.Write(body)
+}
+
+// run は interval ごとに scan し、ctx が終了するまで繰り返す｡
+func (e *exporter) run(ctx context.Context, interval time.Duration) {
+	e.scan(time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.scan(now)
+		}
+	}
+}
+
+// metricFamily は同じ名前のメトリクスのサンプルの集まり｡
+type metricFamily struct {
+	name    string
+	typ     string
+	help    string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels [][2]string
+	value  float64
+}
+
+func (f *metricFamily) add(value float64, labels ...string) {
+	s := metricSample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		s.labels = append(s.labels, [2]string{labels[i], labels[i+1]})
+	}
+	f.samples = append(f.samples, s)
+}
+
+// write は OpenMetrics テキスト形式で書き出す｡counter のサンプル名には _total を付ける｡
+func (f *metricFamily) write(b *strings.Builder) {
+	name := metricPrefix + f.name
+	fmt.Fprintf(b, "# TYPE %s %s\n# HELP %s %s\n", name, f.typ, name, f.help)
+	sample := name
+	if f.typ == "counter" {
+		sample += "_total"
+	}
+	sort.SliceStable(f.samples, func(i, j int) bool {
+		return labelKey(f.samples[i].labels) < labelKey(f.samples[j].labels)
+	})
+	for _, s := range f.samples {
+		b.WriteString(sample)
+		if len(s.labels) > 0 {
+			b.WriteString("{")
+			for i, l := range s.labels {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				fmt.Fprintf(b, "%s=\"%s\"", l[0], escapeLabelValue(l[1]))
+			}
+			b.WriteString("}")
+		}
+		b.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+	}
+}
+
+func labelKey(labels [][2]string) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l[1] + "\x00")
+	}
+	return b.String()
+}
+
+// escapeLabelValue はラベル値のバックスラッシュ・ダブルクォート・改行をエスケープする｡
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// projectModelKey はプロジェクトとモデルの組｡
+type projectModelKey struct {
+	project string
+	model   string
+}
+
+// writeOpenMetrics は期間内の集計をプロジェクト・モデル別のメトリクスとして書き出す｡
+// 値は直近 days 日のファイルの合計で、期間がずれると減ることもあるため gauge とする｡
+func writeOpenMetrics(b *strings.Builder, results []SessionResult, report Report, days int) {
+	usage := make(map[projectModelKey]ModelTokens)
+	sessions := make(map[string]int)
+	for _, r := range results {
+		if !r.IsSidechain {
+			sessions[r.Project]++
+		}
+		for model, mt := range r.ModelUsage {
+			key := projectModelKey{r.Project, model}
+			sum := usage[key]
+			sum.InputTokens += mt.InputTokens
+			sum.OutputTokens += mt.OutputTokens
+			sum.CacheReadTokens += mt.CacheReadTokens
+			sum.CacheCreationTokens += mt.CacheCreationTokens
+			sum.CallCount += mt.CallCount
+			sum.CostUSD += mt.CostUSD
+			usage[key] = sum
+		}
+	}
+
+	tokens := &metricFamily{name: "tokens", typ: "gauge", help: "Tokens used in the window by project, model and token type."}
+	calls := &metricFamily{name: "api_calls", typ: "gauge", help: "API calls in the window by project and model."}
+	cost := &metricFamily{name: "cost_usd", typ: "gauge", help: "Estimated cost in USD in the window by project and model."}
+	for key, mt := range usage {
+		for _, t := range []struct {
+			typ   string
+			value int64
+		}{
+			{"input", mt.InputTokens},
+			{"output", mt.OutputTokens},
+			{"cache_read", mt.CacheReadTokens},
+			{"cache_creation", mt.CacheCreationTokens},
+		} {
+			tokens.add(float64(t.value), "project", key.project, "model", key.model, "type", t.typ)
+		}
+		calls.add(float64(mt.CallCount), "project", key.project, "model", key.model)
+		if mt.CostUSD > 0 {
+			cost.add(mt.CostUSD, "project", key.project, "model", key.model)
+		}
+	}
+
+	sessionFamily := &metricFamily{name: "sessions", typ: "gauge", help: "Sessions in the window by project, excluding subagent sidechains."}
+	for proj, n := range sessions {
+		sessionFamily.add(float64(n), "project", proj)
+	}
+	warnings := &metricFamily{name: "warnings", typ: "gauge", help: "Active warnings by type and project."}
+	warningCounts := make(map[[2]string]int)
+	for _, w := range report.Warnings {
+		warningCounts[[2]string{w.Type, w.Project}]++
+	}
+	for key, n := range warningCounts {
+		warnings.add(float64(n), "type", key[0], "project", key[1])
+	}
+	window := &metricFamily{name: "window_days", typ: "gauge", help: "Number of days covered by the scan."}
+	window.add(float64(days))
+
+	for _, f := range []*metricFamily{tokens, calls, cost, sessionFamily, warnings, window} {
+		f.write(b)
+	}
+}
+
+// writeScanMetrics はエクスポーター自身のスキャン回数と所要時間を書き出す｡
+func writeScanMetrics(b *strings.Builder, scans, scanErrors int, now time.Time, elapsed time.Duration) {
+	total := &metricFamily{name: "scans", typ: "counter", help: "Number of scans of the projects directory."}
+	total.add(float64(scans))
+	errs := &metricFamily{name: "scan_errors", typ: "counter", help: "Number of failed scans."}
+	errs.add(float64(scanErrors))
+	last := &metricFamily{name: "last_scan_timestamp_seconds", typ: "gauge", help: "Unix time of the last successful scan."}
+	last.add(float64(now.Unix()))
+	duration := &metricFamily{name: "scan_duration_seconds", typ: "gauge", help: "Duration of the last successful scan."}
+	duration.add(elapsed.Seconds())
+	for _, f := range []*metricFamily{total, errs, last, duration} {
+		f.write(b)
+	}
+}
+
+// newExporter は serve サブコマンドのフラグからエクスポーターを作る｡
+func newExporter(home, projectsDir string, days int, configPath, pricingPath, cachePath string, noCache bool, stderr io.Writer) (*exporter, error) {
+	cfg := DefaultConfig()
+	if cPath := ResolveConfigPath(configPath, home); cPath != "" {
+		loaded, err := LoadConfig(cPath)
+		if err != nil {
+			return nil, fmt.Errorf("設定ファイルの読み込み失敗: %w", err)
+		}
+		cfg = loaded
+	}
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		return nil, err
+	}
+	e := &exporter{
+		projectsDir: pathutil.ResolveProjectsDir(projectsDir, home),
+		days:        days,
+		cfg:         cfg,
+		resolver:    resolver,
+		stderr:      stderr,
+	}
+	if pPath := ResolvePricingPath(pricingPath, home); pPath != "" {
+		if e.pricing, err = LoadPricing(pPath); err != nil {
+			return nil, fmt.Errorf("料金表の読み込み失敗: %w", err)
+		}
+	}
+	// --no-cache でも前回のスキャン結果はメモリ上で使い回し、追記分だけを解析する
+	cachePath = ResolveCachePath(cachePath)
+	e.saveCache = !noCache && cachePath != ""
+	if e.saveCache {
+		e.cache = LoadSessionCache(cachePath)
+	} else {
+		e.cache = LoadSessionCache("")
+	}
+	return e, nil
+}
+
+// runServe は serve サブコマンドを実行し、終了コードを返す｡
+// SIGINT / SIGTERM を受けるまで /metrics で OpenMetrics 形式のメトリクスを返す｡
+func runServe(args []string, stderr io.Writer) int {
+	fset := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	listen := fset.String("listen", ":9464", "待ち受けるアドレス")
+	interval := fset.Duration("interval", time.Minute, "スキャンの間隔")
+	days := fset.Int("days", 30, "集計対象期間(日数)")
+	projectsDir := fset.String("dir", "", "セッションディレクトリ (デフォルト: ~/.claude/projects)")
+	configPath := fset.String("config", "", "設定ファイルのパス (デフォルト: ~/.claude/"+configFileName+" があれば使用)")
+	pricingPath := fset.String("pricing", "", "料金表JSONのパス (デフォルト: ~/.claude/"+pricingFileName+" があれば使用)")
+	cachePath := fset.String("cache", "", "スキャン結果のキャッシュファイル (デフォルト: ユーザーキャッシュディレクトリ配下)")
+	noCache := fset.Bool("no-cache", false, "キャッシュをファイルに保存しない")
+	fset.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: analyze-tokens serve [flags]\n")
+		fset.PrintDefaults()
+	}
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if *interval <= 0 {
+		fmt.Fprintf(stderr, "--interval は正の値を指定してください: %s\n", *interval)
+		return 2
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(stderr, "ホームディレクトリ取得失敗: %v\n", err)
+		return 1
+	}
+	e, err := newExporter(home, *projectsDir, *days, *configPath, *pricingPath, *cachePath, *noCache, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go e.run(ctx, *interval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	server := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	fmt.Fprintf(stderr, "%s/metrics で待ち受けます\n", *listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "サーバーの起動に失敗: %v\n", err)
+		return 1
+	}
+	return 0
+}
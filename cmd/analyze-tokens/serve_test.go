@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	results := []SessionResult{
+		{Project: "alpha", ModelUsage: map[string]ModelTokens{
+			"claude-opus-4-6": {InputTokens: 100, OutputTokens: 10, CacheReadTokens: 900, CallCount: 2, CostUSD: 0.5},
+		}},
+		{Project: "alpha", ModelUsage: map[string]ModelTokens{
+			"claude-opus-4-6": {InputTokens: 50, OutputTokens: 5, CallCount: 1},
+		}},
+		{Project: "alpha", IsSidechain: true, ModelUsage: map[string]ModelTokens{
+			"claude-haiku-4-5": {InputTokens: 20, CallCount: 1},
+		}},
+		{Project: `we"ird`, ModelUsage: map[string]ModelTokens{"claude-opus-4-6": {InputTokens: 1, CallCount: 1}}},
+	}
+	report := Report{Warnings: []Warning{{Type: "high_avg_input", Project: "alpha"}, {Type: "global_high_avg"}}}
+	var b strings.Builder
+	writeOpenMetrics(&b, results, report, 30)
+	out := b.String()
+
+	for _, want := range []string{
+		"# TYPE analyze_tokens_tokens gauge\n",
+		`analyze_tokens_tokens{project="alpha",model="claude-opus-4-6",type="input"} 150` + "\n",
+		`analyze_tokens_tokens{project="alpha",model="claude-opus-4-6",type="cache_read"} 900` + "\n",
+		`analyze_tokens_api_calls{project="alpha",model="claude-haiku-4-5"} 1` + "\n",
+		`analyze_tokens_cost_usd{project="alpha",model="claude-opus-4-6"} 0.5` + "\n",
+		`analyze_tokens_sessions{project="alpha"} 2` + "\n",
+		`analyze_tokens_sessions{project="we\"ird"} 1` + "\n",
+		`analyze_tokens_warnings{type="high_avg_input",project="alpha"} 1` + "\n",
+		`analyze_tokens_warnings{type="global_high_avg",project=""} 1` + "\n",
+		"analyze_tokens_window_days 30\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q がない:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `cost_usd{project="alpha",model="claude-haiku-4-5"}`) {
+		t.Error("料金のないモデルの cost は出力しない")
+	}
+}
+
+func TestExporter(t *testing.T) {
+	dir := t.TempDir()
+	line := func(id string) string {
+		return `{"type":"assistant","cwd":"/Users/test/alpha","sessionId":"s1","timestamp":"` + time.Now().UTC().Format(time.RFC3339) +
+			`","message":{"id":"` + id + `","model":"claude-opus-4-6","content":[],"usage":{"input_tokens":1000,"output_tokens":10}}}` + "\n"
+	}
+	path := writeTestJSONL(t, dir, "s1.jsonl", line("msg_1"))
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	var stderr bytes.Buffer
+	e, err := newExporter(t.TempDir(), dir, 30, "", "", cachePath, false, &stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	get := func() (int, string) {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(resp.Body)
+		if resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Type") != openMetricsContentType {
+			t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+		}
+		return resp.StatusCode, body.String()
+	}
+
+	t.Run("初回スキャン前は503", func(t *testing.T) {
+		if code, _ := get(); code != http.StatusServiceUnavailable {
+			t.Errorf("code = %d", code)
+		}
+	})
+
+	t.Run("追記した分を次のスキャンで反映", func(t *testing.T) {
+		e.scan(time.Now())
+		code, body := get()
+		if code != http.StatusOK || !strings.Contains(body, `type="input"} 1000`) || !strings.HasSuffix(body, "# EOF\n") {
+			t.Fatalf("code = %d, body = %s", code, body)
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.WriteString(line("msg_2"))
+		_ = f.Close()
+
+		e.scan(time.Now())
+		_, body = get()
+		if !strings.Contains(body, `type="input"} 2000`) || !strings.Contains(body, "analyze_tokens_scans_total 2\n") {
+			t.Errorf("body = %s", body)
+		}
+		if _, err := os.Stat(cachePath); err != nil {
+			t.Errorf("キャッシュが保存されていない: %v", err)
+		}
+	})
+}
+
+func TestExporterNoCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"type":"assistant","cwd":"/Users/test/alpha","sessionId":"s1","timestamp":"` + time.Now().UTC().Format(time.RFC3339) +
+		`","message":{"id":"msg_1","model":"claude-opus-4-6","content":[],"usage":{"input_tokens":1000,"output_tokens":10}}}` + "\n"
+	kept := writeTestJSONL(t, dir, "s1.jsonl", content)
+	old := writeTestJSONL(t, dir, "s2.jsonl", content)
+	e, err := newExporter(t.TempDir(), dir, 30, "", "", "", true, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.scan(time.Now())
+	if len(e.cache.Files) != 2 {
+		t.Fatalf("Files = %d件", len(e.cache.Files))
+	}
+
+	// 期間外になったファイルは --no-cache でもメモリ上のキャッシュから消える
+	past := time.Now().AddDate(0, 0, -60)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+	e.scan(time.Now())
+	if _, ok := e.cache.Files[kept]; !ok || len(e.cache.Files) != 1 {
+		t.Errorf("Files = %v", e.cache.Files)
+	}
+}
+
+func TestRunServeFlags(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := runServe([]string{"--interval", "0s"}, &stderr); code != 2 {
+		t.Errorf("code = %d", code)
+	}
+}
@@ -2,33 +2,109 @@ package main
 
 import (
 	"encoding/json"
+	"maps"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/usadamasa/claude-config/internal/jsonlscan"
+	"github.com/usadamasa/claude-config/internal/project"
 )
 
 // ModelTokens はモデル別のtoken使用量を表す｡
 type ModelTokens struct {
-	InputTokens  int64 `json:"input_tokens"`
-	OutputTokens int64 `json:"output_tokens"`
-	CallCount    int   `json:"call_count"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CallCount           int     `json:"call_count"`
+	CostUSD             float64 `json:"cost_usd,omitempty"`
+}
+
+// ContextTokens はAPIコール1回の実効コンテキストサイズ(input + cache read + cache creation)を返す｡
+func (c APICall) ContextTokens() int64 {
+	return contextTokens(c.Usage.InputTokens, c.Usage.CacheReadInputTokens, c.Usage.CacheCreationInputTokens)
+}
+
+// APICall はAPIコール1回分の記録｡
+type APICall struct {
+	Timestamp time.Time
+	Model     string
+	Usage     tokenUsage
+	CostUSD   float64
+	// Tools はこのコールの応答に含まれる tool_use のツール名｡
+	Tools []string
+	// Subagent は progress エントリ(subagent)由来のコールか｡
+	Subagent bool
+	// ParentToolUseID は subagent を起動した Task ツール呼び出しの ID｡
+	ParentToolUseID string
+}
+
+// CompactionEvent は会話の compaction (system/compact_boundary) の記録｡
+type CompactionEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	// CallIndex は compaction 直前までのAPIコール数｡
+	CallIndex int    `json:"call_index"`
+	Trigger   string `json:"trigger,omitempty"`
+	PreTokens int64  `json:"pre_tokens,omitempty"`
 }
 
 // SessionResult はセッション1つ分のtoken使用量集計結果を表す｡
 type SessionResult struct {
-	SessionID                string                 `json:"session_id"`
-	Project                  string                 `json:"project"`
-	Model                    string                 `json:"model"`
-	TotalInputTokens         int64                  `json:"total_input_tokens"`
-	TotalOutputTokens        int64                  `json:"total_output_tokens"`
-	TotalCacheCreationTokens int64                  `json:"total_cache_creation_tokens"`
-	TotalCacheReadTokens     int64                  `json:"total_cache_read_tokens"`
-	APICallCount             int                    `json:"api_call_count"`
-	UserMessageCount         int                    `json:"user_message_count"`
-	ModelUsage               map[string]ModelTokens `json:"model_usage"`
-	ToolUsage                map[string]int         `json:"tool_usage"`
-	FilePath                 string                 `json:"file_path"`
+	SessionID                string                     `json:"session_id"`
+	Project                  string                     `json:"project"`
+	ProjectID                string                     `json:"project_id,omitempty"`
+	ProjectRoot              string                     `json:"project_root,omitempty"`
+	Model                    string                     `json:"model"`
+	TotalInputTokens         int64                      `json:"total_input_tokens"`
+	TotalOutputTokens        int64                      `json:"total_output_tokens"`
+	TotalCacheCreationTokens int64                      `json:"total_cache_creation_tokens"`
+	TotalCacheReadTokens     int64                      `json:"total_cache_read_tokens"`
+	APICallCount             int                        `json:"api_call_count"`
+	UserMessageCount         int                        `json:"user_message_count"`
+	ModelUsage               map[string]ModelTokens     `json:"model_usage"`
+	ToolUsage                map[string]int             `json:"tool_usage"`
+	ToolResults              map[string]ToolResultStats `json:"tool_results,omitempty"`
+	// SkillUsage は Skill ツールで呼び出したスキル名ごとの回数｡
+	SkillUsage map[string]int `json:"skill_usage,omitempty"`
+	// CommandUsage はユーザーが実行したスラッシュコマンド名("/" を除く)ごとの回数｡
+	CommandUsage          map[string]int    `json:"command_usage,omitempty"`
+	AverageContextPerCall int64             `json:"average_context_per_call"`
+	CacheHitRatio         float64           `json:"cache_hit_ratio"`
+	CostUSD               float64           `json:"cost_usd,omitempty"`
+	FilePath              string            `json:"file_path"`
+	Calls                 []APICall         `json:"-"`
+	Compactions           []CompactionEvent `json:"-"`
+	// IsSidechain は subagent のトランスクリプト(sidechain)ファイルか｡
+	// sidechain の SessionID は agentId、ParentSessionID は起動元のセッション｡
+	IsSidechain     bool   `json:"is_sidechain,omitempty"`
+	AgentID         string `json:"agent_id,omitempty"`
+	ParentSessionID string `json:"parent_session_id,omitempty"`
+	// SubagentSessions は Top セッションで合算した sidechain の数｡
+	SubagentSessions int `json:"subagent_sessions,omitempty"`
+	// tasks は Task ツール呼び出し ID からエージェント情報への対応｡
+	tasks map[string]TaskInfo
+	// agentTasks は sidechain の agentId から Task ツール呼び出し ID への対応｡
+	agentTasks map[string]string
+	// toolNames は tool_use ID からツール名への対応｡
+	toolNames map[string]string
+	// toolCalls はツール呼び出しの履歴｡ループの検出に使う｡
+	toolCalls []toolCall
+	// callIndex は message.id (なければ requestId) から Calls の添字への対応｡
+	// 1つの応答が content ブロックごとに複数行に分けて記録されるため、同じ応答を1回のコールとして数える｡
+	callIndex map[string]int
+}
+
+// rootSessionID は sidechain なら起動元のセッション ID、それ以外は自身の SessionID を返す｡
+// セッション数を数えるときは sidechain を起動元と同じセッションとして扱う｡
+func (r *SessionResult) rootSessionID() string {
+	if r.IsSidechain && r.ParentSessionID != "" {
+		return r.ParentSessionID
+	}
+	return r.SessionID
 }
 
 // AverageInputTokensPerCall は1APIコールあたりの平均input tokensを返す｡
@@ -39,21 +115,73 @@ func (r *SessionResult) AverageInputTokensPerCall() int64 {
 	return r.TotalInputTokens / int64(r.APICallCount)
 }
 
+// ContextTokens はセッション全体の実効コンテキストtokens合計を返す｡
+func (r *SessionResult) ContextTokens() int64 {
+	return contextTokens(r.TotalInputTokens, r.TotalCacheReadTokens, r.TotalCacheCreationTokens)
+}
+
+// updateCacheMetrics は AverageContextPerCall と CacheHitRatio を集計値から再計算する｡
+func (r *SessionResult) updateCacheMetrics() {
+	r.AverageContextPerCall = averagePerCall(r.ContextTokens(), r.APICallCount)
+	r.CacheHitRatio = cacheHitRatio(r.TotalInputTokens, r.TotalCacheReadTokens, r.TotalCacheCreationTokens)
+}
+
+// contextTokens は実効コンテキストサイズを返す｡
+// cache read/creation もモデルに渡るコンテキストに含まれる｡
+func contextTokens(input, cacheRead, cacheCreation int64) int64 {
+	return input + cacheRead + cacheCreation
+}
+
+// cacheHitRatio は実効コンテキストのうち cache read の割合を返す｡
+func cacheHitRatio(input, cacheRead, cacheCreation int64) float64 {
+	return ratio(cacheRead, contextTokens(input, cacheRead, cacheCreation))
+}
+
+// ratio は part / total を小数第3位で丸めて返す｡total が0なら0｡
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*1000) / 1000
+}
+
+func averagePerCall(tokens int64, calls int) int64 {
+	if calls == 0 {
+		return 0
+	}
+	return tokens / int64(calls)
+}
+
 // jsonlEntry はセッションJSONLファイルの1行を表す｡
 type jsonlEntry struct {
-	Type     string          `json:"type"`
-	CWD      string          `json:"cwd"`
-	Session  string          `json:"sessionId"`
-	UserType string          `json:"userType"`
-	Message  json.RawMessage `json:"message"`
-	Data     json.RawMessage `json:"data"`
+	Type      string          `json:"type"`
+	CWD       string          `json:"cwd"`
+	GitBranch string          `json:"gitBranch"`
+	Timestamp string          `json:"timestamp"`
+	Session   string          `json:"sessionId"`
+	UserType  string          `json:"userType"`
+	Subtype   string          `json:"subtype"`
+	RequestID string          `json:"requestId"`
+	Message   json.RawMessage `json:"message"`
+	Data      json.RawMessage `json:"data"`
+	// subagent 関連: progress の起動元 Task ID、sidechain ファイルの識別子、Task 結果の agentId｡
+	ParentToolUseID string          `json:"parentToolUseID"`
+	IsSidechain     bool            `json:"isSidechain"`
+	AgentID         string          `json:"agentId"`
+	ToolUseResult   json.RawMessage `json:"toolUseResult"`
+	// CompactMetadata は system/compact_boundary エントリのみ持つ｡
+	CompactMetadata *struct {
+		Trigger   string `json:"trigger"`
+		PreTokens int64  `json:"preTokens"`
+	} `json:"compactMetadata"`
 }
 
 // assistantMessage はassistantエントリのmessageフィールド｡
 type assistantMessage struct {
-	Model   string                  `json:"model"`
+	ID      string                   `json:"id"`
+	Model   string                   `json:"model"`
 	Content []jsonlscan.ContentBlock `json:"content"`
-	Usage   tokenUsage              `json:"usage"`
+	Usage   tokenUsage               `json:"usage"`
 }
 
 // tokenUsage はAPIレスポンスのusageフィールド｡
@@ -71,60 +199,165 @@ type progressData struct {
 
 // progressMessage はprogress.data.messageフィールド｡
 type progressMessage struct {
-	Type    string           `json:"type"`
+	Type    string            `json:"type"`
 	Message *assistantMessage `json:"message"`
 }
 
 // ScanSessionFile は1つのセッションJSONLファイルからtoken使用量を集計する｡
 func ScanSessionFile(path string) (*SessionResult, error) {
+	return ScanSessionFileWithResolver(path, project.NewResolver(nil))
+}
+
+// ScanSessionFileWithResolver は resolver でプロジェクトを解決して ScanSessionFile と同じ集計を行う｡
+func ScanSessionFileWithResolver(path string, resolver *project.Resolver) (*SessionResult, error) {
 	f, err := os.Open(path) // #nosec G304 -- CLIツール: パスはWalkDir由来
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = f.Close() }()
 
-	result := &SessionResult{
+	s := newSessionScanner(path)
+	scanner := jsonlscan.NewScanner(f)
+	for scanner.Scan() {
+		s.processLine(scanner.Bytes())
+	}
+	return s.finish(resolver), scanner.Err()
+}
+
+// sessionScanner はセッションJSONLファイルを1行ずつ集計する｡
+// 途中の状態をキャッシュに保存し、追記された行から集計を再開できる｡
+type sessionScanner struct {
+	result *SessionResult
+	// seenEntry は先頭のエントリを処理済みか｡
+	seenEntry bool
+	// cwds はセッション中の作業ディレクトリごとのAPIコール数(出現順)｡
+	cwds []cwdUsage
+}
+
+// cwdUsage は作業ディレクトリ1つ分のAPIコール数｡
+type cwdUsage struct {
+	CWD       string `json:"cwd"`
+	GitBranch string `json:"git_branch,omitempty"`
+	Calls     int    `json:"calls"`
+}
+
+func newSessionScanner(path string) *sessionScanner {
+	return &sessionScanner{result: &SessionResult{
 		ModelUsage: make(map[string]ModelTokens),
 		ToolUsage:  make(map[string]int),
 		FilePath:   path,
-	}
-
-	scanner := jsonlscan.NewScanner(f)
+	}}
+}
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
+// recordCWD は cwd でのAPIコール数を加算する｡
+func (s *sessionScanner) recordCWD(cwd, gitBranch string, calls int) {
+	for i := range s.cwds {
+		if s.cwds[i].CWD == cwd {
+			s.cwds[i].Calls += calls
+			if gitBranch != "" {
+				s.cwds[i].GitBranch = gitBranch
+			}
+			return
 		}
+	}
+	s.cwds = append(s.cwds, cwdUsage{CWD: cwd, GitBranch: gitBranch, Calls: calls})
+}
 
-		var entry jsonlEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
-			continue
+// primaryCWD はAPIコールが最も多い作業ディレクトリを返す｡同数なら先に現れたもの｡
+func (s *sessionScanner) primaryCWD() cwdUsage {
+	var best cwdUsage
+	for i, c := range s.cwds {
+		if i == 0 || c.Calls > best.Calls {
+			best = c
 		}
+	}
+	return best
+}
 
-		if result.SessionID == "" && entry.Session != "" {
-			result.SessionID = entry.Session
-		}
-		if result.Project == "" && entry.CWD != "" {
-			result.Project = ExtractProjectName(entry.CWD)
-		}
+// processLine はJSONLの1行を集計に加える｡パースできない行は無視する｡
+func (s *sessionScanner) processLine(line []byte) {
+	if len(line) == 0 {
+		return
+	}
+	var entry jsonlEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
 
-		switch entry.Type {
-		case "assistant":
-			processAssistantEntry(result, entry.Message)
-		case "progress":
-			processProgressEntry(result, entry.Data)
-		case "user":
-			if entry.UserType == "external" {
-				result.UserMessageCount++
+	result := s.result
+	if result.SessionID == "" && entry.Session != "" {
+		result.SessionID = entry.Session
+	}
+	// 先頭行が sidechain ならファイル全体が subagent のトランスクリプト｡
+	// 旧形式ではメインのファイル内に sidechain 行が混在するため、その行は subagent として扱う｡
+	if !s.seenEntry {
+		result.IsSidechain = entry.IsSidechain
+		s.seenEntry = true
+	}
+	if result.IsSidechain && result.AgentID == "" {
+		result.AgentID = entry.AgentID
+	}
+
+	ts, _ := jsonlscan.ParseTimestamp(entry.Timestamp)
+	calls := result.APICallCount
+	switch entry.Type {
+	case "assistant":
+		processAssistantEntry(result, entry.Message, ts, entry.IsSidechain && !result.IsSidechain, entry.RequestID)
+	case "progress":
+		processProgressEntry(result, entry.Data, ts, entry.ParentToolUseID)
+	case "user":
+		if entry.UserType == "external" {
+			result.UserMessageCount++
+		}
+		processUserEntry(result, entry)
+	case "system":
+		if entry.Subtype == "compact_boundary" {
+			ev := CompactionEvent{Timestamp: ts, CallIndex: len(result.Calls)}
+			if entry.CompactMetadata != nil {
+				ev.Trigger = entry.CompactMetadata.Trigger
+				ev.PreTokens = entry.CompactMetadata.PreTokens
 			}
+			result.Compactions = append(result.Compactions, ev)
 		}
 	}
+	// cd したセッションは、最も多くAPIコールを行ったディレクトリのプロジェクトに帰属させる
+	if entry.CWD != "" {
+		s.recordCWD(entry.CWD, entry.GitBranch, result.APICallCount-calls)
+	}
+}
 
-	return result, scanner.Err()
+// finish は集計途中の状態から SessionResult を作り、resolver でプロジェクトを解決する｡
+// 料金の設定などで呼び出し側が変更してもキャッシュ中の状態に影響しないよう、コピーを返す｡
+func (s *sessionScanner) finish(resolver *project.Resolver) *SessionResult {
+	r := *s.result
+	cwd := s.primaryCWD()
+	id := resolver.Resolve(cwd.CWD, cwd.GitBranch)
+	r.ProjectID, r.ProjectRoot = id.ID, id.Root
+	r.Project = resolver.Name(r.ProjectID)
+	r.ModelUsage = maps.Clone(r.ModelUsage)
+	r.ToolUsage = maps.Clone(r.ToolUsage)
+	r.ToolResults = maps.Clone(r.ToolResults)
+	r.SkillUsage = maps.Clone(r.SkillUsage)
+	r.CommandUsage = maps.Clone(r.CommandUsage)
+	r.Calls = slices.Clone(r.Calls)
+	r.Compactions = slices.Clone(r.Compactions)
+	r.tasks = maps.Clone(r.tasks)
+	r.agentTasks = maps.Clone(r.agentTasks)
+	r.toolNames = maps.Clone(r.toolNames)
+	r.callIndex = maps.Clone(r.callIndex)
+	r.toolCalls = slices.Clone(r.toolCalls)
+
+	if r.IsSidechain {
+		r.ParentSessionID = r.SessionID
+		if r.AgentID != "" {
+			r.SessionID = r.AgentID
+		}
+	}
+	r.updateCacheMetrics()
+	return &r
 }
 
-func processAssistantEntry(result *SessionResult, raw json.RawMessage) {
+func processAssistantEntry(result *SessionResult, raw json.RawMessage, ts time.Time, subagent bool, requestID string) {
 	if raw == nil {
 		return
 	}
@@ -133,22 +366,14 @@ func processAssistantEntry(result *SessionResult, raw json.RawMessage) {
 		return
 	}
 
-	addUsage(result, msg.Model, msg.Usage)
-
-	if result.Model == "" && msg.Model != "" {
+	if result.Model == "" && msg.Model != "" && !subagent {
 		result.Model = msg.Model
 	}
 
-	for _, block := range msg.Content {
-		if block.Type == "tool_use" && block.Name != "" {
-			result.ToolUsage[block.Name]++
-		}
-	}
-
-	result.APICallCount++
+	recordCall(result, &msg, APICall{Timestamp: ts, Subagent: subagent}, requestID)
 }
 
-func processProgressEntry(result *SessionResult, raw json.RawMessage) {
+func processProgressEntry(result *SessionResult, raw json.RawMessage, ts time.Time, parentToolUseID string) {
 	if raw == nil {
 		return
 	}
@@ -165,18 +390,156 @@ func processProgressEntry(result *SessionResult, raw json.RawMessage) {
 		return
 	}
 
-	addUsage(result, msg.Model, msg.Usage)
+	recordCall(result, msg, APICall{Timestamp: ts, Subagent: true, ParentToolUseID: parentToolUseID}, "")
+}
+
+// processUserEntry は user エントリのスラッシュコマンドと tool_result を集計する｡
+func processUserEntry(result *SessionResult, entry jsonlEntry) {
+	if entry.Message == nil {
+		return
+	}
+	var msg struct {
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(entry.Message, &msg); err != nil {
+		return
+	}
+	// 通常のユーザー入力とスラッシュコマンドは content が文字列
+	var text string
+	if err := json.Unmarshal(msg.Content, &text); err == nil {
+		recordCommand(result, text)
+		return
+	}
+	var blocks []toolResultBlock
+	if err := json.Unmarshal(msg.Content, &blocks); err != nil {
+		return
+	}
+	recordToolResults(result, blocks)
+	recordAgentResult(result, entry.ToolUseResult, blocks)
+}
+
+// recordCommand はユーザー入力の <command-name>/name</command-name> をスラッシュコマンドの実行として数える｡
+func recordCommand(result *SessionResult, text string) {
+	_, rest, ok := strings.Cut(text, "<command-name>")
+	if !ok {
+		return
+	}
+	name, _, ok := strings.Cut(rest, "</command-name>")
+	if name = strings.TrimPrefix(strings.TrimSpace(name), "/"); !ok || name == "" {
+		return
+	}
+	if result.CommandUsage == nil {
+		result.CommandUsage = make(map[string]int)
+	}
+	result.CommandUsage[name]++
+}
+
+// recordCall はAPIコール1回分の usage とツール使用を集計に加える｡
+// call には Timestamp などメッセージ外の情報を設定して渡す｡
+// 記録済みの応答(message.id または requestID が同じ)の続きの行は、ツール使用と usage の増分のみ加える｡
+func recordCall(result *SessionResult, msg *assistantMessage, call APICall, requestID string) {
+	key := msg.ID
+	if key == "" {
+		key = requestID
+	}
+	if i, ok := result.callIndex[key]; ok && key != "" {
+		prev := &result.Calls[i]
+		prev.Tools = append(prev.Tools, recordTools(result, msg, i+1)...)
+		delta := usageDelta(prev.Usage, msg.Usage)
+		prev.Usage = addTokenUsage(prev.Usage, delta)
+		addUsage(result, prev.Model, delta, false)
+		return
+	}
+
+	call.Model = msg.Model
+	call.Usage = msg.Usage
+	call.Tools = recordTools(result, msg, len(result.Calls)+1)
+	addUsage(result, msg.Model, msg.Usage, true)
+	if key != "" {
+		if result.callIndex == nil {
+			result.callIndex = make(map[string]int)
+		}
+		result.callIndex[key] = len(result.Calls)
+	}
+	result.Calls = append(result.Calls, call)
+	result.APICallCount++
+}
 
+// recordTools は応答中の tool_use を集計に加え、ツール名を返す｡turn は応答の API コール番号(1始まり)｡
+func recordTools(result *SessionResult, msg *assistantMessage, turn int) []string {
+	var tools []string
 	for _, block := range msg.Content {
 		if block.Type == "tool_use" && block.Name != "" {
 			result.ToolUsage[block.Name]++
+			tools = append(tools, block.Name)
+			if block.ID != "" {
+				if result.toolNames == nil {
+					result.toolNames = make(map[string]string)
+				}
+				result.toolNames[block.ID] = block.Name
+			}
+			recordTask(result, block)
+			recordSkill(result, block)
+			result.toolCalls = append(result.toolCalls, newToolCall(block, turn))
 		}
 	}
+	return tools
+}
 
-	result.APICallCount++
+// skillName は Skill ツール呼び出しのスキル名を返す｡Skill 以外や名前がなければ空文字｡
+func skillName(block jsonlscan.ContentBlock) string {
+	if block.Name != "Skill" {
+		return ""
+	}
+	var input struct {
+		Skill   string `json:"skill"`
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(block.Input, &input); err != nil {
+		return ""
+	}
+	name := input.Skill
+	if name == "" {
+		name = input.Command
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+// recordSkill は Skill ツールの呼び出しをスキル名ごとに数える｡
+func recordSkill(result *SessionResult, block jsonlscan.ContentBlock) {
+	name := skillName(block)
+	if name == "" {
+		return
+	}
+	if result.SkillUsage == nil {
+		result.SkillUsage = make(map[string]int)
+	}
+	result.SkillUsage[name]++
+}
+
+// usageDelta は同じ応答の2行目以降の usage のうち、記録済みの値を上回る分を返す｡
+// 分割された行は同じ usage を繰り返すが、最後の行だけ output_tokens が確定値になることがある｡
+func usageDelta(prev, cur tokenUsage) tokenUsage {
+	pos := func(n int64) int64 { return max(n, 0) }
+	return tokenUsage{
+		InputTokens:              pos(cur.InputTokens - prev.InputTokens),
+		CacheCreationInputTokens: pos(cur.CacheCreationInputTokens - prev.CacheCreationInputTokens),
+		CacheReadInputTokens:     pos(cur.CacheReadInputTokens - prev.CacheReadInputTokens),
+		OutputTokens:             pos(cur.OutputTokens - prev.OutputTokens),
+	}
 }
 
-func addUsage(result *SessionResult, model string, usage tokenUsage) {
+func addTokenUsage(a, b tokenUsage) tokenUsage {
+	return tokenUsage{
+		InputTokens:              a.InputTokens + b.InputTokens,
+		CacheCreationInputTokens: a.CacheCreationInputTokens + b.CacheCreationInputTokens,
+		CacheReadInputTokens:     a.CacheReadInputTokens + b.CacheReadInputTokens,
+		OutputTokens:             a.OutputTokens + b.OutputTokens,
+	}
+}
+
+// addUsage は usage を合計とモデル別の集計に加える｡newCall ならモデル別のコール数も数える｡
+func addUsage(result *SessionResult, model string, usage tokenUsage, newCall bool) {
 	result.TotalInputTokens += usage.InputTokens
 	result.TotalOutputTokens += usage.OutputTokens
 	result.TotalCacheCreationTokens += usage.CacheCreationInputTokens
@@ -186,17 +549,30 @@ func addUsage(result *SessionResult, model string, usage tokenUsage) {
 		mt := result.ModelUsage[model]
 		mt.InputTokens += usage.InputTokens
 		mt.OutputTokens += usage.OutputTokens
-		mt.CallCount++
+		mt.CacheCreationTokens += usage.CacheCreationInputTokens
+		mt.CacheReadTokens += usage.CacheReadInputTokens
+		if newCall {
+			mt.CallCount++
+		}
 		result.ModelUsage[model] = mt
 	}
 }
 
 // ScanProjectsDir は指定ディレクトリ以下の全JONLファイルを走査してtoken使用量を集計する｡
 func ScanProjectsDir(projectsDir string, days int) ([]SessionResult, error) {
+	return ScanProjectsDirWithCache(projectsDir, days, "", nil, project.NewResolver(nil))
+}
+
+// ScanProjectsDirWithCache は ScanProjectsDir と同じ集計を、cache に保存済みの状態を使って行う｡
+// 未変更のファイルは解析せず、追記されたファイルは続きの行だけを解析する｡cache が nil なら毎回解析する｡
+// projectFilter を指定すると、別のプロジェクトのプロジェクトディレクトリは読まずに飛ばす｡
+// プロジェクト名は全セッションのプロジェクトを解決した後に、重複しないよう割り当てる｡
+func ScanProjectsDirWithCache(projectsDir string, days int, projectFilter string, cache *SessionCache, resolver *project.Resolver) ([]SessionResult, error) {
 	var results []SessionResult
 
-	err := jsonlscan.WalkJSONLFiles(projectsDir, jsonlscan.WalkOptions{Days: days}, func(path string) error {
-		result, err := ScanSessionFile(path)
+	opts := jsonlscan.WalkOptions{Days: days, SkipDir: otherProjectDir(projectsDir, projectFilter, resolver)}
+	err := jsonlscan.WalkJSONLFiles(projectsDir, opts, func(path string) error {
+		result, err := scanSessionFileCached(path, cache, resolver)
 		if err != nil {
 			return nil
 		}
@@ -208,26 +584,62 @@ func ScanProjectsDir(projectsDir string, days int) ([]SessionResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	for i := range results {
+		results[i].Project = resolver.Name(results[i].ProjectID)
+	}
 	return results, nil
 }
 
-// ExtractProjectName はcwdからプロジェクト名を抽出する｡
-func ExtractProjectName(cwd string) string {
-	if cwd == "" {
-		return ""
+// otherProjectDir は projectsDir 直下のプロジェクトディレクトリが projectFilter と別のプロジェクトかを判定する関数を返す｡
+// プロジェクトディレクトリはセッションを起動した cwd ごとに作られるため、最初に見つかった cwd のプロジェクトで判定する｡
+// 起動後に別のリポジトリへ cd したセッションは、起動時のプロジェクトとして扱われる｡
+func otherProjectDir(projectsDir, projectFilter string, resolver *project.Resolver) func(path string) bool {
+	if projectFilter == "" {
+		return nil
 	}
-
-	parts := strings.Split(cwd, "/")
-
-	for i, p := range parts {
-		if p == "worktree" && i+1 < len(parts) {
-			return parts[i+1]
+	root := filepath.Clean(projectsDir)
+	return func(path string) bool {
+		if filepath.Dir(path) != root {
+			return false
+		}
+		cwd, gitBranch, ok := projectDirCWD(path)
+		if !ok {
+			return false
 		}
+		return !resolver.Matches(resolver.Resolve(cwd, gitBranch), projectFilter)
 	}
+}
 
-	if len(parts) > 0 {
-		return parts[len(parts)-1]
+// projectDirCWD はディレクトリ直下の JSONL ファイルから最初に見つかった cwd と gitBranch を返す｡
+func projectDirCWD(dir string) (cwd, gitBranch string, ok bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", false
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		if cwd, gitBranch, ok := firstCWD(filepath.Join(dir, e.Name())); ok {
+			return cwd, gitBranch, true
+		}
 	}
+	return "", "", false
+}
 
-	return ""
+// firstCWD は JSONL ファイルで最初に cwd を持つ行の cwd と gitBranch を返す｡
+func firstCWD(path string) (cwd, gitBranch string, ok bool) {
+	f, err := os.Open(path) // #nosec G304 -- CLIツール: パスはReadDir由来
+	if err != nil {
+		return "", "", false
+	}
+	defer func() { _ = f.Close() }()
+	scanner := jsonlscan.NewScanner(f)
+	for scanner.Scan() {
+		var line jsonlscan.JSONLLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err == nil && line.CWD != "" {
+			return line.CWD, line.GitBranch, true
+		}
+	}
+	return "", "", false
 }
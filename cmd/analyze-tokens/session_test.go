@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // writeTestJSONL はテスト用のJSONLファイルを作成するヘルパー｡
@@ -166,6 +167,36 @@ not valid json
 		if result.TotalCacheReadTokens != 25000 {
 			t.Errorf("TotalCacheReadTokens = %d, want %d", result.TotalCacheReadTokens, 25000)
 		}
+		mt := result.ModelUsage["claude-opus-4-6"]
+		if mt.CacheCreationTokens != 10000 || mt.CacheReadTokens != 25000 {
+			t.Errorf("ModelUsage cache tokens = (%d, %d), want (10000, 25000)", mt.CacheCreationTokens, mt.CacheReadTokens)
+		}
+	})
+
+	t.Run("APIコールごとにタイムスタンプとusageを記録", func(t *testing.T) {
+		dir := t.TempDir()
+		content := `{"type":"assistant","timestamp":"2026-10-01T09:00:00.000Z","sessionId":"sess-8","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":100,"cache_read_input_tokens":5000,"output_tokens":10}}}
+{"type":"assistant","sessionId":"sess-8","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":200,"output_tokens":20}}}
+`
+		writeTestJSONL(t, dir, "test.jsonl", content)
+
+		result, err := ScanSessionFile(filepath.Join(dir, "test.jsonl"))
+		if err != nil {
+			t.Fatalf("ScanSessionFile失敗: %v", err)
+		}
+		if len(result.Calls) != 2 {
+			t.Fatalf("Calls = %d件, want 2件", len(result.Calls))
+		}
+		want := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+		if !result.Calls[0].Timestamp.Equal(want) {
+			t.Errorf("Calls[0].Timestamp = %v, want %v", result.Calls[0].Timestamp, want)
+		}
+		if result.Calls[0].Usage.CacheReadInputTokens != 5000 || result.Calls[0].Model != "claude-opus-4-6" {
+			t.Errorf("Calls[0] = %+v", result.Calls[0])
+		}
+		if !result.Calls[1].Timestamp.IsZero() {
+			t.Errorf("timestampのない行は zero time: got %v", result.Calls[1].Timestamp)
+		}
 	})
 
 	t.Run("ツール使用をカウント", func(t *testing.T) {
@@ -186,6 +217,76 @@ not valid json
 			t.Errorf("ToolUsage[Bash] = %d, want %d", result.ToolUsage["Bash"], 1)
 		}
 	})
+	t.Run("Skillツールの呼び出しをスキル名ごとにカウント", func(t *testing.T) {
+		dir := t.TempDir()
+		// 古い版は input の command に "/name" を入れる
+		content := `{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-9","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","name":"Skill","input":{"skill":"git-workflow"}},{"type":"tool_use","name":"Skill","input":{"skill":"superpowers:brainstorming"}}],"usage":{"input_tokens":1000,"output_tokens":10}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-9","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","name":"Skill","input":{"command":"/git-workflow"}}],"usage":{"input_tokens":2000,"output_tokens":20}}}
+`
+		writeTestJSONL(t, dir, "test.jsonl", content)
+
+		result, err := ScanSessionFile(filepath.Join(dir, "test.jsonl"))
+		if err != nil {
+			t.Fatalf("ScanSessionFile失敗: %v", err)
+		}
+		if result.SkillUsage["git-workflow"] != 2 || result.SkillUsage["superpowers:brainstorming"] != 1 {
+			t.Errorf("SkillUsage = %v", result.SkillUsage)
+		}
+		if result.ToolUsage["Skill"] != 3 {
+			t.Errorf("ToolUsage[Skill] = %d, want 3", result.ToolUsage["Skill"])
+		}
+		if len(result.toolCalls) != 3 || result.toolCalls[2].Detail != "git-workflow" {
+			t.Errorf("toolCalls = %+v", result.toolCalls)
+		}
+	})
+	t.Run("スラッシュコマンドの実行をコマンド名ごとにカウント", func(t *testing.T) {
+		dir := t.TempDir()
+		content := `{"type":"user","userType":"external","cwd":"/Users/test/project","sessionId":"sess-10","message":{"role":"user","content":"<command-message>plan is running…</command-message>\n<command-name>/superpowers:plan</command-name>\n<command-args>add cache</command-args>"}}
+{"type":"user","userType":"external","cwd":"/Users/test/project","sessionId":"sess-10","message":{"role":"user","content":"<command-name>/clear</command-name>"}}
+{"type":"user","userType":"external","cwd":"/Users/test/project","sessionId":"sess-10","message":{"role":"user","content":"/superpowers:plan をどう使う?"}}
+`
+		writeTestJSONL(t, dir, "test.jsonl", content)
+
+		result, err := ScanSessionFile(filepath.Join(dir, "test.jsonl"))
+		if err != nil {
+			t.Fatalf("ScanSessionFile失敗: %v", err)
+		}
+		if len(result.CommandUsage) != 2 || result.CommandUsage["superpowers:plan"] != 1 || result.CommandUsage["clear"] != 1 {
+			t.Errorf("CommandUsage = %v", result.CommandUsage)
+		}
+	})
+	t.Run("content ブロックごとに分割された応答は1回のコールとして数える", func(t *testing.T) {
+		dir := t.TempDir()
+		// msg_1 は text と2つの tool_use の3行に分かれ、最後の行で output_tokens が確定する｡
+		// msg_2 は message.id がなく requestId で同じ応答と判定する｡
+		content := `{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-8","requestId":"req_1","message":{"id":"msg_1","model":"claude-opus-4-6","content":[{"type":"text","text":"調べます"}],"usage":{"input_tokens":100,"cache_read_input_tokens":5000,"output_tokens":8}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-8","requestId":"req_1","message":{"id":"msg_1","model":"claude-opus-4-6","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{}}],"usage":{"input_tokens":100,"cache_read_input_tokens":5000,"output_tokens":8}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-8","requestId":"req_1","message":{"id":"msg_1","model":"claude-opus-4-6","content":[{"type":"tool_use","id":"toolu_2","name":"Read","input":{}}],"usage":{"input_tokens":100,"cache_read_input_tokens":5000,"output_tokens":120}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-8","requestId":"req_2","message":{"model":"claude-opus-4-6","content":[{"type":"text","text":"a"}],"usage":{"input_tokens":300,"output_tokens":5}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"sess-8","requestId":"req_2","message":{"model":"claude-opus-4-6","content":[{"type":"text","text":"b"}],"usage":{"input_tokens":300,"output_tokens":5}}}
+`
+		writeTestJSONL(t, dir, "test.jsonl", content)
+
+		result, err := ScanSessionFile(filepath.Join(dir, "test.jsonl"))
+		if err != nil {
+			t.Fatalf("ScanSessionFile失敗: %v", err)
+		}
+		if result.APICallCount != 2 || len(result.Calls) != 2 {
+			t.Fatalf("APICallCount = %d, Calls = %d, want 2", result.APICallCount, len(result.Calls))
+		}
+		if result.TotalInputTokens != 400 || result.TotalCacheReadTokens != 5000 || result.TotalOutputTokens != 125 {
+			t.Errorf("totals = input %d, cache read %d, output %d", result.TotalInputTokens, result.TotalCacheReadTokens, result.TotalOutputTokens)
+		}
+		if mt := result.ModelUsage["claude-opus-4-6"]; mt.CallCount != 2 || mt.OutputTokens != 125 {
+			t.Errorf("ModelUsage = %+v", mt)
+		}
+		if c := result.Calls[0]; c.Usage.OutputTokens != 120 || len(c.Tools) != 2 {
+			t.Errorf("Calls[0] = %+v", c)
+		}
+		if result.ToolUsage["Bash"] != 1 || result.ToolUsage["Read"] != 1 {
+			t.Errorf("ToolUsage = %v", result.ToolUsage)
+		}
+	})
 }
 
 func TestScanProjectsDir(t *testing.T) {
@@ -214,6 +315,29 @@ func TestScanProjectsDir(t *testing.T) {
 		}
 	})
 
+	t.Run("同名のプロジェクトを区別しcdしたセッションは主なディレクトリに帰属", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestJSONL(t, dir, "a.jsonl", `{"type":"assistant","cwd":"/Users/test/src/a/api","sessionId":"a","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":100}}}
+`)
+		writeTestJSONL(t, dir, "b.jsonl", `{"type":"user","cwd":"/Users/test/src/web","sessionId":"b","userType":"external","message":{"content":[]}}
+{"type":"assistant","cwd":"/Users/test/src/web","sessionId":"b","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":100}}}
+{"type":"assistant","cwd":"/Users/test/src/b/api","sessionId":"b","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":100}}}
+{"type":"assistant","cwd":"/Users/test/src/b/api","sessionId":"b","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":100}}}
+`)
+
+		results, err := ScanProjectsDir(dir, 30)
+		if err != nil {
+			t.Fatalf("ScanProjectsDir失敗: %v", err)
+		}
+		projects := map[string]string{}
+		for _, r := range results {
+			projects[r.SessionID] = r.Project
+		}
+		if projects["a"] != "a/api" || projects["b"] != "b/api" {
+			t.Errorf("projects = %v", projects)
+		}
+	})
+
 	t.Run("存在しないディレクトリはエラーなし", func(t *testing.T) {
 		results, err := ScanProjectsDir("/nonexistent/path", 30)
 		if err != nil {
@@ -246,48 +370,6 @@ func TestScanProjectsDir(t *testing.T) {
 	})
 }
 
-func TestExtractProjectName(t *testing.T) {
-	tests := []struct {
-		name string
-		cwd  string
-		want string
-	}{
-		{
-			name: "GitHub構成のパス",
-			cwd:  "/Users/test/src/github.com/org/my-project",
-			want: "my-project",
-		},
-		{
-			name: "worktreeパス",
-			cwd:  "/Users/test/src/github.com/org/worktree/my-project/feature-branch",
-			want: "my-project",
-		},
-		{
-			name: "ホームディレクトリ直下",
-			cwd:  "/Users/test",
-			want: "test",
-		},
-		{
-			name: "一般パス",
-			cwd:  "/tmp/workspace/project",
-			want: "project",
-		},
-		{
-			name: "空文字列",
-			cwd:  "",
-			want: "",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := ExtractProjectName(tt.cwd)
-			if got != tt.want {
-				t.Errorf("ExtractProjectName(%q) = %q, want %q", tt.cwd, got, tt.want)
-			}
-		})
-	}
-}
-
 func TestAverageInputTokensPerCall(t *testing.T) {
 	t.Run("通常計算", func(t *testing.T) {
 		r := &SessionResult{
@@ -308,3 +390,55 @@ func TestAverageInputTokensPerCall(t *testing.T) {
 		}
 	})
 }
+
+func TestCacheMetrics(t *testing.T) {
+	t.Run("実効コンテキストとcache hit ratio", func(t *testing.T) {
+		r := &SessionResult{
+			TotalInputTokens:         1000,
+			TotalCacheReadTokens:     75000,
+			TotalCacheCreationTokens: 24000,
+			APICallCount:             10,
+		}
+		r.updateCacheMetrics()
+		if r.AverageContextPerCall != 10000 {
+			t.Errorf("AverageContextPerCall = %d, want %d", r.AverageContextPerCall, 10000)
+		}
+		if r.CacheHitRatio != 0.75 {
+			t.Errorf("CacheHitRatio = %v, want %v", r.CacheHitRatio, 0.75)
+		}
+	})
+
+	t.Run("usageなしは0", func(t *testing.T) {
+		r := &SessionResult{}
+		r.updateCacheMetrics()
+		if r.AverageContextPerCall != 0 || r.CacheHitRatio != 0 {
+			t.Errorf("got (%d, %v), want (0, 0)", r.AverageContextPerCall, r.CacheHitRatio)
+		}
+	})
+
+	t.Run("APIコール1回分のコンテキスト", func(t *testing.T) {
+		c := APICall{Usage: tokenUsage{InputTokens: 10, CacheReadInputTokens: 20, CacheCreationInputTokens: 30, OutputTokens: 40}}
+		if got := c.ContextTokens(); got != 60 {
+			t.Errorf("ContextTokens() = %d, want 60", got)
+		}
+	})
+
+	t.Run("ScanSessionFileで算出される", func(t *testing.T) {
+		dir := t.TempDir()
+		content := `{"type":"assistant","sessionId":"sess-9","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":10,"cache_creation_input_tokens":30000,"cache_read_input_tokens":0,"output_tokens":100}}}
+{"type":"assistant","sessionId":"sess-9","message":{"model":"claude-opus-4-6","content":[],"usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":30000,"output_tokens":100}}}
+`
+		writeTestJSONL(t, dir, "test.jsonl", content)
+
+		result, err := ScanSessionFile(filepath.Join(dir, "test.jsonl"))
+		if err != nil {
+			t.Fatalf("ScanSessionFile失敗: %v", err)
+		}
+		if result.AverageContextPerCall != 30010 {
+			t.Errorf("AverageContextPerCall = %d, want %d", result.AverageContextPerCall, 30010)
+		}
+		if result.CacheHitRatio != 0.5 {
+			t.Errorf("CacheHitRatio = %v, want %v", result.CacheHitRatio, 0.5)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/usadamasa/claude-config/internal/jsonlscan"
+)
+
+// taskToolNames は subagent を起動するツール名｡
+var taskToolNames = map[string]bool{"Task": true, "Agent": true}
+
+// unknownAgentType は起動元の Task を特定できない subagent のエージェント種別｡
+const unknownAgentType = "(unknown)"
+
+// TaskInfo は Task ツール呼び出しで起動された subagent の情報｡
+type TaskInfo struct {
+	AgentType   string `json:"subagent_type"`
+	Description string `json:"description"`
+}
+
+// SubagentUsage はエージェント種別・Task 説明・モデル単位の subagent の使用量｡
+type SubagentUsage struct {
+	AgentType           string  `json:"agent_type"`
+	Description         string  `json:"description,omitempty"`
+	Model               string  `json:"model"`
+	CallCount           int     `json:"call_count"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	ContextTokens       int64   `json:"context_tokens"`
+	CostUSD             float64 `json:"cost_usd,omitempty"`
+}
+
+// SessionSubagents はセッション1つ分の subagent への配分｡
+// ContextTokens と CostUSD は親セッションと sidechain の子セッションの合計｡
+type SessionSubagents struct {
+	SessionID             string          `json:"session_id"`
+	Project               string          `json:"project"`
+	ContextTokens         int64           `json:"context_tokens"`
+	SubagentContextTokens int64           `json:"subagent_context_tokens"`
+	SubagentRatio         float64         `json:"subagent_ratio"`
+	CostUSD               float64         `json:"cost_usd,omitempty"`
+	SubagentCostUSD       float64         `json:"subagent_cost_usd,omitempty"`
+	Children              []string        `json:"children,omitempty"`
+	Subagents             []SubagentUsage `json:"subagents"`
+}
+
+// recordTask は Task ツール呼び出しの subagent_type と description を記録する｡
+func recordTask(result *SessionResult, block jsonlscan.ContentBlock) {
+	if !taskToolNames[block.Name] || block.ID == "" {
+		return
+	}
+	var info TaskInfo
+	_ = json.Unmarshal(block.Input, &info)
+	if result.tasks == nil {
+		result.tasks = make(map[string]TaskInfo)
+	}
+	result.tasks[block.ID] = info
+}
+
+// recordAgentResult は Task の tool_result に含まれる agentId と Task 呼び出し ID を対応付ける｡
+// sidechain ファイルの起動元 Task を特定するのに使う｡
+func recordAgentResult(result *SessionResult, toolUseResult json.RawMessage, blocks []toolResultBlock) {
+	if toolUseResult == nil {
+		return
+	}
+	var tur struct {
+		AgentID string `json:"agentId"`
+	}
+	if err := json.Unmarshal(toolUseResult, &tur); err != nil || tur.AgentID == "" {
+		return
+	}
+	for _, block := range blocks {
+		if block.Type == "tool_result" && block.ToolUseID != "" {
+			if result.agentTasks == nil {
+				result.agentTasks = make(map[string]string)
+			}
+			result.agentTasks[tur.AgentID] = block.ToolUseID
+			return
+		}
+	}
+}
+
+// taskFor は Task 呼び出し ID に対応するエージェント情報を返す｡
+func (r *SessionResult) taskFor(toolUseID string) TaskInfo {
+	if info, ok := r.tasks[toolUseID]; ok && info.AgentType != "" {
+		return info
+	}
+	info := r.tasks[toolUseID]
+	info.AgentType = unknownAgentType
+	return info
+}
+
+// subagentAccumulator は SubagentUsage をキー単位で集計する｡
+type subagentAccumulator struct {
+	entry   *SessionSubagents
+	byKey   map[TaskInfo]map[string]*SubagentUsage
+	ordered []*SubagentUsage
+}
+
+func (a *subagentAccumulator) add(info TaskInfo, c APICall) {
+	models, ok := a.byKey[info]
+	if !ok {
+		models = make(map[string]*SubagentUsage)
+		a.byKey[info] = models
+	}
+	u, ok := models[c.Model]
+	if !ok {
+		u = &SubagentUsage{AgentType: info.AgentType, Description: info.Description, Model: c.Model}
+		models[c.Model] = u
+		a.ordered = append(a.ordered, u)
+	}
+	u.CallCount++
+	u.InputTokens += c.Usage.InputTokens
+	u.OutputTokens += c.Usage.OutputTokens
+	u.CacheCreationTokens += c.Usage.CacheCreationInputTokens
+	u.CacheReadTokens += c.Usage.CacheReadInputTokens
+	u.ContextTokens += c.ContextTokens()
+	u.CostUSD += c.CostUSD
+
+	a.entry.SubagentContextTokens += c.ContextTokens()
+	a.entry.SubagentCostUSD += c.CostUSD
+}
+
+// BuildSubagentSummary はセッションごとの subagent 使用量を集計する｡
+// 親セッション内の progress エントリと、ParentSessionID で紐付く sidechain ファイルの両方を対象とし、
+// 同じ Task が両方に記録されている場合は sidechain 側を採用する｡
+// subagent の使用量が大きい順に最大 topN 件を返す｡
+func BuildSubagentSummary(results []SessionResult, topN int) []SessionSubagents {
+	accs := make(map[string]*subagentAccumulator)
+	var order []string
+	accFor := func(sessionID, project string) *subagentAccumulator {
+		a, ok := accs[sessionID]
+		if !ok {
+			a = &subagentAccumulator{
+				entry: &SessionSubagents{SessionID: sessionID, Project: project},
+				byKey: make(map[TaskInfo]map[string]*SubagentUsage),
+			}
+			accs[sessionID] = a
+			order = append(order, sessionID)
+		}
+		return a
+	}
+
+	parents := make(map[string]*SessionResult)
+	for i := range results {
+		r := &results[i]
+		if !r.IsSidechain {
+			parents[r.SessionID] = r
+			a := accFor(r.SessionID, r.Project)
+			a.entry.ContextTokens += r.ContextTokens()
+			a.entry.CostUSD += r.CostUSD
+		}
+	}
+
+	// sidechain の子セッション
+	linkedTasks := make(map[string]bool)
+	for i := range results {
+		child := &results[i]
+		if !child.IsSidechain {
+			continue
+		}
+		info := TaskInfo{AgentType: unknownAgentType}
+		a := accFor(child.ParentSessionID, child.Project)
+		if parent, ok := parents[child.ParentSessionID]; ok {
+			if toolUseID, ok := parent.agentTasks[child.AgentID]; ok {
+				info = parent.taskFor(toolUseID)
+				linkedTasks[toolUseID] = true
+			}
+		}
+		a.entry.Children = append(a.entry.Children, child.SessionID)
+		a.entry.ContextTokens += child.ContextTokens()
+		a.entry.CostUSD += child.CostUSD
+		for _, c := range child.Calls {
+			a.add(info, c)
+		}
+	}
+
+	// 親セッション内の progress / 旧形式 sidechain 行
+	for _, parent := range parents {
+		a := accs[parent.SessionID]
+		for _, c := range parent.Calls {
+			if !c.Subagent || linkedTasks[c.ParentToolUseID] {
+				continue
+			}
+			a.add(parent.taskFor(c.ParentToolUseID), c)
+		}
+	}
+
+	var summary []SessionSubagents
+	for _, id := range order {
+		a := accs[id]
+		if a.entry.SubagentContextTokens == 0 {
+			continue
+		}
+		for _, u := range a.ordered {
+			a.entry.Subagents = append(a.entry.Subagents, *u)
+		}
+		sort.SliceStable(a.entry.Subagents, func(i, j int) bool {
+			return a.entry.Subagents[i].ContextTokens > a.entry.Subagents[j].ContextTokens
+		})
+		a.entry.SubagentRatio = ratio(a.entry.SubagentContextTokens, a.entry.ContextTokens)
+		summary = append(summary, *a.entry)
+	}
+	sort.SliceStable(summary, func(i, j int) bool {
+		return summary[i].SubagentContextTokens > summary[j].SubagentContextTokens
+	})
+	if topN > 0 && len(summary) > topN {
+		summary = summary[:topN]
+	}
+	return summary
+}
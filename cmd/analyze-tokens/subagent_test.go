@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+)
+
+const subagentParentJSONL = `{"type":"user","cwd":"/Users/test/project","sessionId":"parent-1","userType":"external","timestamp":"2026-10-01T09:00:00Z","message":{"content":[]}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"parent-1","timestamp":"2026-10-01T09:00:05Z","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","id":"toolu_explore","name":"Task","input":{"subagent_type":"Explore","description":"コード調査","prompt":"..."}},{"type":"tool_use","id":"toolu_review","name":"Agent","input":{"subagent_type":"code-reviewer","description":"レビュー","prompt":"..."}}],"usage":{"input_tokens":10,"cache_read_input_tokens":990,"output_tokens":100}}}
+{"type":"progress","cwd":"/Users/test/project","sessionId":"parent-1","parentToolUseID":"toolu_explore","timestamp":"2026-10-01T09:00:10Z","data":{"message":{"type":"assistant","message":{"model":"claude-haiku-4-5-20251001","content":[],"usage":{"input_tokens":3000,"output_tokens":50}}}}}
+{"type":"progress","cwd":"/Users/test/project","sessionId":"parent-1","parentToolUseID":"toolu_review","timestamp":"2026-10-01T09:00:11Z","data":{"message":{"type":"assistant","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":400,"output_tokens":50}}}}}
+{"type":"progress","cwd":"/Users/test/project","sessionId":"parent-1","parentToolUseID":"toolu_missing","timestamp":"2026-10-01T09:00:12Z","data":{"message":{"type":"assistant","message":{"model":"claude-haiku-4-5-20251001","content":[],"usage":{"input_tokens":100,"output_tokens":10}}}}}
+{"type":"user","cwd":"/Users/test/project","sessionId":"parent-1","timestamp":"2026-10-01T09:00:20Z","toolUseResult":{"status":"completed","agentId":"agent-a1"},"message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_review","content":"ok"}]}}
+`
+
+const subagentChildJSONL = `{"type":"user","cwd":"/Users/test/project","sessionId":"parent-1","isSidechain":true,"agentId":"agent-a1","timestamp":"2026-10-01T09:00:11Z","message":{"role":"user","content":"review"}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"parent-1","isSidechain":true,"agentId":"agent-a1","timestamp":"2026-10-01T09:00:12Z","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":400,"cache_creation_input_tokens":1600,"output_tokens":50}}}
+{"type":"assistant","cwd":"/Users/test/project","sessionId":"parent-1","isSidechain":true,"agentId":"agent-a1","timestamp":"2026-10-01T09:00:15Z","message":{"model":"claude-sonnet-4-5","content":[],"usage":{"input_tokens":10,"cache_read_input_tokens":1990,"output_tokens":50}}}
+`
+
+func scanSubagentFixtures(t *testing.T) (*SessionResult, *SessionResult) {
+	t.Helper()
+	dir := t.TempDir()
+	parent, err := ScanSessionFile(writeTestJSONL(t, dir, "parent-1.jsonl", subagentParentJSONL))
+	if err != nil {
+		t.Fatalf("ScanSessionFile(parent)失敗: %v", err)
+	}
+	child, err := ScanSessionFile(writeTestJSONL(t, dir, "agent-a1.jsonl", subagentChildJSONL))
+	if err != nil {
+		t.Fatalf("ScanSessionFile(child)失敗: %v", err)
+	}
+	return parent, child
+}
+
+func TestScanSessionFileSubagents(t *testing.T) {
+	parent, child := scanSubagentFixtures(t)
+
+	t.Run("Task呼び出しとagentIdの対応を記録", func(t *testing.T) {
+		if got := parent.taskFor("toolu_explore"); got.AgentType != "Explore" || got.Description != "コード調査" {
+			t.Errorf("taskFor(toolu_explore) = %+v", got)
+		}
+		if got := parent.taskFor("toolu_review"); got.AgentType != "code-reviewer" {
+			t.Errorf("Agent ツール名も Task として扱うべき: %+v", got)
+		}
+		if got := parent.taskFor("toolu_missing"); got.AgentType != unknownAgentType {
+			t.Errorf("taskFor(toolu_missing) = %+v", got)
+		}
+		if parent.agentTasks["agent-a1"] != "toolu_review" {
+			t.Errorf("agentTasks = %v", parent.agentTasks)
+		}
+	})
+
+	t.Run("progressのコールにTask IDを付与", func(t *testing.T) {
+		if len(parent.Calls) != 4 || parent.Calls[1].ParentToolUseID != "toolu_explore" {
+			t.Errorf("Calls = %+v", parent.Calls)
+		}
+		if parent.Model != "claude-opus-4-6" {
+			t.Errorf("Model = %q, subagentのモデルに上書きされてはいけない", parent.Model)
+		}
+	})
+
+	t.Run("sidechainファイルは親セッションに紐付く", func(t *testing.T) {
+		if !child.IsSidechain || child.SessionID != "agent-a1" || child.ParentSessionID != "parent-1" {
+			t.Errorf("child = {IsSidechain:%v SessionID:%q ParentSessionID:%q}", child.IsSidechain, child.SessionID, child.ParentSessionID)
+		}
+		if child.Model != "claude-sonnet-4-5" || child.APICallCount != 2 {
+			t.Errorf("child Model/APICallCount = %q/%d", child.Model, child.APICallCount)
+		}
+		if parent.IsSidechain || parent.ParentSessionID != "" {
+			t.Errorf("親セッションは sidechain ではない: %+v", parent)
+		}
+	})
+}
+
+func TestBuildSubagentSummary(t *testing.T) {
+	parent, child := scanSubagentFixtures(t)
+	summary := BuildSubagentSummary([]SessionResult{*parent, *child}, 10)
+	if len(summary) != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	s := summary[0]
+
+	t.Run("親セッション単位で子を集約", func(t *testing.T) {
+		if s.SessionID != "parent-1" || len(s.Children) != 1 || s.Children[0] != "agent-a1" {
+			t.Errorf("summary = %+v", s)
+		}
+		// 親: 1000 + 3000 + 400 + 100, 子: 2000 + 2000
+		if s.ContextTokens != 8500 {
+			t.Errorf("ContextTokens = %d, want 8500", s.ContextTokens)
+		}
+	})
+
+	t.Run("sidechainとprogressの重複は子セッション側を採用", func(t *testing.T) {
+		// Explore 3000 + code-reviewer 4000 (progress の 400 は除外) + unknown 100
+		if s.SubagentContextTokens != 7100 {
+			t.Errorf("SubagentContextTokens = %d, want 7100", s.SubagentContextTokens)
+		}
+		if s.SubagentRatio != 0.835 {
+			t.Errorf("SubagentRatio = %v, want 0.835", s.SubagentRatio)
+		}
+	})
+
+	t.Run("エージェント種別ごとの内訳", func(t *testing.T) {
+		if len(s.Subagents) != 3 {
+			t.Fatalf("Subagents = %+v", s.Subagents)
+		}
+		want := []struct {
+			agentType string
+			model     string
+			calls     int
+			context   int64
+		}{
+			{"code-reviewer", "claude-sonnet-4-5", 2, 4000},
+			{"Explore", "claude-haiku-4-5-20251001", 1, 3000},
+			{unknownAgentType, "claude-haiku-4-5-20251001", 1, 100},
+		}
+		for i, w := range want {
+			u := s.Subagents[i]
+			if u.AgentType != w.agentType || u.Model != w.model || u.CallCount != w.calls || u.ContextTokens != w.context {
+				t.Errorf("Subagents[%d] = %+v, want %+v", i, u, w)
+			}
+		}
+	})
+
+	t.Run("subagentのないセッションは含めない", func(t *testing.T) {
+		plain := SessionResult{SessionID: "plain", Calls: []APICall{{Usage: tokenUsage{InputTokens: 100}}}}
+		if got := BuildSubagentSummary([]SessionResult{plain}, 10); len(got) != 0 {
+			t.Errorf("summary = %+v", got)
+		}
+	})
+}
+
+func TestRatio(t *testing.T) {
+	if got := ratio(1, 3); got != 0.333 {
+		t.Errorf("ratio(1, 3) = %v, want 0.333", got)
+	}
+	if got := ratio(5, 0); got != 0 {
+		t.Errorf("ratio(5, 0) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 時系列の集計単位｡
+const (
+	GroupByHour = "hour"
+	GroupByDay  = "day"
+	GroupByWeek = "week"
+)
+
+// TimeBucket は時系列の1区間(ローカル時刻)の集計｡
+// Start は hour なら "2006-01-02T15:00"、day なら "2006-01-02"、week なら週初め(月曜)の日付｡
+type TimeBucket struct {
+	Start               string  `json:"start"`
+	APICalls            int     `json:"api_calls"`
+	Sessions            int     `json:"sessions"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	ContextTokens       int64   `json:"context_tokens"`
+	CostUSD             float64 `json:"cost_usd,omitempty"`
+}
+
+// TimeSeries は --group-by 指定時の出力｡
+type TimeSeries struct {
+	GroupBy string       `json:"group_by"`
+	Days    int          `json:"days"`
+	Buckets []TimeBucket `json:"buckets"`
+}
+
+// ValidateGroupBy は --group-by の値を検証する｡
+func ValidateGroupBy(groupBy string) error {
+	switch groupBy {
+	case GroupByHour, GroupByDay, GroupByWeek:
+		return nil
+	default:
+		return fmt.Errorf("不明な集計単位: %s (hour, day, week のいずれか)", groupBy)
+	}
+}
+
+// bucketStart は t が属する区間のキーを返す｡
+func bucketStart(t time.Time, groupBy string) string {
+	t = t.Local()
+	switch groupBy {
+	case GroupByHour:
+		return t.Format("2006-01-02T15:00")
+	case GroupByWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // 月曜起点
+		return t.AddDate(0, 0, -offset).Format("2006-01-02")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// BuildTimeSeries はAPIコールごとのタイムスタンプから時系列を作る｡
+// ファイルの mtime ではなく各行の timestamp で区間を決めるため、
+// since より前のコールとタイムスタンプのないコールは集計しない｡since がゼロ値なら全期間｡
+func BuildTimeSeries(results []SessionResult, groupBy string, since time.Time) []TimeBucket {
+	buckets := make(map[string]*TimeBucket)
+	sessions := make(map[string]map[string]bool)
+	for i, r := range results {
+		sessionKey := r.rootSessionID()
+		if sessionKey == "" {
+			sessionKey = strconv.Itoa(i)
+		}
+		for _, c := range r.Calls {
+			if c.Timestamp.IsZero() || c.Timestamp.Before(since) {
+				continue
+			}
+			key := bucketStart(c.Timestamp, groupBy)
+			b, ok := buckets[key]
+			if !ok {
+				b = &TimeBucket{Start: key}
+				buckets[key] = b
+				sessions[key] = make(map[string]bool)
+			}
+			b.APICalls++
+			b.InputTokens += c.Usage.InputTokens
+			b.OutputTokens += c.Usage.OutputTokens
+			b.CacheCreationTokens += c.Usage.CacheCreationInputTokens
+			b.CacheReadTokens += c.Usage.CacheReadInputTokens
+			b.ContextTokens += c.ContextTokens()
+			b.CostUSD += c.CostUSD
+			sessions[key][sessionKey] = true
+		}
+	}
+
+	series := make([]TimeBucket, 0, len(buckets))
+	for key, b := range buckets {
+		b.Sessions = len(sessions[key])
+		series = append(series, *b)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Start < series[j].Start })
+	return series
+}
+
+// WriteTimeSeriesCSV は時系列をヘッダ付きCSVで書き出す｡
+func WriteTimeSeriesCSV(w io.Writer, buckets []TimeBucket) error {
+	cw := csv.NewWriter(w)
+	header := []string{"start", "api_calls", "sessions", "input_tokens", "output_tokens",
+		"cache_creation_tokens", "cache_read_tokens", "context_tokens", "cost_usd"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, b := range buckets {
+		record := []string{
+			b.Start,
+			strconv.Itoa(b.APICalls),
+			strconv.Itoa(b.Sessions),
+			strconv.FormatInt(b.InputTokens, 10),
+			strconv.FormatInt(b.OutputTokens, 10),
+			strconv.FormatInt(b.CacheCreationTokens, 10),
+			strconv.FormatInt(b.CacheReadTokens, 10),
+			strconv.FormatInt(b.ContextTokens, 10),
+			strconv.FormatFloat(b.CostUSD, 'f', 4, 64),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
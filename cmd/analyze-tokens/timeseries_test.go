@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketStart(t *testing.T) {
+	// 2026-10-01 は木曜日
+	ts := time.Date(2026, 10, 1, 13, 45, 0, 0, time.Local)
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{GroupByHour, "2026-10-01T13:00"},
+		{GroupByDay, "2026-10-01"},
+		{GroupByWeek, "2026-09-28"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			if got := bucketStart(ts, tt.groupBy); got != tt.want {
+				t.Errorf("bucketStart(%s) = %q, want %q", tt.groupBy, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("日曜日は前週の月曜に属する", func(t *testing.T) {
+		sunday := time.Date(2026, 10, 4, 23, 0, 0, 0, time.Local)
+		if got := bucketStart(sunday, GroupByWeek); got != "2026-09-28" {
+			t.Errorf("bucketStart(sunday) = %q, want %q", got, "2026-09-28")
+		}
+	})
+}
+
+func TestValidateGroupBy(t *testing.T) {
+	for _, v := range []string{"hour", "day", "week"} {
+		if err := ValidateGroupBy(v); err != nil {
+			t.Errorf("ValidateGroupBy(%q) = %v", v, err)
+		}
+	}
+	if err := ValidateGroupBy("month"); err == nil {
+		t.Error("ValidateGroupBy(month) はエラーになるべき")
+	}
+}
+
+func TestBuildTimeSeries(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 10, day, hour, 0, 0, 0, time.Local)
+	}
+	results := []SessionResult{
+		{SessionID: "s1", Calls: []APICall{
+			{Timestamp: at(1, 9), Usage: tokenUsage{InputTokens: 100, CacheReadInputTokens: 900, OutputTokens: 10}},
+			{Timestamp: at(1, 10), Usage: tokenUsage{InputTokens: 200, OutputTokens: 20}},
+			{Timestamp: at(2, 9), Usage: tokenUsage{InputTokens: 300}},
+		}},
+		{SessionID: "s2", Calls: []APICall{
+			{Timestamp: at(1, 9), Usage: tokenUsage{InputTokens: 400, CacheCreationInputTokens: 600}},
+			{Usage: tokenUsage{InputTokens: 999}}, // タイムスタンプなし
+		}},
+	}
+
+	t.Run("日単位", func(t *testing.T) {
+		series := BuildTimeSeries(results, GroupByDay, time.Time{})
+		if len(series) != 2 {
+			t.Fatalf("len(series) = %d, want 2: %+v", len(series), series)
+		}
+		d1 := series[0]
+		if d1.Start != "2026-10-01" || d1.APICalls != 3 || d1.Sessions != 2 {
+			t.Errorf("day1 = %+v", d1)
+		}
+		if d1.InputTokens != 700 || d1.OutputTokens != 30 || d1.ContextTokens != 2200 {
+			t.Errorf("day1 tokens = %+v", d1)
+		}
+		if series[1].Start != "2026-10-02" || series[1].Sessions != 1 {
+			t.Errorf("day2 = %+v", series[1])
+		}
+	})
+
+	t.Run("時間単位", func(t *testing.T) {
+		series := BuildTimeSeries(results, GroupByHour, time.Time{})
+		if len(series) != 3 || series[0].Start != "2026-10-01T09:00" || series[0].Sessions != 2 {
+			t.Errorf("series = %+v", series)
+		}
+	})
+
+	t.Run("since より前の行は除外", func(t *testing.T) {
+		series := BuildTimeSeries(results, GroupByDay, at(2, 0))
+		if len(series) != 1 || series[0].Start != "2026-10-02" {
+			t.Errorf("series = %+v", series)
+		}
+	})
+}
+
+func TestWriteTimeSeriesCSV(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteTimeSeriesCSV(&buf, []TimeBucket{
+		{Start: "2026-10-01", APICalls: 3, Sessions: 2, InputTokens: 700, OutputTokens: 30, ContextTokens: 2200, CostUSD: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("WriteTimeSeriesCSV失敗: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines = %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "start,api_calls,sessions,") {
+		t.Errorf("header = %q", lines[0])
+	}
+	if lines[1] != "2026-10-01,3,2,700,30,0,0,2200,0.5000" {
+		t.Errorf("record = %q", lines[1])
+	}
+}
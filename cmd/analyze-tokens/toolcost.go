@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// bytesPerToken は tool_result のバイト数から token 数を概算する係数｡
+const bytesPerToken = 4
+
+// imageBlockBytes は tool_result 内の画像ブロック1つを換算するバイト数｡
+// 画像は解像度で token 数が決まるため、上限付近の約1600 tokens として数える｡
+const imageBlockBytes = 1600 * bytesPerToken
+
+// unknownToolName は対応する tool_use が見つからない tool_result の集計先｡
+const unknownToolName = "(unknown)"
+
+// ToolResultStats はツール単位の tool_result サイズの集計｡
+type ToolResultStats struct {
+	ResultCount    int   `json:"result_count"`
+	ResultBytes    int64 `json:"result_bytes"`
+	MaxResultBytes int64 `json:"max_result_bytes"`
+}
+
+// ToolCostSummary はツール別の tool_result によるコンテキスト消費｡
+type ToolCostSummary struct {
+	Tool            string `json:"tool"`
+	MCPServer       string `json:"mcp_server,omitempty"`
+	ResultCount     int    `json:"result_count"`
+	ResultBytes     int64  `json:"result_bytes"`
+	EstimatedTokens int64  `json:"estimated_tokens"`
+	AverageTokens   int64  `json:"average_tokens"`
+	MaxTokens       int64  `json:"max_tokens"`
+	SessionCount    int    `json:"session_count"`
+}
+
+// MCPServerCostSummary は MCP サーバ別の tool_result によるコンテキスト消費｡
+type MCPServerCostSummary struct {
+	Server          string   `json:"server"`
+	Tools           []string `json:"tools"`
+	ResultCount     int      `json:"result_count"`
+	ResultBytes     int64    `json:"result_bytes"`
+	EstimatedTokens int64    `json:"estimated_tokens"`
+	AverageTokens   int64    `json:"average_tokens"`
+}
+
+// estimateTokens はバイト数から token 数を概算する｡
+func estimateTokens(bytes int64) int64 {
+	return bytes / bytesPerToken
+}
+
+// MCPServerName は mcp__<server>__<tool> 形式のツール名からサーバ名を返す｡
+// MCP ツールでなければ空文字を返す｡
+func MCPServerName(tool string) string {
+	rest, ok := strings.CutPrefix(tool, "mcp__")
+	if !ok {
+		return ""
+	}
+	server, _, _ := strings.Cut(rest, "__")
+	return server
+}
+
+// toolResultBytes は tool_result の content のサイズを返す｡
+// 文字列はその長さ、ブロック配列は text ブロックの本文長と画像ブロックの換算値の合計｡
+func toolResultBytes(raw json.RawMessage) int64 {
+	if len(raw) == 0 {
+		return 0
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return int64(len(s))
+		}
+	case '[':
+		var blocks []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(raw, &blocks); err == nil {
+			var n int64
+			for _, b := range blocks {
+				switch b.Type {
+				case "text":
+					n += int64(len(b.Text))
+				case "image":
+					n += imageBlockBytes
+				}
+			}
+			return n
+		}
+	}
+	return int64(len(raw))
+}
+
+// toolResultBlock は user エントリの message.content[] の要素｡
+// tool_result の本体は analyze-tokens だけが使うため、共有の jsonlscan.ContentBlock には含めない｡
+type toolResultBlock struct {
+	Type string `json:"type"`
+	// ToolUseID は tool_result ブロックが対応する tool_use の ID｡
+	ToolUseID string `json:"tool_use_id"`
+	// Content は tool_result の本体｡文字列またはブロック配列｡
+	Content json.RawMessage `json:"content"`
+	// IsError は tool_result がツールの失敗を表すか｡
+	IsError bool `json:"is_error"`
+}
+
+// recordToolResults は user エントリ内の tool_result のサイズを起動元ツール別に集計する｡
+// 対応する tool_use が見つからない結果は unknownToolName に計上する｡
+func recordToolResults(result *SessionResult, blocks []toolResultBlock) {
+	for _, block := range blocks {
+		if block.Type != "tool_result" {
+			continue
+		}
+		name, ok := result.toolNames[block.ToolUseID]
+		if !ok {
+			name = unknownToolName
+		}
+		size := toolResultBytes(block.Content)
+		if result.ToolResults == nil {
+			result.ToolResults = make(map[string]ToolResultStats)
+		}
+		st := result.ToolResults[name]
+		st.ResultCount++
+		st.ResultBytes += size
+		st.MaxResultBytes = max(st.MaxResultBytes, size)
+		result.ToolResults[name] = st
+		if block.IsError {
+			markToolCallFailed(result, block.ToolUseID)
+		}
+	}
+}
+
+// BuildToolCost はツール別と MCP サーバ別の tool_result サイズを集計する｡
+// いずれも推定 token 数の大きい順で、ツール別は最大 topN 件を返す｡
+func BuildToolCost(results []SessionResult, topN int) ([]ToolCostSummary, []MCPServerCostSummary) {
+	tools := make(map[string]*ToolCostSummary)
+	for _, r := range results {
+		for name, st := range r.ToolResults {
+			tc, ok := tools[name]
+			if !ok {
+				tc = &ToolCostSummary{Tool: name, MCPServer: MCPServerName(name)}
+				tools[name] = tc
+			}
+			tc.ResultCount += st.ResultCount
+			tc.ResultBytes += st.ResultBytes
+			tc.MaxTokens = max(tc.MaxTokens, estimateTokens(st.MaxResultBytes))
+			tc.SessionCount++
+		}
+	}
+
+	servers := make(map[string]*MCPServerCostSummary)
+	var toolCost []ToolCostSummary
+	for _, tc := range tools {
+		tc.EstimatedTokens = estimateTokens(tc.ResultBytes)
+		tc.AverageTokens = averagePerCall(tc.EstimatedTokens, tc.ResultCount)
+		toolCost = append(toolCost, *tc)
+
+		if tc.MCPServer == "" {
+			continue
+		}
+		sc, ok := servers[tc.MCPServer]
+		if !ok {
+			sc = &MCPServerCostSummary{Server: tc.MCPServer}
+			servers[tc.MCPServer] = sc
+		}
+		sc.Tools = append(sc.Tools, tc.Tool)
+		sc.ResultCount += tc.ResultCount
+		sc.ResultBytes += tc.ResultBytes
+	}
+
+	var serverCost []MCPServerCostSummary
+	for _, sc := range servers {
+		sort.Strings(sc.Tools)
+		sc.EstimatedTokens = estimateTokens(sc.ResultBytes)
+		sc.AverageTokens = averagePerCall(sc.EstimatedTokens, sc.ResultCount)
+		serverCost = append(serverCost, *sc)
+	}
+
+	sort.Slice(toolCost, func(i, j int) bool {
+		if toolCost[i].ResultBytes != toolCost[j].ResultBytes {
+			return toolCost[i].ResultBytes > toolCost[j].ResultBytes
+		}
+		return toolCost[i].Tool < toolCost[j].Tool
+	})
+	sort.Slice(serverCost, func(i, j int) bool {
+		if serverCost[i].ResultBytes != serverCost[j].ResultBytes {
+			return serverCost[i].ResultBytes > serverCost[j].ResultBytes
+		}
+		return serverCost[i].Server < serverCost[j].Server
+	})
+	if topN > 0 && len(toolCost) > topN {
+		toolCost = toolCost[:topN]
+	}
+	return toolCost, serverCost
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMCPServerName(t *testing.T) {
+	tests := []struct {
+		tool string
+		want string
+	}{
+		{"mcp__github__get_issue", "github"},
+		{"mcp__plugin_context7_context7__query-docs", "plugin_context7_context7"},
+		{"mcp__server", "server"},
+		{"Read", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			if got := MCPServerName(tt.tool); got != tt.want {
+				t.Errorf("MCPServerName(%q) = %q, want %q", tt.tool, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolResultBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want int64
+	}{
+		{"文字列", `"hello"`, 5},
+		{"マルチバイトはバイト数", `"日本"`, 6},
+		{"textブロック配列", `[{"type":"text","text":"abc"},{"type":"text","text":"de"}]`, 5},
+		{"画像ブロック", `[{"type":"image","source":{"type":"base64","data":"AAAA"}}]`, imageBlockBytes},
+		{"空", ``, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toolResultBytes(json.RawMessage(tt.raw)); got != tt.want {
+				t.Errorf("toolResultBytes(%s) = %d, want %d", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanSessionFileToolResults(t *testing.T) {
+	big := strings.Repeat("x", 8000)
+	content := `{"type":"assistant","cwd":"/Users/test/project","sessionId":"s1","message":{"model":"claude-opus-4-6","content":[{"type":"tool_use","id":"toolu_1","name":"Read","input":{}},{"type":"tool_use","id":"toolu_2","name":"mcp__github__get_issue","input":{}}],"usage":{"input_tokens":100,"output_tokens":10}}}
+{"type":"user","cwd":"/Users/test/project","sessionId":"s1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"` + big + `"},{"type":"tool_result","tool_use_id":"toolu_2","content":[{"type":"text","text":"issue body"}]}]}}
+{"type":"user","cwd":"/Users/test/project","sessionId":"s1","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_x","content":"orphan"}]}}
+{"type":"user","cwd":"/Users/test/project","sessionId":"s1","userType":"external","message":{"role":"user","content":"次の質問"}}
+`
+	path := writeTestJSONL(t, t.TempDir(), "s1.jsonl", content)
+	result, err := ScanSessionFile(path)
+	if err != nil {
+		t.Fatalf("ScanSessionFile失敗: %v", err)
+	}
+
+	want := map[string]ToolResultStats{
+		"Read":                   {ResultCount: 1, ResultBytes: 8000, MaxResultBytes: 8000},
+		"mcp__github__get_issue": {ResultCount: 1, ResultBytes: 10, MaxResultBytes: 10},
+		unknownToolName:          {ResultCount: 1, ResultBytes: 6, MaxResultBytes: 6},
+	}
+	if len(result.ToolResults) != len(want) {
+		t.Fatalf("ToolResults = %+v", result.ToolResults)
+	}
+	for name, w := range want {
+		if got := result.ToolResults[name]; got != w {
+			t.Errorf("ToolResults[%s] = %+v, want %+v", name, got, w)
+		}
+	}
+	if result.UserMessageCount != 1 {
+		t.Errorf("UserMessageCount = %d, want 1", result.UserMessageCount)
+	}
+}
+
+func TestBuildToolCost(t *testing.T) {
+	results := []SessionResult{
+		{SessionID: "s1", ToolResults: map[string]ToolResultStats{
+			"Read":                   {ResultCount: 10, ResultBytes: 400_000, MaxResultBytes: 100_000},
+			"mcp__github__get_issue": {ResultCount: 2, ResultBytes: 8_000, MaxResultBytes: 6_000},
+		}},
+		{SessionID: "s2", ToolResults: map[string]ToolResultStats{
+			"Read":                      {ResultCount: 10, ResultBytes: 400_000, MaxResultBytes: 40_000},
+			"mcp__github__list_commits": {ResultCount: 2, ResultBytes: 4_000, MaxResultBytes: 2_000},
+			"Bash":                      {ResultCount: 4, ResultBytes: 1_000, MaxResultBytes: 500},
+		}},
+	}
+
+	toolCost, serverCost := BuildToolCost(results, 3)
+
+	t.Run("ツール別は推定tokensの大きい順にtopN件", func(t *testing.T) {
+		if len(toolCost) != 3 {
+			t.Fatalf("toolCost = %+v", toolCost)
+		}
+		read := toolCost[0]
+		if read.Tool != "Read" || read.ResultCount != 20 || read.EstimatedTokens != 200_000 ||
+			read.AverageTokens != 10_000 || read.MaxTokens != 25_000 || read.SessionCount != 2 {
+			t.Errorf("toolCost[0] = %+v", read)
+		}
+		if toolCost[1].Tool != "mcp__github__get_issue" || toolCost[1].MCPServer != "github" {
+			t.Errorf("toolCost[1] = %+v", toolCost[1])
+		}
+	})
+
+	t.Run("MCPサーバ別はtopNで切り捨てる前のツールを集計", func(t *testing.T) {
+		if len(serverCost) != 1 {
+			t.Fatalf("serverCost = %+v", serverCost)
+		}
+		s := serverCost[0]
+		if s.Server != "github" || s.ResultCount != 4 || s.EstimatedTokens != 3_000 || len(s.Tools) != 2 {
+			t.Errorf("serverCost[0] = %+v", s)
+		}
+	})
+
+	t.Run("平均サイズの大きいツールを警告", func(t *testing.T) {
+		report := GenerateReport(results, 10)
+		if report.Summary.EstimatedToolResultTokens != 203_250 {
+			t.Errorf("EstimatedToolResultTokens = %d, want 203250", report.Summary.EstimatedToolResultTokens)
+		}
+		var found []string
+		for _, w := range report.Warnings {
+			if w.Type == "large_tool_results" {
+				found = append(found, w.Tool)
+			}
+		}
+		if len(found) != 1 || found[0] != "Read" {
+			t.Errorf("large_tool_results = %v, want [Read]", found)
+		}
+	})
+}
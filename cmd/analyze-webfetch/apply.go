@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/usadamasa/claude-config/internal/settings"
+)
+
+// ChangeKind identifies how a SettingsChange edits settings.json.
+type ChangeKind string
+
+const (
+	// ChangeAllowAdd adds a WebFetch(domain:...) entry to permissions.allow.
+	ChangeAllowAdd ChangeKind = "allow_add"
+	// ChangeAllowRemove removes the WebFetch/Fetch entries for a domain from permissions.allow.
+	ChangeAllowRemove ChangeKind = "allow_remove"
+	// ChangeSandboxAdd adds a domain to sandbox.network.allowedDomains.
+	ChangeSandboxAdd ChangeKind = "sandbox_add"
+)
+
+// Accept modes for --accept.
+const (
+	AcceptSafe = "safe"
+	AcceptAll  = "all"
+)
+
+// SettingsChange is a single edit derived from the report's recommendations.
+type SettingsChange struct {
+	Kind     ChangeKind
+	Domain   string
+	Category Category
+	Reason   string
+}
+
+// Safe reports whether the change is accepted by --accept safe.
+// Removals only narrow permissions, so they are always safe; additions are safe
+// only for domains categorized as safe.
+func (c SettingsChange) Safe() bool {
+	return c.Kind == ChangeAllowRemove || c.Category == CategorySafe
+}
+
+// String describes the change for prompts and the apply summary.
+func (c SettingsChange) String() string {
+	var action string
+	switch c.Kind {
+	case ChangeAllowAdd:
+		action = "permissions.allow に追加"
+	case ChangeAllowRemove:
+		action = "permissions.allow から削除"
+	case ChangeSandboxAdd:
+		action = "sandbox.network.allowedDomains に追加"
+	}
+	s := fmt.Sprintf("%s: %s", action, c.Domain)
+	if c.Category != "" {
+		s += fmt.Sprintf(" [%s]", c.Category)
+	}
+	if c.Reason != "" {
+		s += " (" + c.Reason + ")"
+	}
+	return s
+}
+
+// PlanChanges turns the report's recommendations into settings edits.
+// Domains from Add and Review are added to permissions, and also to the sandbox
+// when the sandbox is configured. Unused domains are removed from permissions only:
+// sandbox.network.allowedDomains also covers Bash network access, so an unused
+// WebFetch permission says nothing about whether the sandbox entry is stale.
+func PlanChanges(report Report) []SettingsChange {
+	sandboxSet := make(map[string]bool)
+	for _, d := range report.CurrentSandbox {
+		sandboxSet[d] = true
+	}
+	sandboxConfigured := report.CurrentSandbox != nil
+
+	var changes []SettingsChange
+	addSandbox := func(domain string, cat Category, reason string) {
+		if !sandboxConfigured || domainMatchesAllowlist(domain, sandboxSet) {
+			return
+		}
+		sandboxSet[domain] = true
+		changes = append(changes, SettingsChange{Kind: ChangeSandboxAdd, Domain: domain, Category: cat, Reason: reason})
+	}
+
+	recs := append(append([]DomainRecommendation(nil), report.Recommendations.Add...), report.Recommendations.Review...)
+	for _, rec := range recs {
+		changes = append(changes, SettingsChange{Kind: ChangeAllowAdd, Domain: rec.Domain, Category: rec.Category, Reason: rec.Reason})
+		addSandbox(rec.Domain, rec.Category, rec.Reason)
+	}
+	used := make(map[string]bool, len(report.AllDomains))
+	for _, d := range report.AllDomains {
+		used[d.Domain] = true
+	}
+	for _, u := range report.Recommendations.Unused {
+		// Unused compares domains exactly, so a wildcard entry is listed even
+		// when it still matches fetched subdomains; keep those.
+		if wildcardInUse(u.Domain, used) {
+			continue
+		}
+		changes = append(changes, SettingsChange{Kind: ChangeAllowRemove, Domain: u.Domain, Reason: u.Note})
+	}
+	for _, rec := range report.Recommendations.AddToSandbox {
+		addSandbox(rec.Domain, rec.Category, rec.Reason)
+	}
+	return changes
+}
+
+// SelectChanges picks the changes to apply. With accept set to "safe" or "all"
+// it selects without asking; with accept empty it prompts for each change on out
+// and reads y/N answers from in. Unanswered changes after EOF are declined.
+func SelectChanges(changes []SettingsChange, accept string, in io.Reader, out io.Writer) ([]SettingsChange, error) {
+	var selected []SettingsChange
+	switch accept {
+	case AcceptAll:
+		return changes, nil
+	case AcceptSafe:
+		for _, c := range changes {
+			if c.Safe() {
+				selected = append(selected, c)
+			}
+		}
+		return selected, nil
+	case "":
+	default:
+		return nil, fmt.Errorf("--accept には %q か %q を指定してください: %q", AcceptSafe, AcceptAll, accept)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for _, c := range changes {
+		fmt.Fprintf(out, "%s を適用しますか? [y/N]: ", c)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			break
+		}
+		answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if answer == "y" || answer == "yes" {
+			selected = append(selected, c)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("入力の読み込みに失敗: %w", err)
+	}
+	return selected, nil
+}
+
+// ApplyChanges applies the changes to the settings.json bytes and canonicalizes
+// the result the same way normalize-settings does. Fields other than
+// permissions.allow and sandbox.network.allowedDomains are preserved as-is.
+func ApplyChanges(data []byte, changes []SettingsChange) ([]byte, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, fmt.Errorf("JSON パースに失敗: %w", err)
+	}
+
+	var allowAdd, allowRemove, sandboxAdd []string
+	for _, c := range changes {
+		switch c.Kind {
+		case ChangeAllowAdd:
+			allowAdd = append(allowAdd, "WebFetch(domain:"+c.Domain+")")
+		case ChangeAllowRemove:
+			allowRemove = append(allowRemove, c.Domain)
+		case ChangeSandboxAdd:
+			sandboxAdd = append(sandboxAdd, c.Domain)
+		}
+	}
+
+	if len(allowAdd) > 0 || len(allowRemove) > 0 {
+		err := editStringList(top, []string{"permissions", "allow"}, func(allow []string) []string {
+			return mergeStrings(removeDomainPermissions(allow, allowRemove), allowAdd)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("permissions.allow の更新に失敗: %w", err)
+		}
+	}
+	if len(sandboxAdd) > 0 {
+		err := editStringList(top, []string{"sandbox", "network", "allowedDomains"}, func(domains []string) []string {
+			return mergeStrings(domains, sandboxAdd)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("sandbox.network.allowedDomains の更新に失敗: %w", err)
+		}
+	}
+
+	out, err := json.Marshal(top)
+	if err != nil {
+		return nil, fmt.Errorf("JSON シリアライズに失敗: %w", err)
+	}
+	normalized, _, err := settings.Normalize(out, "", settings.DefaultStripFields)
+	return normalized, err
+}
+
+// ApplyChangesToFile applies the changes to the file at path and writes it back
+// only when the content changes.
+func ApplyChangesToFile(path string, changes []SettingsChange) (bool, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはフラグ引数由来
+	if err != nil {
+		return false, fmt.Errorf("ファイル読み込みに失敗: %w", err)
+	}
+
+	updated, err := ApplyChanges(data, changes)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(data, updated) {
+		return false, nil
+	}
+
+	if err := os.WriteFile(path, updated, 0600); err != nil { // #nosec G306
+		return false, fmt.Errorf("ファイル書き込みに失敗: %w", err)
+	}
+	return true, nil
+}
+
+// editStringList rewrites the string array at keys inside obj, creating
+// intermediate objects when they are missing.
+func editStringList(obj map[string]json.RawMessage, keys []string, edit func([]string) []string) error {
+	key := keys[0]
+	if len(keys) == 1 {
+		var list []string
+		if raw, ok := obj[key]; ok {
+			if err := json.Unmarshal(raw, &list); err != nil {
+				return err
+			}
+		}
+		b, err := json.Marshal(edit(list))
+		if err != nil {
+			return err
+		}
+		obj[key] = b
+		return nil
+	}
+
+	child := make(map[string]json.RawMessage)
+	if raw, ok := obj[key]; ok {
+		if err := json.Unmarshal(raw, &child); err != nil {
+			return err
+		}
+	}
+	if err := editStringList(child, keys[1:], edit); err != nil {
+		return err
+	}
+	b, err := json.Marshal(child)
+	if err != nil {
+		return err
+	}
+	obj[key] = b
+	return nil
+}
+
+// wildcardInUse reports whether a "*.example.com" entry matches any used domain.
+func wildcardInUse(entry string, used map[string]bool) bool {
+	if !strings.HasPrefix(entry, "*.") {
+		return false
+	}
+	set := map[string]bool{entry: true}
+	for d := range used {
+		if domainMatchesAllowlist(d, set) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDomainPermissions drops the WebFetch/Fetch entries for the given domains.
+func removeDomainPermissions(allow, domains []string) []string {
+	remove := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		remove[d] = true
+	}
+	kept := make([]string, 0, len(allow))
+	for _, perm := range allow {
+		if entry, ok := parseDomainPermission(perm); ok && remove[entry.Domain] {
+			continue
+		}
+		kept = append(kept, perm)
+	}
+	return kept
+}
+
+// mergeStrings appends the values of add that are not already in list.
+func mergeStrings(list, add []string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, s := range list {
+		seen[s] = true
+	}
+	if list == nil {
+		list = []string{}
+	}
+	for _, s := range add {
+		if !seen[s] {
+			seen[s] = true
+			list = append(list, s)
+		}
+	}
+	return list
+}
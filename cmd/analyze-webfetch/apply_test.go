@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPlanChanges(t *testing.T) {
+	t.Run("adds recommended domains to permissions and sandbox", func(t *testing.T) {
+		report := Report{
+			CurrentSandbox: []string{"github.com", "*.example.com"},
+			Recommendations: Recommendations{
+				Add:    []DomainRecommendation{{Domain: "docs.new-lib.io", Category: CategorySafe}},
+				Review: []DomainRecommendation{{Domain: "api.example.com", Category: CategoryReview}},
+				Unused: []UnusedDomain{{Domain: "docs.unused.com", InAllowlist: true}},
+				AddToSandbox: []DomainRecommendation{
+					{Domain: "pkg.go.dev", Category: CategorySafe},
+					{Domain: "docs.new-lib.io", Category: CategorySafe},
+				},
+			},
+		}
+
+		got := PlanChanges(report)
+		want := []SettingsChange{
+			{Kind: ChangeAllowAdd, Domain: "docs.new-lib.io", Category: CategorySafe},
+			{Kind: ChangeSandboxAdd, Domain: "docs.new-lib.io", Category: CategorySafe},
+			{Kind: ChangeAllowAdd, Domain: "api.example.com", Category: CategoryReview},
+			{Kind: ChangeAllowRemove, Domain: "docs.unused.com"},
+			{Kind: ChangeSandboxAdd, Domain: "pkg.go.dev", Category: CategorySafe},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d changes, got %d: %+v", len(want), len(got), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("change %d: expected %+v, got %+v", i, want[i], got[i])
+			}
+		}
+	})
+
+	t.Run("does not touch sandbox when it is not configured", func(t *testing.T) {
+		report := Report{
+			Recommendations: Recommendations{
+				Add: []DomainRecommendation{{Domain: "docs.new-lib.io", Category: CategorySafe}},
+			},
+		}
+		got := PlanChanges(report)
+		if len(got) != 1 || got[0].Kind != ChangeAllowAdd {
+			t.Errorf("expected only allow_add, got %+v", got)
+		}
+	})
+
+	t.Run("keeps wildcard entries that still match fetched domains", func(t *testing.T) {
+		report := Report{
+			Recommendations: Recommendations{
+				Unused: []UnusedDomain{{Domain: "*.github.com"}, {Domain: "*.unused.com"}},
+			},
+			AllDomains: []DomainSummary{{Domain: "api.github.com", Count: 3}},
+		}
+		got := PlanChanges(report)
+		if len(got) != 1 || got[0].Domain != "*.unused.com" {
+			t.Errorf("expected only *.unused.com to be removed, got %+v", got)
+		}
+	})
+}
+
+func TestSelectChanges(t *testing.T) {
+	changes := []SettingsChange{
+		{Kind: ChangeAllowAdd, Domain: "docs.new-lib.io", Category: CategorySafe},
+		{Kind: ChangeAllowAdd, Domain: "random-site.xyz", Category: CategoryReview},
+		{Kind: ChangeAllowRemove, Domain: "docs.unused.com"},
+	}
+
+	t.Run("accept safe selects safe additions and removals", func(t *testing.T) {
+		got, err := SelectChanges(changes, AcceptSafe, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0].Domain != "docs.new-lib.io" || got[1].Domain != "docs.unused.com" {
+			t.Errorf("unexpected selection: %+v", got)
+		}
+	})
+
+	t.Run("accept all selects everything", func(t *testing.T) {
+		got, err := SelectChanges(changes, AcceptAll, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 3 {
+			t.Errorf("expected 3 changes, got %d", len(got))
+		}
+	})
+
+	t.Run("rejects unknown accept mode", func(t *testing.T) {
+		if _, err := SelectChanges(changes, "yes", nil, nil); err == nil {
+			t.Error("expected error for unknown accept mode")
+		}
+	})
+
+	t.Run("interactive mode applies only confirmed changes", func(t *testing.T) {
+		var out bytes.Buffer
+		got, err := SelectChanges(changes, "", strings.NewReader("y\n\nYES\n"), &out)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0].Domain != "docs.new-lib.io" || got[1].Domain != "docs.unused.com" {
+			t.Errorf("unexpected selection: %+v", got)
+		}
+		if strings.Count(out.String(), "[y/N]") != 3 {
+			t.Errorf("expected 3 prompts, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("interactive mode declines the rest on EOF", func(t *testing.T) {
+		got, err := SelectChanges(changes, "", strings.NewReader("y\n"), &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 1 {
+			t.Errorf("expected 1 change, got %+v", got)
+		}
+	})
+}
+
+func TestApplyChanges(t *testing.T) {
+	t.Run("edits permissions and sandbox, canonicalizes the file and strips runtime fields", func(t *testing.T) {
+		input := `{
+  "permissions": {
+    "allow": [
+      "WebFetch(domain:github.com)",
+      "Fetch(domain:docs.unused.com)",
+      "Bash(git status)",
+      "WebFetch(domain:docs.unused.com)"
+    ],
+    "deny": ["Bash(curl:*)"]
+  },
+  "sandbox": {
+    "enabled": true,
+    "network": {
+      "allowedDomains": ["github.com"]
+    }
+  },
+  "model": "opus",
+  "effortLevel": "high",
+  "teammateMode": "auto"
+}`
+		changes := []SettingsChange{
+			{Kind: ChangeAllowAdd, Domain: "docs.new-lib.io"},
+			{Kind: ChangeAllowAdd, Domain: "github.com"},
+			{Kind: ChangeAllowRemove, Domain: "docs.unused.com"},
+			{Kind: ChangeSandboxAdd, Domain: "docs.new-lib.io"},
+		}
+
+		got, err := ApplyChanges([]byte(input), changes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := `{
+  "model": "opus",
+  "permissions": {
+    "allow": [
+      "Bash(git status)",
+      "WebFetch(domain:docs.new-lib.io)",
+      "WebFetch(domain:github.com)"
+    ],
+    "deny": [
+      "Bash(curl:*)"
+    ]
+  },
+  "sandbox": {
+    "enabled": true,
+    "network": {
+      "allowedDomains": [
+        "docs.new-lib.io",
+        "github.com"
+      ]
+    }
+  }
+}
+`
+		if string(got) != want {
+			t.Errorf("output mismatch\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("creates missing sections", func(t *testing.T) {
+		changes := []SettingsChange{
+			{Kind: ChangeAllowAdd, Domain: "pkg.go.dev"},
+			{Kind: ChangeSandboxAdd, Domain: "pkg.go.dev"},
+		}
+		got, err := ApplyChanges([]byte(`{"model":"opus"}`), changes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		s, err := LoadSettings(writeTestFile(t, t.TempDir(), "settings.json", string(got)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(s.Permissions.Allow) != 1 || s.Permissions.Allow[0] != "WebFetch(domain:pkg.go.dev)" {
+			t.Errorf("unexpected permissions.allow: %v", s.Permissions.Allow)
+		}
+		if len(s.Sandbox.Network.AllowedDomains) != 1 || s.Sandbox.Network.AllowedDomains[0] != "pkg.go.dev" {
+			t.Errorf("unexpected sandbox domains: %v", s.Sandbox.Network.AllowedDomains)
+		}
+	})
+
+	t.Run("returns error for invalid JSON", func(t *testing.T) {
+		if _, err := ApplyChanges([]byte(`{invalid}`), nil); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+	})
+}
+
+func TestApplyChangesToFile(t *testing.T) {
+	t.Run("writes only when the content changes", func(t *testing.T) {
+		path := writeTestFile(t, t.TempDir(), "settings.json", `{"permissions":{"allow":["WebFetch(domain:github.com)"]}}`)
+		changes := []SettingsChange{{Kind: ChangeAllowAdd, Domain: "pkg.go.dev"}}
+
+		changed, err := ApplyChangesToFile(path, changes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !changed {
+			t.Error("expected changed=true on first apply")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "WebFetch(domain:pkg.go.dev)") {
+			t.Errorf("expected new permission in file:\n%s", data)
+		}
+
+		changed, err = ApplyChangesToFile(path, changes)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if changed {
+			t.Error("expected changed=false on second apply")
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/usadamasa/claude-config/internal/jsonlscan"
 	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/project"
 )
 
 // Report is the top-level output structure.
@@ -38,6 +40,7 @@ type Recommendations struct {
 	Review       []DomainRecommendation `json:"review"`
 	Unused       []UnusedDomain         `json:"unused"`
 	AddToSandbox []DomainRecommendation `json:"add_to_sandbox,omitempty"`
+	Wildcards    []WildcardSuggestion   `json:"wildcards,omitempty"`
 }
 
 // DomainRecommendation represents a domain suggested for addition or review.
@@ -46,6 +49,7 @@ type DomainRecommendation struct {
 	Count    int      `json:"count"`
 	Category Category `json:"category"`
 	Reason   string   `json:"reason"`
+	Projects []string `json:"projects,omitempty"`
 }
 
 // UnusedDomain represents an allowlisted domain not seen in recent usage.
@@ -62,11 +66,36 @@ type DomainSummary struct {
 	Count       int      `json:"count"`
 	Category    Category `json:"category"`
 	InAllowlist bool     `json:"in_allowlist"`
+	// Projects lists the projects that fetched the domain.
+	Projects []string `json:"projects,omitempty"`
+}
+
+// domainProjects returns the sorted project names that fetched each domain.
+func domainProjects(scanResults []ScanResult) map[string][]string {
+	sets := make(map[string]map[string]bool)
+	for _, r := range scanResults {
+		if r.Project == "" {
+			continue
+		}
+		if sets[r.Domain] == nil {
+			sets[r.Domain] = make(map[string]bool)
+		}
+		sets[r.Domain][r.Project] = true
+	}
+	projects := make(map[string][]string, len(sets))
+	for domain, set := range sets {
+		for name := range set {
+			projects[domain] = append(projects[domain], name)
+		}
+		sort.Strings(projects[domain])
+	}
+	return projects
 }
 
 // GenerateReport creates a Report from scan results and the current allowlist.
 func GenerateReport(scanResults []ScanResult, allowlist []AllowlistEntry, sandboxDomains []string, days int, filesScanned int) Report {
 	domainCounts := make(map[string]int)
+	projects := domainProjects(scanResults)
 	webFetchCount := 0
 	fetchCount := 0
 	for _, r := range scanResults {
@@ -105,6 +134,7 @@ func GenerateReport(scanResults []ScanResult, allowlist []AllowlistEntry, sandbo
 			Count:       count,
 			Category:    cat.Category,
 			InAllowlist: inAllowlist,
+			Projects:    projects[domain],
 		})
 
 		if !inAllowlist {
@@ -113,6 +143,7 @@ func GenerateReport(scanResults []ScanResult, allowlist []AllowlistEntry, sandbo
 				Count:    count,
 				Category: cat.Category,
 				Reason:   cat.Reason,
+				Projects: projects[domain],
 			}
 			switch cat.Category {
 			case CategorySafe:
@@ -163,9 +194,10 @@ func GenerateReport(scanResults []ScanResult, allowlist []AllowlistEntry, sandbo
 		},
 		CurrentAllowlist: allowlistDomains,
 		Recommendations: Recommendations{
-			Add:    addRecs,
-			Review: reviewRecs,
-			Unused: unusedRecs,
+			Add:       addRecs,
+			Review:    reviewRecs,
+			Unused:    unusedRecs,
+			Wildcards: SuggestWildcards(allDomains, allowlistDomains, wildcardMinSubdomains),
 		},
 		AllDomains: allDomains,
 	}
@@ -179,9 +211,11 @@ func GenerateReport(scanResults []ScanResult, allowlist []AllowlistEntry, sandbo
 }
 
 func domainMatchesAllowlist(domain string, allowlistSet map[string]bool) bool {
-	if allowlistSet[domain] {
-		return true
-	}
+	return allowlistSet[domain] || matchesWildcard(domain, allowlistSet)
+}
+
+// matchesWildcard reports whether a "*.parent" entry in allowlistSet covers domain.
+func matchesWildcard(domain string, allowlistSet map[string]bool) bool {
 	parts := splitDomain(domain)
 	for i := 1; i < len(parts); i++ {
 		wildcard := "*." + joinDomain(parts[i:])
@@ -204,8 +238,15 @@ func main() {
 	days := flag.Int("days", 30, "集計期間(日数)")
 	settingsPath := flag.String("settings", "", "settings.json パス (デフォルト: ~/.claude/settings.json)")
 	projectsDirFlag := flag.String("projects-dir", "", "projects ディレクトリパス (デフォルト: ~/.claude/projects)")
+	apply := flag.Bool("apply", false, "推奨を settings.json に反映する (--accept 未指定時は1件ずつ確認)")
+	accept := flag.String("accept", "", "確認せずに反映する範囲: safe (safe カテゴリの追加と未使用の削除) または all")
 	flag.Parse()
 
+	if *accept != "" && !*apply {
+		fmt.Fprintln(os.Stderr, "--accept は --apply と合わせて指定してください")
+		os.Exit(2)
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ホームディレクトリの取得に失敗: %v\n", err)
@@ -227,7 +268,12 @@ func main() {
 	allowlist := ExtractAllowlist(s)
 	sandboxDomains := ExtractSandboxDomains(s)
 
-	scanResults, err := ScanJSONLFiles(projectsDir, *days)
+	resolver, err := project.NewDefaultResolver(home)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	scanResults, err := ScanJSONLFilesWithResolver(projectsDir, *days, resolver)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "JSONL ファイルの走査に失敗: %v\n", err)
 		os.Exit(1)
@@ -243,4 +289,42 @@ func main() {
 		fmt.Fprintf(os.Stderr, "レポートの出力に失敗: %v\n", err)
 		os.Exit(1)
 	}
+
+	if *apply {
+		if err := applyReport(*settingsPath, report, *accept, os.Stdin, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "settings.json への反映に失敗: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// applyReport selects the recommended changes and writes them to settingsPath.
+// Prompts and the summary go to out so stdout keeps only the JSON report.
+func applyReport(settingsPath string, report Report, accept string, in io.Reader, out io.Writer) error {
+	changes := PlanChanges(report)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "反映する推奨はありません")
+		return nil
+	}
+	selected, err := SelectChanges(changes, accept, in, out)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(out, "反映する変更はありません")
+		return nil
+	}
+	changed, err := ApplyChangesToFile(settingsPath, selected)
+	if err != nil {
+		return err
+	}
+	for _, c := range selected {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+	if changed {
+		fmt.Fprintf(out, "settings.json に%d件の変更を反映しました: %s\n", len(selected), settingsPath)
+	} else {
+		fmt.Fprintf(out, "settings.json は変更されませんでした: %s\n", settingsPath)
+	}
+	return nil
 }
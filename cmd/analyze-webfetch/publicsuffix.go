@@ -0,0 +1,74 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// publicSuffixListData is the upstream public_suffix_list.dat embedded verbatim.
+// The embedded copy is the 2023-02-09 snapshot; refresh it with
+// "task go:update-psl" and update this date when you do.
+//
+//go:embed data/public_suffix_list.dat
+var publicSuffixListData string
+
+// suffixRules holds the rules of a public suffix list, keyed without the
+// leading "*." or "!".
+type suffixRules struct {
+	exact     map[string]bool
+	wildcard  map[string]bool
+	exception map[string]bool
+}
+
+var publicSuffixes = parseSuffixRules(publicSuffixListData)
+
+// parseSuffixRules parses the public_suffix_list.dat format: one rule per line,
+// "//" comments, "*." wildcard rules and "!" exception rules.
+func parseSuffixRules(data string) suffixRules {
+	rules := suffixRules{
+		exact:     make(map[string]bool),
+		wildcard:  make(map[string]bool),
+		exception: make(map[string]bool),
+	}
+	for _, line := range strings.Split(data, "\n") {
+		rule := strings.ToLower(strings.TrimSpace(line))
+		if rule == "" || strings.HasPrefix(rule, "//") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			rules.exception[rule[1:]] = true
+		case strings.HasPrefix(rule, "*."):
+			rules.wildcard[rule[2:]] = true
+		default:
+			rules.exact[rule] = true
+		}
+	}
+	return rules
+}
+
+// PublicSuffix returns the public suffix of domain following the publicsuffix.org
+// algorithm: the longest matching rule wins, exception rules drop their leftmost
+// label, and an unlisted TLD is a public suffix by the implicit "*" rule.
+func (r suffixRules) PublicSuffix(domain string) string {
+	labels := splitDomain(strings.ToLower(domain))
+	for i := range labels {
+		suffix := joinDomain(labels[i:])
+		if r.exception[suffix] {
+			return joinDomain(labels[i+1:])
+		}
+		if r.exact[suffix] {
+			return suffix
+		}
+		if i+1 < len(labels) && r.wildcard[joinDomain(labels[i+1:])] {
+			return suffix
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// IsPublicSuffix reports whether domain is itself a public suffix, such as
+// "co.uk" or "github.io", under which a wildcard would span unrelated owners.
+func (r suffixRules) IsPublicSuffix(domain string) bool {
+	return r.PublicSuffix(domain) == strings.ToLower(domain)
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestPublicSuffix(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"docs.example.com", "com"},
+		{"www.example.co.uk", "co.uk"},
+		{"example.co.uk", "co.uk"},
+		{"foo.github.io", "github.io"},
+		{"bucket.s3.amazonaws.com", "s3.amazonaws.com"},
+		{"ec2-1-2-3-4.us-east-1.compute.amazonaws.com", "us-east-1.compute.amazonaws.com"},
+		{"foo.bar.ck", "bar.ck"},
+		{"www.ck", "ck"},
+		{"example.unlisted", "unlisted"},
+		{"www.example.co.za", "co.za"},
+		{"shop.example.com.sg", "com.sg"},
+		{"my-app.deno.dev", "deno.dev"},
+		{"Docs.Example.COM", "com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := publicSuffixes.PublicSuffix(tt.domain); got != tt.want {
+				t.Errorf("PublicSuffix(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPublicSuffix(t *testing.T) {
+	for domain, want := range map[string]bool{
+		"com":        true,
+		"co.uk":      true,
+		"github.io":  true,
+		"vercel.app": true,
+		// second-level registries and hosting suffixes from the full upstream list
+		"co.za":          true,
+		"com.sg":         true,
+		"com.mx":         true,
+		"deno.dev":       true,
+		"example.com.mx": false,
+		"unlisted":       true,
+		"example.com":    false,
+		"github.com":     false,
+		"www.ck":         false,
+	} {
+		if got := publicSuffixes.IsPublicSuffix(domain); got != want {
+			t.Errorf("IsPublicSuffix(%q) = %v, want %v", domain, got, want)
+		}
+	}
+}
+
+func TestParseSuffixRules(t *testing.T) {
+	rules := parseSuffixRules("// comment\n\nexample\n*.wild.example\n!keep.wild.example\n")
+	if !rules.exact["example"] || !rules.wildcard["wild.example"] || !rules.exception["keep.wild.example"] {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+	if len(rules.exact) != 1 {
+		t.Errorf("expected comments and blank lines to be skipped, got %v", rules.exact)
+	}
+}
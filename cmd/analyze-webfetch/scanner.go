@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/usadamasa/claude-config/internal/jsonlscan"
+	"github.com/usadamasa/claude-config/internal/project"
 )
 
 // ScanResult represents a single WebFetch/Fetch invocation found in a JSONL file.
@@ -15,6 +16,9 @@ type ScanResult struct {
 	Tool      string
 	Timestamp time.Time
 	FilePath  string
+	// Project is the project resolved from the cwd of the invocation.
+	Project   string
+	ProjectID string
 }
 
 // webFetchInput represents the input fields of a WebFetch tool_use.
@@ -26,10 +30,16 @@ type webFetchInput struct {
 // ScanJSONLFiles walks the given directory for .jsonl files modified within
 // the specified number of days and extracts WebFetch tool_use entries.
 func ScanJSONLFiles(projectsDir string, days int) ([]ScanResult, error) {
+	return ScanJSONLFilesWithResolver(projectsDir, days, project.NewResolver(nil))
+}
+
+// ScanJSONLFilesWithResolver is ScanJSONLFiles with project names resolved by resolver.
+// Names are assigned after all files are scanned so that same-named projects are disambiguated.
+func ScanJSONLFilesWithResolver(projectsDir string, days int, resolver *project.Resolver) ([]ScanResult, error) {
 	var results []ScanResult
 
 	err := jsonlscan.WalkJSONLFiles(projectsDir, jsonlscan.WalkOptions{Days: days}, func(path string) error {
-		fileResults, err := scanSingleFile(path)
+		fileResults, err := scanSingleFile(path, resolver)
 		if err != nil {
 			return nil
 		}
@@ -39,11 +49,14 @@ func ScanJSONLFiles(projectsDir string, days int) ([]ScanResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	for i := range results {
+		results[i].Project = resolver.Name(results[i].ProjectID)
+	}
 	return results, nil
 }
 
 // scanSingleFile reads a JSONL file line by line and extracts WebFetch entries.
-func scanSingleFile(path string) ([]ScanResult, error) {
+func scanSingleFile(path string, resolver *project.Resolver) ([]ScanResult, error) {
 	f, err := os.Open(path) // #nosec G304 -- CLIツール: パスはWalkDir由来
 	if err != nil {
 		return nil, err
@@ -64,6 +77,8 @@ func scanSingleFile(path string) ([]ScanResult, error) {
 			continue
 		}
 
+		ts, _ := jsonlscan.ParseTimestamp(entry.Timestamp)
+
 		for _, block := range entry.Message.Content {
 			if block.Type != "tool_use" {
 				continue
@@ -86,10 +101,12 @@ func scanSingleFile(path string) ([]ScanResult, error) {
 			}
 
 			results = append(results, ScanResult{
-				URL:      input.URL,
-				Domain:   domain,
-				Tool:     block.Name,
-				FilePath: path,
+				URL:       input.URL,
+				Domain:    domain,
+				Tool:      block.Name,
+				Timestamp: ts,
+				FilePath:  path,
+				ProjectID: resolver.Resolve(entry.CWD, entry.GitBranch).ID,
 			})
 		}
 	}
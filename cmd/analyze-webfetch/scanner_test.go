@@ -3,8 +3,11 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/usadamasa/claude-config/internal/project"
 )
 
 // Helper to build a JSONL line in the actual Claude session log format.
@@ -50,6 +53,53 @@ func TestScanJSONLFiles(t *testing.T) {
 		}
 	})
 
+	t.Run("resolves the project from cwd and aliases", func(t *testing.T) {
+		dir := t.TempDir()
+		line := func(cwd, url string) string {
+			return `{"type":"assistant","cwd":"` + cwd + `","message":{"role":"assistant","content":[{"type":"tool_use","name":"WebFetch","input":{"url":"` + url + `","prompt":"test"}}]}}` + "\n"
+		}
+		writeTestFile(t, dir, "session.jsonl",
+			line("/nonexistent/a/api", "https://go.dev/doc")+
+				line("/nonexistent/b/api", "https://go.dev/ref")+
+				line("/nonexistent/web", "https://go.dev/blog"))
+
+		resolver := project.NewResolver(map[string]string{"web": "frontend"})
+		results, err := ScanJSONLFilesWithResolver(dir, 30, resolver)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Project)
+		}
+		if strings.Join(got, ",") != "a/api,b/api,frontend" {
+			t.Errorf("projects = %v", got)
+		}
+
+		report := GenerateReport(results, nil, nil, 30, 1)
+		if len(report.AllDomains) != 1 || strings.Join(report.AllDomains[0].Projects, ",") != "a/api,b/api,frontend" {
+			t.Errorf("AllDomains = %+v", report.AllDomains)
+		}
+	})
+
+	t.Run("records the line timestamp", func(t *testing.T) {
+		dir := t.TempDir()
+		jsonlContent := `{"type":"assistant","timestamp":"2026-10-01T09:00:00Z","message":{"role":"assistant","content":[{"type":"tool_use","name":"WebFetch","input":{"url":"https://go.dev/doc","prompt":"test"}}]}}` + "\n"
+		writeTestFile(t, dir, "session.jsonl", jsonlContent)
+
+		results, err := ScanJSONLFiles(dir, 30)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected 1 result, got %d", len(results))
+		}
+		want := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+		if !results[0].Timestamp.Equal(want) {
+			t.Errorf("expected timestamp %v, got %v", want, results[0].Timestamp)
+		}
+	})
+
 	t.Run("extracts multiple WebFetch from single content array", func(t *testing.T) {
 		dir := t.TempDir()
 		// A single message can have multiple tool_use entries in content[]
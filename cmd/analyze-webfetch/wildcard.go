@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// wildcardMinSubdomains is the number of fetched sibling subdomains needed
+// before suggesting a wildcard for their parent domain.
+const wildcardMinSubdomains = 3
+
+// WildcardSuggestion proposes collapsing sibling subdomains into one wildcard entry.
+type WildcardSuggestion struct {
+	Wildcard string `json:"wildcard"`
+	// Subdomains lists the fetched subdomains the wildcard would cover.
+	Subdomains []string `json:"subdomains"`
+	Count      int      `json:"count"`
+	// Replaces lists the existing allowlist entries made redundant by the wildcard.
+	Replaces []string `json:"replaces,omitempty"`
+}
+
+// SuggestWildcards groups fetched domains by their parent domain and suggests
+// "*.parent" when at least minSubdomains siblings were fetched. Parents that are
+// public suffixes (co.uk, github.io, ...) are skipped because a wildcard there
+// would cover sites with unrelated owners, and so are domains already covered
+// by a wildcard in the allowlist.
+func SuggestWildcards(domains []DomainSummary, allowlist []string, minSubdomains int) []WildcardSuggestion {
+	allowSet := make(map[string]bool, len(allowlist))
+	for _, d := range allowlist {
+		allowSet[d] = true
+	}
+
+	groups := make(map[string]*WildcardSuggestion)
+	for _, d := range domains {
+		if d.Count == 0 || matchesWildcard(d.Domain, allowSet) {
+			continue
+		}
+		_, parent, ok := strings.Cut(d.Domain, ".")
+		if !ok || publicSuffixes.IsPublicSuffix(parent) {
+			continue
+		}
+		g, ok := groups[parent]
+		if !ok {
+			g = &WildcardSuggestion{Wildcard: "*." + parent}
+			groups[parent] = g
+		}
+		g.Subdomains = append(g.Subdomains, d.Domain)
+		g.Count += d.Count
+	}
+
+	var suggestions []WildcardSuggestion
+	for parent, g := range groups {
+		if len(g.Subdomains) < minSubdomains {
+			continue
+		}
+		sort.Strings(g.Subdomains)
+		for _, entry := range allowlist {
+			if strings.HasSuffix(strings.TrimPrefix(entry, "*."), "."+parent) {
+				g.Replaces = append(g.Replaces, entry)
+			}
+		}
+		sort.Strings(g.Replaces)
+		suggestions = append(suggestions, *g)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if len(suggestions[i].Subdomains) != len(suggestions[j].Subdomains) {
+			return len(suggestions[i].Subdomains) > len(suggestions[j].Subdomains)
+		}
+		return suggestions[i].Wildcard < suggestions[j].Wildcard
+	})
+	return suggestions
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestWildcards(t *testing.T) {
+	t.Run("collapses used sibling subdomains and lists replaced entries", func(t *testing.T) {
+		domains := []DomainSummary{
+			{Domain: "docs.example.com", Count: 5},
+			{Domain: "api.example.com", Count: 3},
+			{Domain: "blog.example.com", Count: 1},
+			{Domain: "example.com", Count: 2},
+			{Domain: "docs.other.com", Count: 4},
+		}
+		allowlist := []string{"api.example.com", "docs.example.com", "example.com", "*.v2.example.com", "docs.other.com"}
+
+		got := SuggestWildcards(domains, allowlist, 3)
+		want := []WildcardSuggestion{{
+			Wildcard:   "*.example.com",
+			Subdomains: []string{"api.example.com", "blog.example.com", "docs.example.com"},
+			Count:      9,
+			Replaces:   []string{"*.v2.example.com", "api.example.com", "docs.example.com"},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("does not suggest wildcards directly under public suffixes", func(t *testing.T) {
+		domains := []DomainSummary{
+			{Domain: "a.github.io", Count: 1},
+			{Domain: "b.github.io", Count: 1},
+			{Domain: "c.github.io", Count: 1},
+			{Domain: "foo.co.uk", Count: 1},
+			{Domain: "bar.co.uk", Count: 1},
+			{Domain: "baz.co.uk", Count: 1},
+			{Domain: "foo.com.sg", Count: 1},
+			{Domain: "bar.com.sg", Count: 1},
+			{Domain: "baz.com.sg", Count: 1},
+		}
+		if got := SuggestWildcards(domains, nil, 3); len(got) != 0 {
+			t.Errorf("expected no suggestions, got %+v", got)
+		}
+	})
+
+	t.Run("suggests under a registrable domain below a public suffix", func(t *testing.T) {
+		domains := []DomainSummary{
+			{Domain: "www.example.co.uk", Count: 1},
+			{Domain: "docs.example.co.uk", Count: 1},
+			{Domain: "api.example.co.uk", Count: 1},
+		}
+		got := SuggestWildcards(domains, nil, 3)
+		if len(got) != 1 || got[0].Wildcard != "*.example.co.uk" {
+			t.Errorf("expected *.example.co.uk, got %+v", got)
+		}
+	})
+
+	t.Run("skips groups below the threshold or already covered", func(t *testing.T) {
+		domains := []DomainSummary{
+			{Domain: "docs.example.com", Count: 1},
+			{Domain: "api.example.com", Count: 1},
+			{Domain: "a.covered.com", Count: 1},
+			{Domain: "b.covered.com", Count: 1},
+			{Domain: "c.covered.com", Count: 1},
+		}
+		if got := SuggestWildcards(domains, []string{"*.covered.com"}, 3); len(got) != 0 {
+			t.Errorf("expected no suggestions, got %+v", got)
+		}
+	})
+}
+
+func TestGenerateReportWildcards(t *testing.T) {
+	var scanResults []ScanResult
+	for _, d := range []string{"docs.example.com", "api.example.com", "blog.example.com"} {
+		scanResults = append(scanResults, ScanResult{Domain: d, Tool: "WebFetch", FilePath: "a.jsonl"})
+	}
+	allowlist := []AllowlistEntry{{Tool: "WebFetch", Domain: "docs.example.com"}}
+
+	report := GenerateReport(scanResults, allowlist, nil, 30, 1)
+	if len(report.Recommendations.Wildcards) != 1 {
+		t.Fatalf("expected 1 wildcard suggestion, got %+v", report.Recommendations.Wildcards)
+	}
+	if got := report.Recommendations.Wildcards[0].Replaces; !reflect.DeepEqual(got, []string{"docs.example.com"}) {
+		t.Errorf("unexpected replaces: %v", got)
+	}
+}
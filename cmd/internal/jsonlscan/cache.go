@@ -0,0 +1,170 @@
+package jsonlscan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheEntry は JSONL ファイル1つ分の解析状態｡
+// Offset は解析済みの末尾(最後の完全な行の直後)のバイト位置｡
+type CacheEntry[T any] struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Offset  int64     `json:"offset"`
+	State   T         `json:"state"`
+}
+
+// FileCache は JSONL ファイルごとの解析状態を永続化し、再解析を省く｡
+// セッション JSONL は追記のみで更新される前提で、サイズと mtime が同じファイルは解析せず、
+// 大きくなったファイルは前回の Offset から続きの行だけを解析する｡
+// State の形式を変えた場合は version を変えて古いキャッシュを捨てる｡
+// 走査で Scan されなくなったファイル(削除や期間外)のエントリは Prune で捨て、キャッシュが際限なく育たないようにする｡
+type FileCache[T any] struct {
+	Version string                    `json:"version"`
+	Files   map[string]*CacheEntry[T] `json:"files"`
+
+	path  string
+	dirty bool
+	// visited は前回の Prune 以降に Scan したファイル｡
+	visited map[string]bool
+}
+
+// LoadFileCache はキャッシュファイルを読み込む｡
+// ファイルがない、壊れている、version が異なる場合は空のキャッシュを返す｡
+func LoadFileCache[T any](path, version string) *FileCache[T] {
+	c := &FileCache[T]{Version: version, Files: make(map[string]*CacheEntry[T]), path: path, visited: make(map[string]bool)}
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはフラグ引数またはユーザーキャッシュディレクトリ由来
+	if err != nil {
+		return c
+	}
+	var loaded FileCache[T]
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Version != version || loaded.Files == nil {
+		c.dirty = true
+		return c
+	}
+	c.Files = loaded.Files
+	return c
+}
+
+// Scan は path の未解析の完全な行ごとに fn を呼び、更新後の状態を返す｡
+// 書き込み途中の末尾の行(改行で終わっていない行)は次回に回す｡
+// キャッシュがない、またはファイルが前回より小さくなった場合は newState から解析し直す｡
+func (c *FileCache[T]) Scan(path string, newState func() T, fn func(state *T, line []byte)) (T, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	c.visited[path] = true
+
+	entry, ok := c.Files[path]
+	if ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return entry.State, nil
+	}
+	if !ok || info.Size() < entry.Offset {
+		entry = &CacheEntry[T]{State: newState()}
+	}
+
+	offset, err := ReadLinesFrom(path, entry.Offset, func(line []byte) {
+		fn(&entry.State, line)
+	})
+	if err != nil {
+		// 途中まで反映した状態は Offset と一致しないため捨てる
+		delete(c.Files, path)
+		c.dirty = true
+		return entry.State, err
+	}
+	entry.Offset = offset
+	entry.Size = info.Size()
+	entry.ModTime = info.ModTime()
+	c.Files[path] = entry
+	c.dirty = true
+	return entry.State, nil
+}
+
+// Prune は前回の Prune 以降に Scan しなかったファイルのエントリを削除する｡
+// 1回の走査が終わるたびに呼ぶ前提で、永続化しないキャッシュでもメモリが際限なく増えないようにする｡
+func (c *FileCache[T]) Prune() {
+	for path := range c.Files {
+		if !c.visited[path] {
+			delete(c.Files, path)
+			c.dirty = true
+		}
+	}
+	c.visited = make(map[string]bool)
+}
+
+// Save は Prune したうえでキャッシュをファイルに書き出す｡
+// 今回の走査で Scan しなかったファイル(削除された、期間外になった)のエントリは残らない｡
+// 書き込みは一時ファイルからの rename で行い、途中で中断しても壊れたキャッシュを残さない｡
+func (c *FileCache[T]) Save() error {
+	c.Prune()
+	if !c.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o750); err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// ReadLinesFrom は path の offset 以降の改行で終わる行ごとに fn を呼ぶ｡
+// fn に渡す行は改行を含まず、呼び出し後に再利用される｡
+// 最後に読んだ完全な行の直後のバイト位置を返す｡
+func ReadLinesFrom(path string, offset int64, fn func(line []byte)) (int64, error) {
+	f, err := os.Open(path) // #nosec G304 -- CLIツール: パスはWalkDir由来
+	if err != nil {
+		return offset, err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	r := bufio.NewReaderSize(f, 1024*1024)
+	var buf []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return offset, nil
+			}
+			return offset, err
+		}
+		offset += int64(len(buf))
+		if line := bytes.TrimRight(buf, "\r\n"); len(line) > 0 {
+			fn(line)
+		}
+		buf = buf[:0]
+	}
+}
@@ -0,0 +1,149 @@
+package jsonlscan
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+type testLines struct {
+	Lines []string `json:"lines"`
+}
+
+func scanLines(t *testing.T, c *FileCache[*testLines], path string) []string {
+	t.Helper()
+	state, err := c.Scan(path, func() *testLines { return &testLines{} }, func(s **testLines, line []byte) {
+		(*s).Lines = append((*s).Lines, string(line))
+	})
+	if err != nil {
+		t.Fatalf("Scan失敗: %v", err)
+	}
+	return state.Lines
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileCacheScan(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s.jsonl")
+	cachePath := filepath.Join(dir, "cache", "cache.json")
+	if err := os.WriteFile(path, []byte("a\nb\npartial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := LoadFileCache[*testLines](cachePath, "v1")
+	t.Run("改行で終わらない末尾の行は次回に回す", func(t *testing.T) {
+		if got := scanLines(t, c, path); !slices.Equal(got, []string{"a", "b"}) {
+			t.Errorf("lines = %v", got)
+		}
+		if c.Files[path].Offset != 4 {
+			t.Errorf("Offset = %d, want 4", c.Files[path].Offset)
+		}
+	})
+
+	t.Run("追記された行だけを解析する", func(t *testing.T) {
+		appendFile(t, path, "-line\nc\n")
+		if got := scanLines(t, c, path); !slices.Equal(got, []string{"a", "b", "partial-line", "c"}) {
+			t.Errorf("lines = %v", got)
+		}
+	})
+
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save失敗: %v", err)
+	}
+
+	t.Run("保存したキャッシュから復元し未変更なら解析しない", func(t *testing.T) {
+		loaded := LoadFileCache[*testLines](cachePath, "v1")
+		state, err := loaded.Scan(path, func() *testLines { return &testLines{} }, func(**testLines, []byte) {
+			t.Error("未変更のファイルを解析してはいけない")
+		})
+		if err != nil || len(state.Lines) != 4 {
+			t.Errorf("state = %+v, err = %v", state, err)
+		}
+	})
+
+	t.Run("versionが異なれば空のキャッシュ", func(t *testing.T) {
+		if loaded := LoadFileCache[*testLines](cachePath, "v2"); len(loaded.Files) != 0 {
+			t.Errorf("Files = %v", loaded.Files)
+		}
+	})
+
+	t.Run("小さくなったファイルは最初から解析し直す", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := scanLines(t, c, path); !slices.Equal(got, []string{"x"}) {
+			t.Errorf("lines = %v", got)
+		}
+	})
+
+	t.Run("今回の走査で読まなかったファイルのエントリは保存時に消す", func(t *testing.T) {
+		other := filepath.Join(dir, "other.jsonl")
+		if err := os.WriteFile(other, []byte("o\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		scanLines(t, c, other)
+		scanLines(t, c, path)
+		if err := c.Save(); err != nil {
+			t.Fatalf("Save失敗: %v", err)
+		}
+
+		// other はファイルが残っていても、次の走査で読まなければ消える
+		scanLines(t, c, path)
+		if err := c.Save(); err != nil {
+			t.Fatalf("Save失敗: %v", err)
+		}
+		loaded := LoadFileCache[*testLines](cachePath, "v1")
+		if _, ok := loaded.Files[other]; ok || len(loaded.Files) != 1 {
+			t.Errorf("Files = %v", loaded.Files)
+		}
+	})
+
+	t.Run("削除されたファイルのエントリは保存時に消す", func(t *testing.T) {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatalf("Save失敗: %v", err)
+		}
+		if loaded := LoadFileCache[*testLines](cachePath, "v1"); len(loaded.Files) != 0 {
+			t.Errorf("Files = %v", loaded.Files)
+		}
+	})
+}
+
+func TestFileCacheModTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s.jsonl")
+	if err := os.WriteFile(path, []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := LoadFileCache[*testLines](filepath.Join(dir, "cache.json"), "v1")
+	scanLines(t, c, path)
+
+	// サイズが同じでも mtime が変われば Offset 以降を確認する
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanLines(t, c, path); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("lines = %v", got)
+	}
+	if !c.Files[path].ModTime.Equal(later) {
+		t.Errorf("ModTime = %v, want %v", c.Files[path].ModTime, later)
+	}
+}
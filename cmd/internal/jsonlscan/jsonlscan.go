@@ -13,21 +13,40 @@ import (
 // ContentBlock は message.content[] の要素を表す｡
 type ContentBlock struct {
 	Type  string          `json:"type"`
+	ID    string          `json:"id"`
 	Name  string          `json:"name"`
 	Input json.RawMessage `json:"input"`
 }
 
 // JSONLLine はセッション JSONL ファイルの1行を表す｡
 type JSONLLine struct {
-	Type    string `json:"type"`
-	Message struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	CWD       string `json:"cwd"`
+	GitBranch string `json:"gitBranch"`
+	Message   struct {
 		Content []ContentBlock `json:"content"`
 	} `json:"message"`
 }
 
+// ParseTimestamp は JSONL の timestamp フィールド(RFC3339)をパースする｡
+// 空文字や不正な形式の場合は ok=false を返す｡
+func ParseTimestamp(s string) (t time.Time, ok bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
 // WalkOptions は WalkJSONLFiles の走査オプション｡
 type WalkOptions struct {
 	Days int
+	// SkipDir が true を返したディレクトリ以下は走査しない｡nil なら全ディレクトリを走査する｡
+	SkipDir func(path string) bool
 }
 
 // WalkJSONLFiles は指定ディレクトリの JSONL ファイルを走査し、
@@ -45,6 +64,9 @@ func WalkJSONLFiles(dir string, opts WalkOptions, fn func(path string) error) er
 			return nil
 		}
 		if d.IsDir() {
+			if path != dir && opts.SkipDir != nil && opts.SkipDir(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		if !strings.HasSuffix(d.Name(), ".jsonl") {
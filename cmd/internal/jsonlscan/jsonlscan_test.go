@@ -64,6 +64,31 @@ func TestWalkJSONLFiles(t *testing.T) {
 			t.Errorf("古いファイルがコールバックに渡された: count=%d", count)
 		}
 	})
+	t.Run("SkipDir が true を返したディレクトリ以下は走査しない", func(t *testing.T) {
+		tmp := t.TempDir()
+		for _, name := range []string{"keep", "skip"} {
+			if err := os.MkdirAll(filepath.Join(tmp, name, "sub"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmp, name, "sub", "s.jsonl"), []byte("{}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var found []string
+		opts := WalkOptions{Days: 30, SkipDir: func(path string) bool { return filepath.Base(path) == "skip" }}
+		err := WalkJSONLFiles(tmp, opts, func(path string) error {
+			rel, _ := filepath.Rel(tmp, path)
+			found = append(found, rel)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(found) != 1 || found[0] != filepath.Join("keep", "sub", "s.jsonl") {
+			t.Errorf("got %v, want [keep/sub/s.jsonl]", found)
+		}
+	})
 }
 
 func TestNewScanner(t *testing.T) {
@@ -92,3 +117,24 @@ func TestCountUniqueFiles(t *testing.T) {
 		t.Errorf("got %d, want 2", got)
 	}
 }
+
+func TestParseTimestamp(t *testing.T) {
+	t.Run("RFC3339 のミリ秒付きをパースする", func(t *testing.T) {
+		got, ok := ParseTimestamp("2026-09-01T12:34:56.789Z")
+		if !ok {
+			t.Fatal("パースに成功するべき")
+		}
+		want := time.Date(2026, 9, 1, 12, 34, 56, 789000000, time.UTC)
+		if !got.Equal(want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("空文字と不正な形式は ok=false", func(t *testing.T) {
+		for _, s := range []string{"", "yesterday"} {
+			if _, ok := ParseTimestamp(s); ok {
+				t.Errorf("ParseTimestamp(%q) は失敗するべき", s)
+			}
+		}
+	})
+}
@@ -55,26 +55,32 @@ func ResolveProjectsDir(flag, home string) string {
 	return filepath.Join(home, ".claude", "projects")
 }
 
-// ResolveSettingsPath は settings.json のパスを自動検出する｡
-// cwd から親方向に git ルートを探索し､そこに settings.json があればそのパスを返す｡
-// 見つからなければ home/.claude/settings.json をデフォルトとして返す｡
-func ResolveSettingsPath(cwd, home string) (string, error) {
+// FindGitRoot は cwd から親方向に .git を探索し､見つかったディレクトリを返す｡
+// worktree の .git ファイルも git ルートとして扱う｡
+func FindGitRoot(cwd string) (string, bool) {
 	dir := cwd
 	for {
-		gitPath := filepath.Join(dir, ".git")
-		if _, err := os.Lstat(gitPath); err == nil {
-			settingsPath := filepath.Join(dir, "dotclaude", "settings.json")
-			if _, err := os.Stat(settingsPath); err == nil {
-				return settingsPath, nil
-			}
-			break
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
 		}
 		parent := filepath.Dir(dir)
 		if parent == dir {
-			break
+			return "", false
 		}
 		dir = parent
 	}
+}
+
+// ResolveSettingsPath は settings.json のパスを自動検出する｡
+// cwd から親方向に git ルートを探索し､そこに settings.json があればそのパスを返す｡
+// 見つからなければ home/.claude/settings.json をデフォルトとして返す｡
+func ResolveSettingsPath(cwd, home string) (string, error) {
+	if root, ok := FindGitRoot(cwd); ok {
+		settingsPath := filepath.Join(root, "dotclaude", "settings.json")
+		if _, err := os.Stat(settingsPath); err == nil {
+			return settingsPath, nil
+		}
+	}
 
 	return filepath.Join(home, ".claude", "settings.json"), nil
 }
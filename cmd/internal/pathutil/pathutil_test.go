@@ -251,3 +251,30 @@ func TestResolveSettingsPath(t *testing.T) {
 		}
 	})
 }
+
+func TestFindGitRoot(t *testing.T) {
+	t.Run("サブディレクトリから親のgitルートを返す", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(tmpDir, ".git"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		subDir := filepath.Join(tmpDir, "a", "b")
+		if err := os.MkdirAll(subDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok := FindGitRoot(subDir)
+		if !ok {
+			t.Fatal("gitルートが見つかるべき")
+		}
+		if got != tmpDir {
+			t.Errorf("got %s, want %s", got, tmpDir)
+		}
+	})
+
+	t.Run("gitリポジトリ外ではokがfalse", func(t *testing.T) {
+		if _, ok := FindGitRoot(t.TempDir()); ok {
+			t.Error("gitルートは見つからないべき")
+		}
+	})
+}
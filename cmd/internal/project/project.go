@@ -0,0 +1,347 @@
+package project
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AliasesFileName は ~/.claude 配下のプロジェクト別名ファイル名｡
+// 内容は ID・ルートディレクトリ・remote・表示名のいずれかから別名への JSON オブジェクト｡
+const AliasesFileName = "project-aliases.json"
+
+// Identity はセッションの cwd から決まるプロジェクトの同一性｡
+type Identity struct {
+	// ID はプロジェクトの識別子｡git remote があれば正規化した remote、なければルートディレクトリ｡
+	// 同じリポジトリの clone や worktree は同じ ID になる｡
+	ID     string
+	Root   string
+	Remote string
+	// base は他のプロジェクトと重複しない場合の表示名｡
+	base string
+}
+
+// Resolver は cwd からプロジェクトを解決し、表示名を割り当てる｡
+// 表示名は解決済みの全プロジェクトの中で重複しないよう決めるため、Name は全ての Resolve の後に呼ぶ｡
+type Resolver struct {
+	aliases map[string]string
+	byCWD   map[string]Identity
+	byID    map[string]Identity
+	names   map[string]string
+}
+
+// NewResolver は別名の対応を持つ Resolver を作る｡aliases は nil でもよい｡
+func NewResolver(aliases map[string]string) *Resolver {
+	return &Resolver{
+		aliases: aliases,
+		byCWD:   make(map[string]Identity),
+		byID:    make(map[string]Identity),
+	}
+}
+
+// DefaultAliasesPath はプロジェクト別名ファイルのパスを返す｡
+func DefaultAliasesPath(home string) string {
+	return filepath.Join(home, ".claude", AliasesFileName)
+}
+
+// LoadAliases はプロジェクト別名ファイルを読み込む｡ファイルがなければ nil を返す｡
+func LoadAliases(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはホームディレクトリ由来
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var aliases map[string]string
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("プロジェクト別名ファイルのパースに失敗 (%s): %w", path, err)
+	}
+	return aliases, nil
+}
+
+// NewDefaultResolver は ~/.claude/project-aliases.json の別名を使う Resolver を作る｡
+func NewDefaultResolver(home string) (*Resolver, error) {
+	aliases, err := LoadAliases(DefaultAliasesPath(home))
+	if err != nil {
+		return nil, err
+	}
+	return NewResolver(aliases), nil
+}
+
+// Resolve は cwd と gitBranch (トランスクリプトの各行のフィールド) からプロジェクトを解決する｡
+// cwd を含む git リポジトリがあればそのルートと remote を使う｡
+// cwd が存在しない場合は worktree のパス構成や gitBranch からルートを推定する｡
+func (r *Resolver) Resolve(cwd, gitBranch string) Identity {
+	if cwd == "" {
+		return Identity{}
+	}
+	key := cwd + "\x00" + gitBranch
+	if id, ok := r.byCWD[key]; ok {
+		return id
+	}
+
+	var id Identity
+	if root, gitDir, ok := findGitRoot(cwd); ok {
+		id = Identity{Root: root, Remote: readRemote(gitDir)}
+	} else {
+		id = Identity{Root: guessRoot(cwd, gitBranch)}
+	}
+	id.ID = id.Root
+	if id.Remote != "" {
+		id.ID = id.Remote
+	}
+	id.base = lastSegments(id.ID, 1)
+
+	r.byCWD[key] = id
+	if _, ok := r.byID[id.ID]; !ok {
+		r.byID[id.ID] = id
+		r.names = nil
+	}
+	return id
+}
+
+// Name は ID に対応する表示名を返す｡
+// 別名があればそれを、なければ末尾のパス要素を使い、重複する場合は親の要素を加えて区別する｡
+func (r *Resolver) Name(id string) string {
+	if id == "" {
+		return ""
+	}
+	if r.names == nil {
+		r.names = r.assignNames()
+	}
+	if name, ok := r.names[id]; ok {
+		return name
+	}
+	return lastSegments(id, 1)
+}
+
+// Matches は name が id の ID・ルートディレクトリ・別名・表示名のいずれかになりうるかを返す｡
+// 表示名は全プロジェクトの解決後に決まるため、重複の区別で親の要素を加えた名前も含めて ID の末尾の要素と比べる｡
+func (r *Resolver) Matches(id Identity, name string) bool {
+	if name == "" || id.ID == "" {
+		return false
+	}
+	if name == id.ID || name == id.Root {
+		return true
+	}
+	if alias, ok := r.alias(id); ok && alias == name {
+		return true
+	}
+	n := len(splitSegments(name))
+	return n > 0 && lastSegments(id.ID, n) == strings.Join(splitSegments(name), "/")
+}
+
+func (r *Resolver) alias(id Identity) (string, bool) {
+	for _, key := range []string{id.ID, id.Root, id.Remote, id.base} {
+		if name, ok := r.aliases[key]; ok && key != "" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (r *Resolver) assignNames() map[string]string {
+	names := make(map[string]string, len(r.byID))
+	groups := make(map[string][]string)
+	for idKey, id := range r.byID {
+		if name, ok := r.alias(id); ok {
+			names[idKey] = name
+			continue
+		}
+		groups[id.base] = append(groups[id.base], idKey)
+	}
+	for base, ids := range groups {
+		if len(ids) == 1 {
+			names[ids[0]] = base
+			continue
+		}
+		sort.Strings(ids)
+		for id, name := range disambiguate(ids) {
+			names[id] = name
+		}
+	}
+	return names
+}
+
+// disambiguate は末尾の要素数を増やしながら、全 ID の表示名が重複しなくなる最短の名前を返す｡
+func disambiguate(ids []string) map[string]string {
+	maxDepth := 0
+	for _, id := range ids {
+		maxDepth = max(maxDepth, len(splitSegments(id)))
+	}
+	for depth := 2; ; depth++ {
+		names := make(map[string]string, len(ids))
+		seen := make(map[string]bool, len(ids))
+		unique := true
+		for _, id := range ids {
+			name := lastSegments(id, depth)
+			if seen[name] {
+				unique = false
+			}
+			seen[name] = true
+			names[id] = name
+		}
+		if unique || depth >= maxDepth {
+			return names
+		}
+	}
+}
+
+func splitSegments(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == '/' })
+}
+
+// lastSegments は "/" 区切りの末尾 n 要素を返す｡
+func lastSegments(s string, n int) string {
+	parts := splitSegments(s)
+	if len(parts) > n {
+		parts = parts[len(parts)-n:]
+	}
+	return strings.Join(parts, "/")
+}
+
+// findGitRoot は cwd から親方向に .git を探し、リポジトリのルートと git ディレクトリを返す｡
+// worktree (.git がファイル) の場合は本体のリポジトリを返す｡
+func findGitRoot(cwd string) (root, gitDir string, ok bool) {
+	dir := filepath.Clean(cwd)
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		if err == nil && info.IsDir() {
+			return dir, dotGit, true
+		}
+		if err == nil {
+			if linked, ok := readGitFile(dir, dotGit); ok {
+				if common, ok := readCommonDir(linked); ok && filepath.Base(common) == ".git" {
+					return filepath.Dir(common), common, true
+				}
+				// submodule などは本体と別のリポジトリとして扱う
+				return dir, linked, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+// readGitFile は "gitdir: <path>" 形式の .git ファイルから git ディレクトリを読む｡
+func readGitFile(dir, path string) (string, bool) {
+	data, err := os.ReadFile(path) // #nosec G304 -- CLIツール: パスはトランスクリプトの cwd 由来
+	if err != nil {
+		return "", false
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", false
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return filepath.Clean(gitDir), true
+}
+
+// readCommonDir は worktree の git ディレクトリの commondir から本体の git ディレクトリを読む｡
+func readCommonDir(gitDir string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir")) // #nosec G304 -- CLIツール: パスは .git ファイル由来
+	if err != nil {
+		return "", false
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common), true
+}
+
+// readRemote は git の config から origin (なければ最初の remote) の URL を正規化して返す｡
+func readRemote(gitDir string) string {
+	f, err := os.Open(filepath.Join(gitDir, "config")) // #nosec G304 -- CLIツール: パスは git ディレクトリ由来
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	var section, first, origin string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "url" || !strings.HasPrefix(section, `[remote "`) {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if first == "" {
+			first = value
+		}
+		if section == `[remote "origin"]` {
+			origin = value
+		}
+	}
+	if origin == "" {
+		origin = first
+	}
+	return NormalizeRemote(origin)
+}
+
+// NormalizeRemote は git remote の URL を host/owner/repo 形式にする｡
+// https・ssh・scp 形式の違い、ユーザー名、末尾の .git を区別しない｡
+func NormalizeRemote(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(remote), "/"), ".git")
+	if remote == "" {
+		return ""
+	}
+	var host, path string
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" && u.Host != "" {
+		host, path = u.Hostname(), u.Path
+	} else if h, p, ok := strings.Cut(remote, ":"); ok && !strings.Contains(h, "/") {
+		// scp 形式: git@github.com:owner/repo
+		host, path = h, p
+		if _, after, ok := strings.Cut(host, "@"); ok {
+			host = after
+		}
+	} else {
+		// ローカルパスの remote はそのまま使う
+		return remote
+	}
+	return strings.ToLower(host) + "/" + strings.Trim(path, "/")
+}
+
+// guessRoot は存在しない cwd (削除済みの worktree など) からプロジェクトのルートを推定する｡
+func guessRoot(cwd, gitBranch string) string {
+	cwd = filepath.Clean(cwd)
+	parts := strings.Split(cwd, "/")
+	for i, p := range parts {
+		// <repo>/.claude/worktrees/<name>
+		if p == ".claude" && i+2 < len(parts) && parts[i+1] == "worktrees" && i > 0 {
+			return strings.Join(parts[:i], "/")
+		}
+		// .../worktree/<repo>/<branch>
+		if p == "worktree" && i+1 < len(parts) {
+			return strings.Join(parts[:i+2], "/")
+		}
+	}
+	// ブランチ名のディレクトリに作った worktree
+	if gitBranch != "" && gitBranch != "HEAD" {
+		for _, suffix := range []string{gitBranch, strings.ReplaceAll(gitBranch, "/", "-")} {
+			if root, ok := strings.CutSuffix(cwd, "/"+suffix); ok && root != "" {
+				return root
+			}
+		}
+	}
+	return cwd
+}
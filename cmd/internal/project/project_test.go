@@ -0,0 +1,191 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const gitConfig = `[core]
+	bare = false
+[remote "upstream"]
+	url = https://github.com/upstream/api.git
+[remote "origin"]
+	url = git@github.com:org/api.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`
+
+func TestResolveGitRepository(t *testing.T) {
+	tmp := t.TempDir()
+	repo := filepath.Join(tmp, "src", "api")
+	writeFile(t, filepath.Join(repo, ".git", "config"), gitConfig)
+	writeFile(t, filepath.Join(repo, "pkg", "server", "main.go"), "package main\n")
+	// worktree: .git ファイルから本体の git ディレクトリをたどる
+	wt := filepath.Join(tmp, "wt", "feature")
+	writeFile(t, filepath.Join(repo, ".git", "worktrees", "feature", "commondir"), "../..\n")
+	writeFile(t, filepath.Join(wt, ".git"), "gitdir: "+filepath.Join(repo, ".git", "worktrees", "feature")+"\n")
+
+	r := NewResolver(nil)
+	root := r.Resolve(repo, "main")
+	if root.Root != repo || root.Remote != "github.com/org/api" || root.ID != "github.com/org/api" {
+		t.Errorf("root = %+v", root)
+	}
+	t.Run("サブディレクトリは同じプロジェクト", func(t *testing.T) {
+		if got := r.Resolve(filepath.Join(repo, "pkg", "server"), "main"); got.ID != root.ID {
+			t.Errorf("ID = %q, want %q", got.ID, root.ID)
+		}
+	})
+	t.Run("worktreeは本体と同じプロジェクト", func(t *testing.T) {
+		got := r.Resolve(wt, "feature")
+		if got.ID != root.ID || got.Root != repo {
+			t.Errorf("worktree = %+v", got)
+		}
+	})
+	if name := r.Name(root.ID); name != "api" {
+		t.Errorf("Name = %q, want api", name)
+	}
+}
+
+func TestResolveWithoutRepository(t *testing.T) {
+	tests := []struct {
+		name   string
+		cwd    string
+		branch string
+		want   string
+	}{
+		{"GitHub構成のパス", "/nonexistent/src/github.com/org/my-project", "", "my-project"},
+		{"worktreeパス", "/nonexistent/src/github.com/org/worktree/my-project/feature-branch", "", "my-project"},
+		{"Claudeのworktree", "/nonexistent/src/my-project/.claude/worktrees/fix-bug", "", "my-project"},
+		{"ブランチ名のworktree", "/nonexistent/src/my-project/feat/login", "feat/login", "my-project"},
+		{"ブランチ名を-で連結したworktree", "/nonexistent/src/my-project/feat-login", "feat/login", "my-project"},
+		{"一般パス", "/nonexistent/workspace/project", "main", "project"},
+		{"空文字列", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(nil)
+			if got := r.Name(r.Resolve(tt.cwd, tt.branch).ID); got != tt.want {
+				t.Errorf("Name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverName(t *testing.T) {
+	t.Run("同名のプロジェクトは親の要素で区別する", func(t *testing.T) {
+		r := NewResolver(nil)
+		a := r.Resolve("/nonexistent/src/a/api", "")
+		b := r.Resolve("/nonexistent/src/b/api", "")
+		c := r.Resolve("/nonexistent/src/web", "")
+		if got := r.Name(a.ID); got != "a/api" {
+			t.Errorf("a = %q", got)
+		}
+		if got := r.Name(b.ID); got != "b/api" {
+			t.Errorf("b = %q", got)
+		}
+		if got := r.Name(c.ID); got != "web" {
+			t.Errorf("c = %q", got)
+		}
+	})
+
+	t.Run("別名はID・ルート・表示名のいずれでも指定できる", func(t *testing.T) {
+		r := NewResolver(map[string]string{
+			"/nonexistent/src/a/api": "billing-api",
+			"web":                    "frontend",
+		})
+		a := r.Resolve("/nonexistent/src/a/api", "")
+		b := r.Resolve("/nonexistent/src/b/api", "")
+		c := r.Resolve("/nonexistent/src/web", "")
+		if got := r.Name(a.ID); got != "billing-api" {
+			t.Errorf("a = %q", got)
+		}
+		// 別名を付けたプロジェクトとは衝突しない
+		if got := r.Name(b.ID); got != "api" {
+			t.Errorf("b = %q", got)
+		}
+		if got := r.Name(c.ID); got != "frontend" {
+			t.Errorf("c = %q", got)
+		}
+	})
+
+	t.Run("後から解決したプロジェクトも区別に含める", func(t *testing.T) {
+		r := NewResolver(nil)
+		a := r.Resolve("/nonexistent/a/api", "")
+		if got := r.Name(a.ID); got != "api" {
+			t.Errorf("a = %q", got)
+		}
+		r.Resolve("/nonexistent/b/api", "")
+		if got := r.Name(a.ID); got != "a/api" {
+			t.Errorf("a = %q", got)
+		}
+	})
+}
+
+func TestResolverMatches(t *testing.T) {
+	r := NewResolver(map[string]string{"/nonexistent/src/b/api": "billing-api"})
+	a := r.Resolve("/nonexistent/src/a/api", "")
+	b := r.Resolve("/nonexistent/src/b/api", "")
+	for _, tt := range []struct {
+		name string
+		id   Identity
+		want bool
+	}{
+		{"/nonexistent/src/a/api", a, true},
+		{"api", a, true},
+		{"a/api", a, true},
+		{"b/api", a, false},
+		{"billing-api", b, true},
+		{"billing-api", a, false},
+		{"pi", a, false},
+		{"", a, false},
+	} {
+		if got := r.Matches(tt.id, tt.name); got != tt.want {
+			t.Errorf("Matches(%s, %q) = %v, want %v", tt.id.ID, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRemote(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{"https://github.com/org/repo.git", "github.com/org/repo"},
+		{"https://user@GitHub.com/org/repo/", "github.com/org/repo"},
+		{"ssh://git@github.com:22/org/repo.git", "github.com/org/repo"},
+		{"git@github.com:org/repo.git", "github.com/org/repo"},
+		{"/srv/git/repo.git", "/srv/git/repo"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRemote(tt.remote); got != tt.want {
+			t.Errorf("NormalizeRemote(%q) = %q, want %q", tt.remote, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAliases(t *testing.T) {
+	dir := t.TempDir()
+	if aliases, err := LoadAliases(filepath.Join(dir, AliasesFileName)); err != nil || aliases != nil {
+		t.Errorf("ファイルがなければ nil: %v, %v", aliases, err)
+	}
+	path := filepath.Join(dir, AliasesFileName)
+	writeFile(t, path, `{"github.com/org/api":"api"}`)
+	if aliases, err := LoadAliases(path); err != nil || aliases["github.com/org/api"] != "api" {
+		t.Errorf("aliases = %v, err = %v", aliases, err)
+	}
+	writeFile(t, path, `[`)
+	if _, err := LoadAliases(path); err == nil {
+		t.Error("不正なJSONはエラーになるべき")
+	}
+}
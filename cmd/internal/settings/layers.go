@@ -0,0 +1,230 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Scope は settings.json のレイヤ(適用範囲)を表す｡
+type Scope string
+
+const (
+	ScopeManaged Scope = "managed"
+	ScopeLocal   Scope = "local"
+	ScopeProject Scope = "project"
+	ScopeUser    Scope = "user"
+)
+
+// Permission list 名｡deny > ask > allow の順に強い｡
+const (
+	ListAllow = "allow"
+	ListAsk   = "ask"
+	ListDeny  = "deny"
+)
+
+// listStrength は permission list の優先度を返す(大きいほど強い)｡
+var listStrength = map[string]int{
+	ListAllow: 1,
+	ListAsk:   2,
+	ListDeny:  3,
+}
+
+// LayerSource は読み込み対象のレイヤとファイルパスの組｡
+type LayerSource struct {
+	Scope Scope
+	Path  string
+	// Required はファイルが存在しない場合にエラーとするか｡ユーザーが明示したパスに使う｡
+	Required bool
+}
+
+// Layer は読み込まれた1レイヤ分の settings｡
+type Layer struct {
+	Scope    Scope     `json:"scope"`
+	Path     string    `json:"path"`
+	Settings *Settings `json:"-"`
+}
+
+// Rule は有効なパーミッションルールとその出所を表す｡
+type Rule struct {
+	Entry string `json:"entry"`
+	List  string `json:"list"`
+	Scope Scope  `json:"scope"`
+	Path  string `json:"path"`
+}
+
+// Override は下位レイヤ(または弱い list)のルールが打ち消されていることを表す｡
+type Override struct {
+	Entry          string `json:"entry"`
+	List           string `json:"list"`
+	Scope          Scope  `json:"scope"`
+	OverriddenBy   Scope  `json:"overridden_by"`
+	OverridingList string `json:"overriding_list"`
+	Reason         string `json:"reason"`
+}
+
+// Effective は全レイヤをマージした有効なパーミッション集合｡
+type Effective struct {
+	Layers    []Layer    `json:"layers"`
+	Allow     []Rule     `json:"allow"`
+	Ask       []Rule     `json:"ask"`
+	Deny      []Rule     `json:"deny"`
+	Overrides []Override `json:"overrides,omitempty"`
+}
+
+// Entries は指定 list のエントリ文字列を返す｡
+func (e *Effective) Entries(list string) []string {
+	var entries []string
+	for _, r := range e.rules(list) {
+		entries = append(entries, r.Entry)
+	}
+	return entries
+}
+
+// Find は指定 list から entry に一致するルールを返す｡
+func (e *Effective) Find(list, entry string) (Rule, bool) {
+	for _, r := range e.rules(list) {
+		if r.Entry == entry {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (e *Effective) rules(list string) []Rule {
+	switch list {
+	case ListAllow:
+		return e.Allow
+	case ListAsk:
+		return e.Ask
+	case ListDeny:
+		return e.Deny
+	default:
+		return nil
+	}
+}
+
+// ManagedSettingsPath は OS ごとの managed-settings.json のパスを返す｡
+func ManagedSettingsPath() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "/Library/Application Support/ClaudeCode/managed-settings.json"
+	case "windows":
+		return `C:\Program Files\ClaudeCode\managed-settings.json`
+	default:
+		return "/etc/claude-code/managed-settings.json"
+	}
+}
+
+// DefaultLayerSources は Claude Code の優先順位(高い順)でレイヤの読み込み元を返す｡
+// projectRoot が空の場合はプロジェクトレイヤを含めない｡
+// userRequired が true の場合は user レイヤのファイルが存在しなければ LoadLayers がエラーを返す｡
+func DefaultLayerSources(userPath, projectRoot string, userRequired bool) []LayerSource {
+	sources := []LayerSource{{Scope: ScopeManaged, Path: ManagedSettingsPath()}}
+	if projectRoot != "" {
+		sources = append(sources,
+			LayerSource{Scope: ScopeLocal, Path: filepath.Join(projectRoot, ".claude", "settings.local.json")},
+			LayerSource{Scope: ScopeProject, Path: filepath.Join(projectRoot, ".claude", "settings.json")},
+		)
+	}
+	return append(sources, LayerSource{Scope: ScopeUser, Path: userPath, Required: userRequired})
+}
+
+// LoadLayers は sources を順に読み込む｡存在しないファイルは Required でなければスキップする｡
+// 同じパスが複数のレイヤに指定された場合は最初のレイヤのみ採用する｡
+func LoadLayers(sources []LayerSource) ([]Layer, error) {
+	var layers []Layer
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		if src.Path == "" || seen[src.Path] {
+			continue
+		}
+		seen[src.Path] = true
+
+		s, err := Load(src.Path)
+		if errors.Is(err, os.ErrNotExist) && !src.Required {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, Layer{Scope: src.Scope, Path: src.Path, Settings: s})
+	}
+	return layers, nil
+}
+
+// Merge は優先順位の高い順に並んだ layers をマージする｡
+// Claude Code と同様に各 list は全レイヤの和集合となり、
+// 同じエントリが複数レイヤにある場合は上位レイヤを出所とする｡
+// 同一エントリがより強い list にある場合(deny > ask > allow)は Override として記録する｡
+func Merge(layers []Layer) *Effective {
+	eff := &Effective{Layers: layers}
+	seen := map[string]map[string]Rule{
+		ListAllow: {},
+		ListAsk:   {},
+		ListDeny:  {},
+	}
+
+	for _, layer := range layers {
+		lists := map[string][]string{
+			ListAllow: layer.Settings.Permissions.Allow,
+			ListAsk:   layer.Settings.Permissions.Ask,
+			ListDeny:  layer.Settings.Permissions.Deny,
+		}
+		for _, list := range []string{ListDeny, ListAsk, ListAllow} {
+			for _, entry := range lists[list] {
+				if prev, ok := seen[list][entry]; ok {
+					if prev.Path != layer.Path {
+						eff.Overrides = append(eff.Overrides, Override{
+							Entry:          entry,
+							List:           list,
+							Scope:          layer.Scope,
+							OverriddenBy:   prev.Scope,
+							OverridingList: list,
+							Reason:         "上位レイヤに同じエントリがある",
+						})
+					}
+					continue
+				}
+				rule := Rule{Entry: entry, List: list, Scope: layer.Scope, Path: layer.Path}
+				seen[list][entry] = rule
+				eff.appendRule(rule)
+			}
+		}
+	}
+
+	for _, list := range []string{ListAllow, ListAsk} {
+		for _, rule := range eff.rules(list) {
+			for _, stronger := range []string{ListDeny, ListAsk} {
+				if listStrength[stronger] <= listStrength[list] {
+					continue
+				}
+				if s, ok := seen[stronger][rule.Entry]; ok {
+					eff.Overrides = append(eff.Overrides, Override{
+						Entry:          rule.Entry,
+						List:           list,
+						Scope:          rule.Scope,
+						OverriddenBy:   s.Scope,
+						OverridingList: stronger,
+						Reason:         stronger + " が " + list + " より優先される",
+					})
+					break
+				}
+			}
+		}
+	}
+
+	return eff
+}
+
+func (e *Effective) appendRule(r Rule) {
+	switch r.List {
+	case ListAllow:
+		e.Allow = append(e.Allow, r)
+	case ListAsk:
+		e.Ask = append(e.Ask, r)
+	case ListDeny:
+		e.Deny = append(e.Deny, r)
+	}
+}
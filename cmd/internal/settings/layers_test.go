@@ -0,0 +1,164 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLayer(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultLayerSources(t *testing.T) {
+	t.Run("優先順位の高い順に並ぶ", func(t *testing.T) {
+		sources := DefaultLayerSources("/home/u/.claude/settings.json", "/repo", false)
+		want := []Scope{ScopeManaged, ScopeLocal, ScopeProject, ScopeUser}
+		if len(sources) != len(want) {
+			t.Fatalf("got %d sources, want %d", len(sources), len(want))
+		}
+		for i, s := range sources {
+			if s.Scope != want[i] {
+				t.Errorf("sources[%d].Scope = %s, want %s", i, s.Scope, want[i])
+			}
+		}
+		if sources[1].Path != filepath.Join("/repo", ".claude", "settings.local.json") {
+			t.Errorf("local path = %s", sources[1].Path)
+		}
+	})
+
+	t.Run("projectRootが空ならプロジェクトレイヤを含めない", func(t *testing.T) {
+		sources := DefaultLayerSources("/home/u/.claude/settings.json", "", false)
+		if len(sources) != 2 {
+			t.Fatalf("got %d sources, want 2", len(sources))
+		}
+	})
+}
+
+func TestLoadLayers(t *testing.T) {
+	t.Run("存在しないファイルはスキップする", func(t *testing.T) {
+		tmp := t.TempDir()
+		user := writeLayer(t, tmp, "user.json", `{"permissions":{"allow":["Bash(ls:*)"]}}`)
+
+		layers, err := LoadLayers([]LayerSource{
+			{Scope: ScopeManaged, Path: filepath.Join(tmp, "missing.json")},
+			{Scope: ScopeUser, Path: user},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(layers) != 1 || layers[0].Scope != ScopeUser {
+			t.Fatalf("got %+v, want user layer only", layers)
+		}
+	})
+
+	t.Run("Required のファイルが存在しなければエラーを返す", func(t *testing.T) {
+		tmp := t.TempDir()
+		_, err := LoadLayers([]LayerSource{
+			{Scope: ScopeManaged, Path: filepath.Join(tmp, "missing-managed.json")},
+			{Scope: ScopeUser, Path: filepath.Join(tmp, "typo.json"), Required: true},
+		})
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got %v, want os.ErrNotExist", err)
+		}
+	})
+
+	t.Run("同じパスは最初のレイヤのみ採用する", func(t *testing.T) {
+		tmp := t.TempDir()
+		path := writeLayer(t, tmp, "settings.json", `{}`)
+
+		layers, err := LoadLayers([]LayerSource{
+			{Scope: ScopeProject, Path: path},
+			{Scope: ScopeUser, Path: path},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(layers) != 1 || layers[0].Scope != ScopeProject {
+			t.Fatalf("got %+v, want project layer only", layers)
+		}
+	})
+
+	t.Run("不正なJSONはエラーを返す", func(t *testing.T) {
+		tmp := t.TempDir()
+		path := writeLayer(t, tmp, "settings.json", `{invalid`)
+
+		if _, err := LoadLayers([]LayerSource{{Scope: ScopeUser, Path: path}}); err == nil {
+			t.Error("エラーが返されるべき")
+		}
+	})
+}
+
+func TestMerge(t *testing.T) {
+	tmp := t.TempDir()
+	project := writeLayer(t, tmp, "project.json", `{
+		"permissions": {
+			"allow": ["Bash(go test:*)"],
+			"deny": ["Bash(git push:*)"]
+		}
+	}`)
+	user := writeLayer(t, tmp, "user.json", `{
+		"permissions": {
+			"allow": ["Bash(go test:*)", "Bash(git push:*)", "Bash(ls:*)"],
+			"ask": ["Bash(ls:*)"]
+		}
+	}`)
+	layers, err := LoadLayers([]LayerSource{
+		{Scope: ScopeProject, Path: project},
+		{Scope: ScopeUser, Path: user},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eff := Merge(layers)
+
+	t.Run("各listは全レイヤの和集合になる", func(t *testing.T) {
+		if got := eff.Entries(ListAllow); len(got) != 3 {
+			t.Errorf("allow = %v, want 3 entries", got)
+		}
+		if got := eff.Entries(ListDeny); len(got) != 1 {
+			t.Errorf("deny = %v, want 1 entry", got)
+		}
+	})
+
+	t.Run("重複エントリは上位レイヤを出所とする", func(t *testing.T) {
+		r, ok := eff.Find(ListAllow, "Bash(go test:*)")
+		if !ok {
+			t.Fatal("Bash(go test:*) が見つからない")
+		}
+		if r.Scope != ScopeProject || r.Path != project {
+			t.Errorf("got %+v, want project layer", r)
+		}
+	})
+
+	t.Run("上書きを記録する", func(t *testing.T) {
+		want := map[string]Override{
+			"Bash(go test:*)":  {List: ListAllow, Scope: ScopeUser, OverriddenBy: ScopeProject, OverridingList: ListAllow},
+			"Bash(git push:*)": {List: ListAllow, Scope: ScopeUser, OverriddenBy: ScopeProject, OverridingList: ListDeny},
+			"Bash(ls:*)":       {List: ListAllow, Scope: ScopeUser, OverriddenBy: ScopeUser, OverridingList: ListAsk},
+		}
+		if len(eff.Overrides) != len(want) {
+			t.Fatalf("got %d overrides, want %d: %+v", len(eff.Overrides), len(want), eff.Overrides)
+		}
+		for _, o := range eff.Overrides {
+			w, ok := want[o.Entry]
+			if !ok {
+				t.Errorf("unexpected override: %+v", o)
+				continue
+			}
+			if o.List != w.List || o.Scope != w.Scope || o.OverriddenBy != w.OverriddenBy || o.OverridingList != w.OverridingList {
+				t.Errorf("%s: got %+v, want %+v", o.Entry, o, w)
+			}
+		}
+	})
+}
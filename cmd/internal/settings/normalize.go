@@ -1,4 +1,4 @@
-package main
+package settings
 
 import (
 	"bytes"
@@ -8,6 +8,10 @@ import (
 	"sort"
 )
 
+// DefaultStripFields は正規化時に除去するランタイムフィールドのデフォルト｡
+// Claude Code が実行中に書き込むため、バージョン管理対象の settings.json には残さない｡
+var DefaultStripFields = []string{"effortLevel", "teammateMode"}
+
 // Normalize は settings.json のバイト列を正規化する｡
 // 戻り値: 正規化済みバイト列、警告メッセージ、エラー
 func Normalize(data []byte, pinnedModel string, stripFields []string) ([]byte, []string, error) {
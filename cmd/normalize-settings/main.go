@@ -7,13 +7,14 @@ import (
 	"strings"
 
 	"github.com/usadamasa/claude-config/internal/pathutil"
+	"github.com/usadamasa/claude-config/internal/settings"
 )
 
 func main() {
 	settingsPath := flag.String("settings", "", "settings.json パス (デフォルト: 自動検出)")
 	check := flag.Bool("check", false, "読み取り専用モード。差分があれば exit 1")
 	pinnedModel := flag.String("pinned-model", "claude-opus-4-6", "model の期待値")
-	stripFieldsCSV := flag.String("strip-fields", "effortLevel,teammateMode", "除去するランタイムフィールド (カンマ区切り)")
+	stripFieldsCSV := flag.String("strip-fields", strings.Join(settings.DefaultStripFields, ","), "除去するランタイムフィールド (カンマ区切り)")
 	flag.Parse()
 
 	var stripFields []string
@@ -59,7 +60,7 @@ func runCheck(path, pinnedModel string, stripFields []string) {
 		os.Exit(1)
 	}
 
-	normalized, warns, err := Normalize(data, pinnedModel, stripFields)
+	normalized, warns, err := settings.Normalize(data, pinnedModel, stripFields)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "正規化に失敗: %v\n", err)
 		os.Exit(1)
@@ -79,7 +80,7 @@ func runCheck(path, pinnedModel string, stripFields []string) {
 }
 
 func runNormalize(path, pinnedModel string, stripFields []string) {
-	changed, warns, err := NormalizeFile(path, pinnedModel, stripFields)
+	changed, warns, err := settings.NormalizeFile(path, pinnedModel, stripFields)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "正規化に失敗: %v\n", err)
 		os.Exit(1)